@@ -0,0 +1,756 @@
+package bindgen
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ark-lang/ark/src/parser"
+	"github.com/ark-lang/ark/src/util"
+	"github.com/ark-lang/ark/src/util/log"
+)
+
+// Bindgen translates the declarations of a C header to Ark: functions become
+// [c] function declarations, and the types they use become Ark types with the
+// same layout. Integer types are mapped to the types of the C scope where the
+// compiler has one, and otherwise to primitives of the same size, assuming
+// the LP64 data model.
+//
+// Declarations that can't be translated are left out, with a comment in the
+// output saying why.
+type Bindgen struct {
+	Input  string // the C header
+	Output string // the Ark file to write
+
+	typedefs  map[string]*cType
+	records   map[string]*cRecord // tagged records, by `struct tag` or `union tag`
+	enums     map[string]*cEnum
+	constants map[string]constValue // enum members and macros, for constant expressions
+	defines   map[string]*define
+
+	decls []interface{} // *cRecord, *cEnum, *typedefDecl or *functionDecl, in source order
+
+	typeNames  map[string]bool
+	valueNames map[string]bool
+	opaque     map[string]bool // names of types which are used but not defined in the header
+	anonCount  int
+}
+
+type typedefDecl struct {
+	Name  string
+	Type  *cType
+	alias bool // the typedef names a record or enum, and isn't translated itself
+}
+
+type functionDecl struct {
+	Name string
+	Type *cType
+	Line int
+}
+
+func (v *Bindgen) err(err string, stuff ...interface{}) {
+	log.Error("bindgen", util.TEXT_RED+util.TEXT_BOLD+"error:"+util.TEXT_RESET+" %s\n",
+		fmt.Sprintf(err, stuff...))
+	os.Exit(util.EXIT_FAILURE_SETUP)
+}
+
+func (v *Bindgen) warn(line int, err string, stuff ...interface{}) {
+	log.Warning("bindgen", util.TEXT_YELLOW+util.TEXT_BOLD+"warning:"+util.TEXT_RESET+" [%s:%d] %s\n",
+		filepath.Base(v.Input), line, fmt.Sprintf(err, stuff...))
+}
+
+// Reports a declaration which is left out of the output
+func (v *Bindgen) skip(line int, name string, reason string) {
+	log.Verboseln("bindgen", "[%s:%d] Skipping `%s`: %s", filepath.Base(v.Input), line, name, reason)
+}
+
+func (v *Bindgen) Generate() {
+	log.Verboseln("bindgen", util.TEXT_BOLD+util.TEXT_GREEN+"Started bindgen"+util.TEXT_RESET)
+	t := time.Now()
+
+	contents, err := ioutil.ReadFile(v.Input)
+	if err != nil {
+		v.err("Couldn't read header %s: `%s`", v.Input, err.Error())
+	}
+
+	v.typedefs = make(map[string]*cType)
+	v.records = make(map[string]*cRecord)
+	v.enums = make(map[string]*cEnum)
+	v.constants = make(map[string]constValue)
+	v.defines = make(map[string]*define)
+	v.typeNames = make(map[string]bool)
+	v.valueNames = make(map[string]bool)
+	v.opaque = make(map[string]bool)
+
+	tokens, defines := lex(v, string(contents))
+
+	p := &cParser{gen: v, tokens: v.expandMacros(tokens, make(map[string]bool))}
+	p.parse()
+
+	v.nameTypes()
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "// Generated by ark bindgen from %s, do not edit.\n", filepath.Base(v.Input))
+
+	types, functions := new(bytes.Buffer), new(bytes.Buffer)
+	for _, decl := range v.decls {
+		switch decl := decl.(type) {
+		case *functionDecl:
+			v.writeFunction(functions, decl)
+		case *cRecord:
+			v.writeRecord(types, decl)
+		case *cEnum:
+			v.writeEnum(types, decl)
+		case *typedefDecl:
+			v.writeTypedef(types, decl)
+		}
+	}
+
+	constants := new(bytes.Buffer)
+	v.writeConstants(constants, defines)
+
+	var opaque []string
+	for name := range v.opaque {
+		opaque = append(opaque, name)
+	}
+	sort.Strings(opaque)
+	for _, name := range opaque {
+		fmt.Fprintf(types, "\n// not defined in %s\npub type %s struct {};\n", filepath.Base(v.Input), name)
+	}
+
+	// every type starts with an empty line already
+	for _, part := range []*bytes.Buffer{constants, functions} {
+		if part.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		buf.Write(part.Bytes())
+		if part == constants {
+			buf.Write(types.Bytes())
+		}
+	}
+
+	if err := ioutil.WriteFile(v.Output, buf.Bytes(), 0666); err != nil {
+		v.err("Couldn't write %s: `%s`", v.Output, err.Error())
+	}
+
+	dur := time.Since(t)
+	log.Verbose("bindgen", util.TEXT_BOLD+util.TEXT_GREEN+"Finished bindgen"+util.TEXT_RESET+" (%.2fms)\n",
+		float32(dur.Nanoseconds())/1000000)
+}
+
+// Replaces object-like macros by their definitions
+func (v *Bindgen) expandMacros(tokens []*token, expanding map[string]bool) []*token {
+	var res []*token
+	for _, tok := range tokens {
+		def, ok := v.defines[tok.Contents]
+		if tok.Type != TOKEN_IDENTIFIER || !ok || def.IsFunction || expanding[tok.Contents] {
+			res = append(res, tok)
+			continue
+		}
+
+		expanding[tok.Contents] = true
+		res = append(res, v.expandMacros(def.Body, expanding)...)
+		delete(expanding, tok.Contents)
+	}
+	return res
+}
+
+func (v *Bindgen) record(tag string, isUnion bool) *cRecord {
+	if tag == "" {
+		rec := &cRecord{IsUnion: isUnion}
+		v.decls = append(v.decls, rec)
+		return rec
+	}
+
+	key := "struct " + tag
+	if isUnion {
+		key = "union " + tag
+	}
+
+	// tagged records are translated even if they are never defined, as
+	// pointers to them may be used
+	rec, ok := v.records[key]
+	if !ok {
+		rec = &cRecord{Tag: tag, IsUnion: isUnion}
+		v.records[key] = rec
+		v.decls = append(v.decls, rec)
+	}
+	return rec
+}
+
+func (v *Bindgen) enum(tag string) *cEnum {
+	if tag == "" {
+		return &cEnum{}
+	}
+
+	enum, ok := v.enums[tag]
+	if !ok {
+		enum = &cEnum{Tag: tag}
+		v.enums[tag] = enum
+	}
+	return enum
+}
+
+func (v *Bindgen) addTypedef(name string, typ *cType) {
+	if _, ok := v.typedefs[name]; ok {
+		return
+	}
+
+	// an unknown type can be redefined as itself, which would make the
+	// typedef refer to itself
+	if actual := v.resolve(typ); actual.Kind == C_TYPEDEF && actual.Name == name {
+		return
+	}
+	v.typedefs[name] = typ
+	v.decls = append(v.decls, &typedefDecl{Name: name, Type: typ})
+}
+
+func (v *Bindgen) addFunction(name string, typ *cType, line int) {
+	v.decls = append(v.decls, &functionDecl{Name: name, Type: typ, Line: line})
+}
+
+// Arrays and functions are passed as pointers in C
+func (v *Bindgen) adjustParameter(typ *cType) *cType {
+	switch actual := v.resolve(typ); actual.Kind {
+	case C_ARRAY:
+		return pointerTo(actual.Elem)
+	case C_FUNCTION:
+		return pointerTo(typ)
+	}
+	return typ
+}
+
+// Follows typedefs to the type they name
+func (v *Bindgen) resolve(typ *cType) *cType {
+	for typ.Kind == C_TYPEDEF {
+		actual, ok := v.typedefs[typ.Name]
+		if !ok {
+			break
+		}
+		typ = actual
+	}
+	return typ
+}
+
+func (v *Bindgen) isUnsigned(typ *cType) bool {
+	typ = v.resolve(typ)
+	if typ.Kind == C_TYPEDEF {
+		if builtin, ok := builtinTypedefs[typ.Name]; ok {
+			return strings.HasPrefix(builtin.Ark, "u")
+		}
+	}
+	return typ.Kind == C_POINTER || (typ.Kind == C_PRIMITIVE && strings.HasPrefix(typ.Name, "unsigned"))
+}
+
+// Returns a name which is valid in Ark for the C name
+func sanitizeName(name string) string {
+	if !parser.IsReservedKeyword(name) {
+		return name
+	}
+	if strings.HasPrefix(name, "_") {
+		return "c" + name
+	}
+	return name + "_"
+}
+
+func (v *Bindgen) uniqueTypeName(name string, kind string) string {
+	name = sanitizeName(name)
+	if v.typeNames[name] {
+		name += "_" + kind
+	}
+	for base, idx := name, 1; v.typeNames[name]; idx++ {
+		name = fmt.Sprintf("%s%d", base, idx)
+	}
+
+	v.typeNames[name] = true
+	return name
+}
+
+// Decides the Ark names of the types. Records and enums declared in a
+// typedef are named after the typedef, which is then not translated.
+func (v *Bindgen) nameTypes() {
+	for _, decl := range v.decls {
+		if decl, ok := decl.(*typedefDecl); ok {
+			switch typ := decl.Type; typ.Kind {
+			case C_RECORD:
+				if typ.Record.name == "" {
+					typ.Record.name = v.uniqueTypeName(decl.Name, "struct")
+					decl.alias = true
+				}
+			case C_ENUM:
+				if typ.Enum.name == "" {
+					typ.Enum.name = v.uniqueTypeName(decl.Name, "enum")
+					decl.alias = true
+				}
+			}
+		}
+	}
+
+	for _, decl := range v.decls {
+		if decl, ok := decl.(*typedefDecl); ok && !decl.alias {
+			v.typeNames[sanitizeName(decl.Name)] = true
+		}
+	}
+
+	for _, decl := range v.decls {
+		switch decl := decl.(type) {
+		case *cRecord:
+			v.recordName(decl)
+		case *cEnum:
+			if decl.name == "" && decl.Tag != "" {
+				decl.name = v.uniqueTypeName(decl.Tag, "enum")
+			}
+		}
+	}
+}
+
+func (v *Bindgen) recordName(rec *cRecord) string {
+	if rec.name != "" {
+		return rec.name
+	}
+
+	kind := "struct"
+	if rec.IsUnion {
+		kind = "union"
+	}
+
+	switch {
+	case rec.Tag != "":
+		rec.name = v.uniqueTypeName(rec.Tag, kind)
+	case rec.parent != nil:
+		rec.name = v.uniqueTypeName(v.recordName(rec.parent)+"_"+rec.field, kind)
+	default:
+		rec.name = v.uniqueTypeName(fmt.Sprintf("anon%d", v.anonCount), kind)
+		v.anonCount++
+	}
+	return rec.name
+}
+
+// Returns the Ark type of a value of the C type
+func (v *Bindgen) arkType(typ *cType) (string, error) {
+	switch typ.Kind {
+	case C_PRIMITIVE:
+		if ark, ok := primitiveArkTypes[typ.Name]; ok {
+			return ark, nil
+		}
+		if typ.Name == "void" {
+			return "", fmt.Errorf("`void` is only allowed as a return type or behind a pointer")
+		}
+		return "", fmt.Errorf("type `%s` has no Ark equivalent", typ.Name)
+
+	case C_POINTER:
+		return v.arkPointerType(typ.Elem)
+
+	case C_ARRAY:
+		return "", fmt.Errorf("arrays are only supported as fields and parameters")
+
+	case C_FUNCTION:
+		return v.arkFunctionType(typ)
+
+	case C_RECORD:
+		if _, _, err := v.layout(typ); err != nil {
+			return "", err
+		}
+		return v.recordName(typ.Record), nil
+
+	case C_ENUM:
+		if typ.Enum.name == "" {
+			return "C::int", nil
+		}
+		return typ.Enum.name, nil
+
+	case C_TYPEDEF:
+		actual, ok := v.typedefs[typ.Name]
+		if !ok {
+			if builtin, ok := builtinTypedefs[typ.Name]; ok {
+				return builtin.Ark, nil
+			}
+			return "", fmt.Errorf("unknown type `%s`", typ.Name)
+		}
+
+		// a function type can only be assigned functions of the exact same
+		// type, so typedefs of function types are replaced by the type
+		if v.isFunctionTypedef(typ.Name) {
+			return v.arkType(actual)
+		}
+
+		// the translation of the typedef fails the same way
+		ark, err := v.arkType(actual)
+		if err != nil && v.resolve(actual).Kind != C_ARRAY {
+			return "", err
+		}
+		if v.isAlias(typ.Name) {
+			return ark, nil
+		}
+		return sanitizeName(typ.Name), nil
+	}
+
+	panic("unreachable")
+}
+
+func (v *Bindgen) arkPointerType(elem *cType) (string, error) {
+	switch elem.Kind {
+	case C_PRIMITIVE:
+		if elem.Name == "void" {
+			return "^C::void", nil
+		}
+
+	case C_RECORD:
+		return "^" + v.recordName(elem.Record), nil
+
+	case C_FUNCTION:
+		return v.arkFunctionType(elem)
+
+	case C_TYPEDEF:
+		actual, ok := v.typedefs[elem.Name]
+		if !ok {
+			if _, ok := builtinTypedefs[elem.Name]; !ok {
+				// a type from another header, which is only used through
+				// pointers here
+				name := sanitizeName(elem.Name)
+				v.opaque[name] = true
+				return "^" + name, nil
+			}
+		} else if v.isAlias(elem.Name) {
+			return v.arkPointerType(actual)
+		} else if v.resolve(actual).Kind == C_FUNCTION {
+			// Ark function types are pointers already
+			return v.arkType(elem)
+		}
+	}
+
+	ark, err := v.arkType(elem)
+	if err != nil {
+		return "", err
+	}
+	return "^" + ark, nil
+}
+
+func (v *Bindgen) arkFunctionType(typ *cType) (string, error) {
+	params, ret, err := v.arkSignature(typ)
+	if err != nil {
+		return "", err
+	}
+
+	types := make([]string, len(params))
+	for idx, par := range params {
+		types[idx] = par[1]
+	}
+	if typ.IsVariadic {
+		types = append(types, "...")
+	}
+
+	return "[c] func(" + strings.Join(types, ", ") + ")" + ret, nil
+}
+
+// Returns the names and types of the parameters, and the return type after
+// an arrow, of the function type typ
+func (v *Bindgen) arkSignature(typ *cType) ([][2]string, string, error) {
+	var params [][2]string
+	for idx, par := range typ.Params {
+		ark, err := v.arkType(par.Type)
+		if err != nil {
+			return nil, "", fmt.Errorf("parameter %d: %s", idx+1, err.Error())
+		}
+
+		name := par.Name
+		if name == "" {
+			name = fmt.Sprintf("arg%d", idx)
+		}
+		params = append(params, [2]string{sanitizeName(name), ark})
+	}
+
+	ret := ""
+	if actual := v.resolve(typ.Elem); actual.Kind != C_PRIMITIVE || actual.Name != "void" {
+		ark, err := v.arkType(typ.Elem)
+		if err != nil {
+			return nil, "", fmt.Errorf("return type: %s", err.Error())
+		}
+		ret = " -> " + ark
+	}
+
+	return params, ret, nil
+}
+
+// Returns true if the typedef names a function type or a function pointer type
+func (v *Bindgen) isFunctionTypedef(typedef string) bool {
+	typ := v.resolve(v.typedefs[typedef])
+	if typ.Kind == C_POINTER {
+		typ = v.resolve(typ.Elem)
+	}
+	return typ.Kind == C_FUNCTION
+}
+
+func (v *Bindgen) isAlias(typedef string) bool {
+	for _, decl := range v.decls {
+		if decl, ok := decl.(*typedefDecl); ok && decl.Name == typedef {
+			return decl.alias
+		}
+	}
+	return false
+}
+
+func (v *Bindgen) writeFunction(buf *bytes.Buffer, decl *functionDecl) {
+	if v.valueNames[decl.Name] {
+		return
+	}
+	v.valueNames[decl.Name] = true
+
+	params, ret, err := v.arkSignature(decl.Type)
+	if err != nil {
+		v.skip(decl.Line, decl.Name, err.Error())
+		fmt.Fprintf(buf, "// skipped %s: %s\n", decl.Name, err.Error())
+		return
+	}
+
+	pars := make([]string, len(params))
+	for idx, par := range params {
+		pars[idx] = par[0] + ": " + par[1]
+	}
+	if decl.Type.IsVariadic {
+		pars = append(pars, "...")
+	}
+
+	fmt.Fprintf(buf, "[c] func %s(%s)%s;\n", decl.Name, strings.Join(pars, ", "), ret)
+}
+
+func (v *Bindgen) writeRecord(buf *bytes.Buffer, rec *cRecord) {
+	name := v.recordName(rec)
+
+	var fields []string
+	var err error
+	if rec.IsUnion {
+		fields, err = v.unionFields(rec)
+	} else {
+		for _, field := range rec.Fields {
+			var res []string
+			res, err = v.fieldDeclarations(sanitizeName(field.Name), field.Type)
+			if err != nil {
+				err = fmt.Errorf("field `%s`: %s", field.Name, err.Error())
+				break
+			}
+			fields = append(fields, res...)
+		}
+	}
+
+	if rec.Bitfields {
+		err = fmt.Errorf("bitfields aren't supported")
+	}
+
+	buf.WriteString("\n")
+	if err != nil {
+		fmt.Fprintf(buf, "// opaque, %s\npub type %s struct {};\n", err.Error(), name)
+		return
+	}
+
+	if rec.IsUnion {
+		fmt.Fprintf(buf, "// union of")
+		for idx, field := range rec.Fields {
+			if idx > 0 {
+				buf.WriteString(",")
+			}
+			ark, err := v.arkType(field.Type)
+			if err != nil {
+				ark = "?"
+			}
+			fmt.Fprintf(buf, " %s: %s", field.Name, ark)
+		}
+		buf.WriteString("\n")
+	}
+
+	attrs := ""
+	if rec.Packed {
+		attrs = "[packed] "
+	}
+
+	if len(fields) == 0 {
+		fmt.Fprintf(buf, "pub type %s %sstruct {};\n", name, attrs)
+		return
+	}
+
+	fmt.Fprintf(buf, "pub type %s %sstruct {\n", name, attrs)
+	for idx, field := range fields {
+		if idx < len(fields)-1 {
+			field += ","
+		}
+		fmt.Fprintf(buf, "\t%s\n", field)
+	}
+	buf.WriteString("};\n")
+}
+
+// Returns the fields for a C field. Arrays have a field for every element.
+func (v *Bindgen) fieldDeclarations(name string, typ *cType) ([]string, error) {
+	actual := v.resolve(typ)
+	if actual.Kind != C_ARRAY {
+		ark, err := v.arkType(typ)
+		if err != nil {
+			return nil, err
+		}
+		return []string{name + ": " + ark}, nil
+	}
+
+	if actual.Length < 0 {
+		return nil, fmt.Errorf("flexible array members aren't supported")
+	}
+
+	var res []string
+	for idx := 0; idx < actual.Length; idx++ {
+		fields, err := v.fieldDeclarations(fmt.Sprintf("%s_%d", name, idx), actual.Elem)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, fields...)
+	}
+	return res, nil
+}
+
+// Ark has no unions, so a union is a struct of integers with the size and
+// alignment of the union
+func (v *Bindgen) unionFields(rec *cRecord) ([]string, error) {
+	size, align, err := v.layout(&cType{Kind: C_RECORD, Record: rec})
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, size/align)
+	for idx := range res {
+		res[idx] = fmt.Sprintf("data_%d: u%d", idx, align*8)
+	}
+	return res, nil
+}
+
+func (v *Bindgen) writeEnum(buf *bytes.Buffer, enum *cEnum) {
+	// Ark enums can only have distinct, positive values
+	simple := enum.name != ""
+	used := make(map[int64]bool)
+	for _, mem := range enum.Members {
+		if mem.Value < 0 || mem.Value > math.MaxInt32 || used[mem.Value] {
+			simple = false
+		}
+		used[mem.Value] = true
+	}
+
+	buf.WriteString("\n")
+	if simple {
+		fmt.Fprintf(buf, "pub type %s enum {\n", enum.name)
+		next := int64(0)
+		for idx, mem := range enum.Members {
+			fmt.Fprintf(buf, "\t%s", sanitizeName(mem.Name))
+			if mem.Value != next {
+				fmt.Fprintf(buf, " = %d", mem.Value)
+			}
+			if idx < len(enum.Members)-1 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+			next = mem.Value + 1
+		}
+		buf.WriteString("};\n")
+		return
+	}
+
+	if enum.name != "" {
+		fmt.Fprintf(buf, "pub type %s C::int;\n", enum.name)
+	}
+	for _, mem := range enum.Members {
+		v.writeConstant(buf, mem.Name, constValue{Value: mem.Value})
+	}
+}
+
+func (v *Bindgen) writeTypedef(buf *bytes.Buffer, decl *typedefDecl) {
+	if decl.alias || v.isFunctionTypedef(decl.Name) {
+		return
+	}
+	name := sanitizeName(decl.Name)
+
+	// fixed size arrays become structs with a field for every element
+	if actual := v.resolve(decl.Type); actual.Kind == C_ARRAY {
+		var fields []string
+		for idx := 0; idx < actual.Length; idx++ {
+			res, err := v.fieldDeclarations(fmt.Sprintf("_%d", idx), actual.Elem)
+			if err != nil {
+				fmt.Fprintf(buf, "\n// skipped %s: %s\n", decl.Name, err.Error())
+				return
+			}
+			fields = append(fields, res...)
+		}
+
+		fmt.Fprintf(buf, "\npub type %s struct {\n", name)
+		for idx, field := range fields {
+			if idx < len(fields)-1 {
+				field += ","
+			}
+			fmt.Fprintf(buf, "\t%s\n", field)
+		}
+		buf.WriteString("};\n")
+		return
+	}
+
+	ark, err := v.arkType(decl.Type)
+	if err != nil {
+		fmt.Fprintf(buf, "\n// skipped %s: %s\n", decl.Name, err.Error())
+		return
+	}
+	if ark == name {
+		return
+	}
+	fmt.Fprintf(buf, "\npub type %s %s;\n", name, ark)
+}
+
+// Writes the macros which are integer constants
+func (v *Bindgen) writeConstants(buf *bytes.Buffer, defines []*define) {
+	for _, def := range defines {
+		if v.defines[def.Name] != def || def.IsFunction || len(def.Body) == 0 {
+			continue
+		}
+
+		tokens := v.expandMacros(def.Body, map[string]bool{def.Name: true})
+		tokens = append(tokens, &token{Type: TOKEN_EOF, Line: def.Line})
+		if value, ok := v.evalConstant(tokens); ok {
+			v.constants[def.Name] = value
+			v.writeConstant(buf, def.Name, value)
+		}
+	}
+}
+
+func (v *Bindgen) evalConstant(tokens []*token) (res constValue, ok bool) {
+	p := &cParser{gen: v, tokens: tokens}
+	defer func() {
+		if r := recover(); r != nil {
+			if _, isParseErr := r.(parseError); !isParseErr {
+				panic(r)
+			}
+			ok = false
+		}
+	}()
+
+	res = p.parseConstExpr()
+	return res, p.peek(0).Type == TOKEN_EOF
+}
+
+func (v *Bindgen) writeConstant(buf *bytes.Buffer, name string, value constValue) {
+	name = sanitizeName(name)
+	if v.valueNames[name] {
+		return
+	}
+	v.valueNames[name] = true
+
+	// the type of the constant is the type C gives it
+	typ, lit := "C::int", fmt.Sprintf("%d", value.Value)
+	switch {
+	case value.Unsigned && uint64(value.Value) <= math.MaxUint32:
+		typ = "C::uint"
+	case value.Unsigned:
+		typ, lit = "u64", fmt.Sprintf("%d", uint64(value.Value))
+	case value.Value < math.MinInt32 || value.Value > math.MaxInt32:
+		typ = "s64"
+	}
+
+	fmt.Fprintf(buf, "[unused] pub %s: %s = %s;\n", name, typ, lit)
+}
@@ -0,0 +1,126 @@
+package bindgen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var bindgenTests = []struct {
+	name   string
+	header string
+	want   []string // lines the output must contain
+}{
+	{
+		name: "macros",
+		header: `#define BUFFER_SIZE 256
+#define FLAGS (1 << 3 | 1)
+#define LIMIT (BUFFER_SIZE * 2)
+#define NAME "ark"
+`,
+		want: []string{
+			"[unused] pub BUFFER_SIZE: C::int = 256;",
+			"[unused] pub FLAGS: C::int = 9;",
+			"[unused] pub LIMIT: C::int = 512;",
+		},
+	},
+	{
+		name: "unions",
+		header: `union value {
+    int i;
+    double d;
+};
+void set(union value *v);
+`,
+		want: []string{
+			"// union of i: C::int, d: f64",
+			"pub type value struct {",
+			"\tdata_0: u64",
+			"[c] func set(v: ^value);",
+		},
+	},
+	{
+		name: "bitfields",
+		header: `struct flags {
+    unsigned int ready : 1;
+    unsigned int mode : 3;
+};
+`,
+		want: []string{
+			"// opaque, bitfields aren't supported",
+			"pub type flags struct {};",
+		},
+	},
+	{
+		name: "arrays",
+		header: `struct matrix {
+    float cells[2][2];
+    int count;
+};
+`,
+		want: []string{
+			"\tcells_0_0: f32,",
+			"\tcells_0_1: f32,",
+			"\tcells_1_0: f32,",
+			"\tcells_1_1: f32,",
+			"\tcount: C::int",
+		},
+	},
+	{
+		name: "keywords",
+		header: `struct keywords {
+    int func;
+    int match;
+};
+int apply(int func, struct keywords *_Keys);
+`,
+		want: []string{
+			"\tfunc_: C::int,",
+			"\tmatch_: C::int",
+			"[c] func apply(func_: C::int, c_Keys: ^keywords) -> C::int;",
+		},
+	},
+}
+
+func TestBindgen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ark-bindgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, test := range bindgenTests {
+		gen := &Bindgen{
+			Input:  filepath.Join(dir, test.name+".h"),
+			Output: filepath.Join(dir, test.name+".ark"),
+		}
+
+		if err := ioutil.WriteFile(gen.Input, []byte(test.header), 0666); err != nil {
+			t.Fatal(err)
+		}
+		gen.Generate()
+
+		output, err := ioutil.ReadFile(gen.Output)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(string(output), "\n")
+		for _, want := range test.want {
+			if !containsLine(lines, want) {
+				t.Errorf("%s: output doesn't contain `%s`:\n%s", test.name, want, output)
+			}
+		}
+	}
+}
+
+func containsLine(lines []string, line string) bool {
+	for _, have := range lines {
+		if have == line {
+			return true
+		}
+	}
+	return false
+}
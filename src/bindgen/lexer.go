@@ -0,0 +1,345 @@
+package bindgen
+
+import (
+	"strings"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	TOKEN_IDENTIFIER tokenType = iota
+	TOKEN_NUMBER
+	TOKEN_CHAR
+	TOKEN_STRING
+	TOKEN_PUNCT
+	TOKEN_EOF
+)
+
+type token struct {
+	Type     tokenType
+	Contents string
+	Line     int
+	end      int // offset of the end of the token in the input
+}
+
+// A macro defined with #define. Function-like macros are only kept for #ifdef,
+// as only macros which expand to constants are translated.
+type define struct {
+	Name       string
+	Body       []*token
+	Line       int
+	IsFunction bool
+}
+
+// Punctuators of more than one character, longest first
+var punctuators = []string{
+	"...", "<<=", ">>=",
+	"->", "++", "--", "<<", ">>", "<=", ">=", "==", "!=", "&&", "||",
+	"*=", "/=", "%=", "+=", "-=", "&=", "^=", "|=", "##",
+}
+
+type lexer struct {
+	gen *Bindgen
+
+	input []rune
+	pos   int
+	line  int
+
+	tokens  []*token
+	defines []*define // all definitions, in order
+
+	conditionals []*conditional
+}
+
+// The state of an #if, #ifdef or #ifndef directive
+type conditional struct {
+	active bool // whether the lines in the current branch are read
+	taken  bool // whether one of the branches has been active
+	outer  bool // whether the lines around the conditional are read
+}
+
+// Splits the header into tokens. Preprocessor directives are not part of the
+// tokens: conditionals are evaluated with the macros defined in the header,
+// and the macros defined by #define are collected in the Bindgen. Includes are
+// not followed.
+func lex(gen *Bindgen, input string) ([]*token, []*define) {
+	v := &lexer{gen: gen, input: []rune(input), line: 1}
+
+	lineStart := true
+	for {
+		v.skipSpace()
+		if v.pos >= len(v.input) {
+			break
+		}
+
+		if v.peek(0) == '\n' {
+			v.consume()
+			lineStart = true
+			continue
+		}
+
+		if lineStart && v.peek(0) == '#' {
+			v.consume()
+			v.lexDirective()
+			continue
+		}
+
+		lineStart = false
+		tok := v.lexToken()
+		if v.active() {
+			v.tokens = append(v.tokens, tok)
+		}
+	}
+
+	v.tokens = append(v.tokens, &token{Type: TOKEN_EOF, Line: v.line})
+	return v.tokens, v.defines
+}
+
+func (v *lexer) active() bool {
+	return len(v.conditionals) == 0 || v.conditionals[len(v.conditionals)-1].active
+}
+
+func (v *lexer) peek(ahead int) rune {
+	if v.pos+ahead >= len(v.input) {
+		return 0
+	}
+	return v.input[v.pos+ahead]
+}
+
+func (v *lexer) consume() rune {
+	r := v.input[v.pos]
+	if r == '\n' {
+		v.line++
+	}
+	v.pos++
+	return r
+}
+
+// Skips whitespace other than newlines, comments, and escaped newlines
+func (v *lexer) skipSpace() {
+	for v.pos < len(v.input) {
+		switch {
+		case v.peek(0) == '\\' && v.peek(1) == '\n':
+			v.consume()
+			v.consume()
+
+		case v.peek(0) == '/' && v.peek(1) == '/':
+			for v.pos < len(v.input) && v.peek(0) != '\n' {
+				v.consume()
+			}
+
+		case v.peek(0) == '/' && v.peek(1) == '*':
+			v.consume()
+			v.consume()
+			for v.pos < len(v.input) && !(v.peek(0) == '*' && v.peek(1) == '/') {
+				v.consume()
+			}
+			if v.pos < len(v.input) {
+				v.consume()
+				v.consume()
+			}
+
+		case v.peek(0) != '\n' && unicode.IsSpace(v.peek(0)):
+			v.consume()
+
+		default:
+			return
+		}
+	}
+}
+
+func (v *lexer) lexDirective() {
+	line := v.line
+
+	var tokens []*token
+	for {
+		v.skipSpace()
+		if v.pos >= len(v.input) || v.peek(0) == '\n' {
+			break
+		}
+		tokens = append(tokens, v.lexToken())
+	}
+
+	if len(tokens) == 0 {
+		return
+	}
+
+	directive, args := tokens[0].Contents, tokens[1:]
+	switch directive {
+	case "if", "ifdef", "ifndef":
+		cond := &conditional{outer: v.active()}
+		if cond.outer {
+			switch directive {
+			case "if":
+				cond.active = v.evalCondition(line, args)
+			case "ifdef":
+				cond.active = len(args) > 0 && v.gen.defines[args[0].Contents] != nil
+			case "ifndef":
+				cond.active = len(args) > 0 && v.gen.defines[args[0].Contents] == nil
+			}
+		}
+		cond.taken = cond.active
+		v.conditionals = append(v.conditionals, cond)
+
+	case "elif", "else":
+		if len(v.conditionals) == 0 {
+			v.gen.warn(line, "#%s without #if", directive)
+			return
+		}
+
+		cond := v.conditionals[len(v.conditionals)-1]
+		cond.active = cond.outer && !cond.taken && (directive == "else" || v.evalCondition(line, args))
+		cond.taken = cond.taken || cond.active
+
+	case "endif":
+		if len(v.conditionals) == 0 {
+			v.gen.warn(line, "#endif without #if")
+			return
+		}
+		v.conditionals = v.conditionals[:len(v.conditionals)-1]
+
+	case "define":
+		if !v.active() || len(args) == 0 || args[0].Type != TOKEN_IDENTIFIER {
+			return
+		}
+
+		// the parameter list of a function-like macro directly follows its
+		// name, without any whitespace in between
+		name := args[0]
+		def := &define{Name: name.Contents, Body: args[1:], Line: line}
+		def.IsFunction = name.end < len(v.input) && v.input[name.end] == '('
+
+		v.defines = append(v.defines, def)
+		v.gen.defines[def.Name] = def
+
+	case "undef":
+		if v.active() && len(args) > 0 {
+			delete(v.gen.defines, args[0].Contents)
+		}
+	}
+}
+
+// Evaluates the condition of an #if or #elif directive. As in C, identifiers
+// which are not macros are 0.
+func (v *lexer) evalCondition(line int, tokens []*token) bool {
+	var replaced []*token
+	for idx := 0; idx < len(tokens); idx++ {
+		tok := tokens[idx]
+		if tok.Type != TOKEN_IDENTIFIER || tok.Contents != "defined" {
+			replaced = append(replaced, tok)
+			continue
+		}
+
+		// defined NAME or defined(NAME)
+		name := ""
+		if idx+1 < len(tokens) && tokens[idx+1].Type == TOKEN_IDENTIFIER {
+			name = tokens[idx+1].Contents
+			idx++
+		} else if idx+3 < len(tokens) && tokens[idx+1].Contents == "(" && tokens[idx+3].Contents == ")" {
+			name = tokens[idx+2].Contents
+			idx += 3
+		}
+
+		value := "0"
+		if v.gen.defines[name] != nil {
+			value = "1"
+		}
+		replaced = append(replaced, &token{Type: TOKEN_NUMBER, Contents: value, Line: tok.Line})
+	}
+
+	expanded := v.gen.expandMacros(replaced, make(map[string]bool))
+	for idx, tok := range expanded {
+		if tok.Type == TOKEN_IDENTIFIER {
+			expanded[idx] = &token{Type: TOKEN_NUMBER, Contents: "0", Line: tok.Line}
+		}
+	}
+	expanded = append(expanded, &token{Type: TOKEN_EOF, Line: line})
+
+	value, ok := v.gen.evalConstant(expanded)
+	if !ok {
+		v.gen.warn(line, "Couldn't evaluate condition, assuming it is false")
+	}
+	return ok && value.Value != 0
+}
+
+func (v *lexer) lexToken() *token {
+	start := v.pos
+	tok := &token{Line: v.line}
+
+	r := v.peek(0)
+	switch {
+	case r == '_' || unicode.IsLetter(r):
+		tok.Type = TOKEN_IDENTIFIER
+		for r := v.peek(0); r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r); r = v.peek(0) {
+			v.consume()
+		}
+
+		// wide and unicode string or character literals
+		if v.peek(0) == '"' || v.peek(0) == '\'' {
+			switch string(v.input[start:v.pos]) {
+			case "L", "u", "U", "u8":
+				v.lexQuoted(tok)
+			}
+		}
+
+	case unicode.IsDigit(r) || (r == '.' && unicode.IsDigit(v.peek(1))):
+		tok.Type = TOKEN_NUMBER
+		for {
+			r := v.peek(0)
+			if (r == '+' || r == '-') && strings.ContainsRune("eEpP", v.input[v.pos-1]) {
+				v.consume()
+			} else if r == '.' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+				v.consume()
+			} else {
+				break
+			}
+		}
+
+	case r == '"' || r == '\'':
+		v.lexQuoted(tok)
+
+	default:
+		tok.Type = TOKEN_PUNCT
+		matched := false
+		for _, punct := range punctuators {
+			if strings.HasPrefix(string(v.input[v.pos:min(v.pos+len(punct), len(v.input))]), punct) {
+				v.pos += len(punct)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.consume()
+		}
+	}
+
+	tok.Contents = string(v.input[start:v.pos])
+	tok.end = v.pos
+	return tok
+}
+
+func (v *lexer) lexQuoted(tok *token) {
+	quote := v.consume()
+	if quote == '"' {
+		tok.Type = TOKEN_STRING
+	} else {
+		tok.Type = TOKEN_CHAR
+	}
+
+	for v.pos < len(v.input) && v.peek(0) != quote && v.peek(0) != '\n' {
+		if v.consume() == '\\' && v.pos < len(v.input) {
+			v.consume()
+		}
+	}
+	if v.peek(0) == quote {
+		v.consume()
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
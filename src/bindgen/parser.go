@@ -0,0 +1,1001 @@
+package bindgen
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The parser reads the declarations of a header, which are collected in the
+// Bindgen. It doesn't try to understand all of C: a declaration it can't parse
+// is skipped, and parsing continues after it.
+
+type parseError struct {
+	msg string
+}
+
+type cParser struct {
+	gen    *Bindgen
+	tokens []*token
+	pos    int
+
+	externBlocks int // number of `extern "C" {` blocks the parser is in
+}
+
+type specifiers struct {
+	typ *cType
+
+	isTypedef bool
+	isStatic  bool
+	isExtern  bool
+}
+
+// Type qualifiers, storage classes and other keywords which don't change the
+// translation of a declaration
+var ignoredKeywords = map[string]bool{
+	"const": true, "__const": true, "volatile": true, "__volatile__": true,
+	"restrict": true, "__restrict": true, "__restrict__": true, "_Atomic": true,
+	"inline": true, "__inline": true, "__inline__": true, "_Noreturn": true,
+	"register": true, "auto": true, "__extension__": true,
+	"_Thread_local": true, "__thread": true, "__cdecl": true, "__stdcall": true,
+}
+
+// Keywords followed by a parenthesized argument, which are skipped
+var attributeKeywords = map[string]bool{
+	"__attribute__": true, "__attribute": true, "__declspec": true,
+	"__asm__": true, "__asm": true, "asm": true, "_Alignas": true,
+}
+
+var primitiveKeywords = map[string]bool{
+	"void": true, "char": true, "short": true, "int": true, "long": true,
+	"float": true, "double": true, "signed": true, "unsigned": true,
+	"_Bool": true, "__int128": true, "_Complex": true,
+}
+
+func (v *cParser) err(err string, stuff ...interface{}) {
+	panic(parseError{fmt.Sprintf(err, stuff...)})
+}
+
+func (v *cParser) peek(ahead int) *token {
+	if v.pos+ahead >= len(v.tokens) {
+		return v.tokens[len(v.tokens)-1]
+	}
+	return v.tokens[v.pos+ahead]
+}
+
+func (v *cParser) next() *token {
+	tok := v.peek(0)
+	if tok.Type != TOKEN_EOF {
+		v.pos++
+	}
+	return tok
+}
+
+func (v *cParser) is(contents string) bool {
+	tok := v.peek(0)
+	return tok.Type != TOKEN_STRING && tok.Type != TOKEN_CHAR && tok.Contents == contents
+}
+
+func (v *cParser) accept(contents string) bool {
+	if v.is(contents) {
+		v.next()
+		return true
+	}
+	return false
+}
+
+func (v *cParser) expect(contents string) {
+	if !v.accept(contents) {
+		v.err("Expected `%s`, found `%s`", contents, v.peek(0).Contents)
+	}
+}
+
+func (v *cParser) expectIdentifier() string {
+	tok := v.next()
+	if tok.Type != TOKEN_IDENTIFIER {
+		v.err("Expected identifier, found `%s`", tok.Contents)
+	}
+	return tok.Contents
+}
+
+func (v *cParser) parse() {
+	for v.peek(0).Type != TOKEN_EOF {
+		switch {
+		case v.accept(";"):
+
+		case v.is("extern") && v.peek(1).Type == TOKEN_STRING:
+			v.next()
+			v.next()
+			if v.accept("{") {
+				v.externBlocks++
+			}
+
+		case v.is("}") && v.externBlocks > 0:
+			v.next()
+			v.externBlocks--
+
+		default:
+			v.parseDeclaration()
+		}
+	}
+}
+
+func (v *cParser) parseDeclaration() {
+	start := v.pos
+	line := v.peek(0).Line
+
+	defer func() {
+		if r := recover(); r != nil {
+			perr, ok := r.(parseError)
+			if !ok {
+				panic(r)
+			}
+
+			v.gen.warn(line, "Couldn't parse declaration: %s", perr.msg)
+			v.pos = start
+			v.skipDeclaration()
+		}
+	}()
+
+	spec := v.parseSpecifiers()
+	if spec == nil {
+		// only macros were left at the end of the header
+		if v.peek(0).Type == TOKEN_EOF {
+			return
+		}
+		v.err("Unexpected `%s`", v.peek(0).Contents)
+	}
+
+	if v.accept(";") {
+		return
+	}
+
+	for {
+		name, typ := v.parseDeclarator(spec.typ)
+
+		// function definitions, which are static inline in headers, have no
+		// symbol to bind to
+		if typ.Kind == C_FUNCTION && v.is("{") {
+			v.skipBlock()
+			return
+		}
+
+		switch {
+		case name == "":
+			v.err("Expected name in declaration")
+
+		case spec.isTypedef:
+			v.gen.addTypedef(name, typ)
+
+		case typ.Kind == C_FUNCTION:
+			if !spec.isStatic {
+				v.gen.addFunction(name, typ, line)
+			}
+
+		case !spec.isStatic:
+			v.gen.skip(line, name, "global variables aren't supported")
+		}
+
+		if v.accept("=") {
+			v.skipInitializer()
+		}
+
+		if !v.accept(",") {
+			break
+		}
+	}
+
+	v.expect(";")
+}
+
+// Skips the tokens of a declaration which couldn't be parsed
+func (v *cParser) skipDeclaration() {
+	depth := 0
+	for v.peek(0).Type != TOKEN_EOF {
+		tok := v.peek(0)
+		if tok.Type == TOKEN_PUNCT {
+			switch tok.Contents {
+			case "(", "[", "{":
+				depth++
+
+			case ")", "]":
+				depth--
+
+			case "}":
+				if depth == 0 {
+					// the end of an extern "C" block
+					return
+				}
+				depth--
+
+				// the end of a function body
+				if depth == 0 && v.peek(1).Contents != ";" && v.peek(1).Type != TOKEN_IDENTIFIER {
+					v.next()
+					return
+				}
+
+			case ";":
+				if depth == 0 {
+					v.next()
+					return
+				}
+			}
+		}
+		v.next()
+	}
+}
+
+// Skips a block of tokens in braces, like the body of a function
+func (v *cParser) skipBlock() {
+	v.expect("{")
+	for depth := 1; depth > 0; {
+		tok := v.next()
+		if tok.Type == TOKEN_EOF {
+			v.err("Unexpected end of file")
+		} else if tok.Type == TOKEN_PUNCT && tok.Contents == "{" {
+			depth++
+		} else if tok.Type == TOKEN_PUNCT && tok.Contents == "}" {
+			depth--
+		}
+	}
+}
+
+func (v *cParser) skipInitializer() {
+	depth := 0
+	for {
+		tok := v.peek(0)
+		if tok.Type == TOKEN_EOF {
+			v.err("Unexpected end of file")
+		}
+
+		if tok.Type == TOKEN_PUNCT {
+			switch tok.Contents {
+			case "(", "[", "{":
+				depth++
+			case ")", "]", "}":
+				depth--
+			case ",", ";":
+				if depth == 0 {
+					return
+				}
+			}
+		}
+		v.next()
+	}
+}
+
+// Skips attributes and similar extensions. Returns true if one of them was
+// __attribute__((packed)).
+func (v *cParser) skipAttributes() bool {
+	packed := false
+	for attributeKeywords[v.peek(0).Contents] && v.peek(0).Type == TOKEN_IDENTIFIER {
+		v.next()
+		if !v.is("(") {
+			continue
+		}
+
+		start := v.pos
+		v.skipParens()
+		for _, tok := range v.tokens[start:v.pos] {
+			if tok.Contents == "packed" || tok.Contents == "__packed__" {
+				packed = true
+			}
+		}
+	}
+	return packed
+}
+
+func (v *cParser) skipParens() {
+	v.expect("(")
+	for depth := 1; depth > 0; {
+		tok := v.next()
+		if tok.Type == TOKEN_EOF {
+			v.err("Unexpected end of file")
+		} else if tok.Type == TOKEN_PUNCT && tok.Contents == "(" {
+			depth++
+		} else if tok.Type == TOKEN_PUNCT && tok.Contents == ")" {
+			depth--
+		}
+	}
+}
+
+// Returns true if the identifier names a type
+func (v *cParser) isTypeName(name string) bool {
+	if primitiveKeywords[name] || ignoredKeywords[name] {
+		return true
+	}
+
+	switch name {
+	case "struct", "union", "enum", "typedef":
+		return true
+	}
+
+	_, ok := v.gen.typedefs[name]
+	_, builtin := builtinTypedefs[name]
+	return ok || builtin
+}
+
+// Parses the specifiers of a declaration, returns nil if there are none
+func (v *cParser) parseSpecifiers() *specifiers {
+	res := &specifiers{}
+
+	var words []string
+	start := v.pos
+
+	for {
+		tok := v.peek(0)
+		if tok.Type != TOKEN_IDENTIFIER {
+			break
+		}
+
+		if attributeKeywords[tok.Contents] {
+			v.skipAttributes()
+			continue
+		}
+
+		if ignoredKeywords[tok.Contents] {
+			v.next()
+			if tok.Contents == "_Atomic" && v.is("(") {
+				v.err("Atomic types aren't supported")
+			}
+			continue
+		}
+
+		switch tok.Contents {
+		case "typedef":
+			res.isTypedef = true
+			v.next()
+			continue
+
+		case "static":
+			res.isStatic = true
+			v.next()
+			continue
+
+		case "extern":
+			res.isExtern = true
+			v.next()
+			continue
+		}
+
+		if res.typ != nil {
+			break
+		}
+
+		if primitiveKeywords[tok.Contents] {
+			words = append(words, v.next().Contents)
+			continue
+		}
+
+		if words != nil {
+			break
+		}
+
+		switch tok.Contents {
+		case "struct", "union":
+			res.typ = v.parseRecord()
+		case "enum":
+			res.typ = v.parseEnum()
+		default:
+			// a macro which expands to nothing, like the ones used to
+			// start an extern "C" block, is followed by the declaration
+			if !v.isTypeName(tok.Contents) && v.startsSpecifiers(v.peek(1)) {
+				v.next()
+				continue
+			}
+
+			// so is one which expands to a qualifier, like the one in
+			// `z_const Bytef *next_in`, as a type can't be followed by two
+			// names
+			if !v.isTypeName(tok.Contents) && v.peek(1).Type == TOKEN_IDENTIFIER {
+				after := v.peek(2)
+				if after.Contents == "*" || (after.Type == TOKEN_IDENTIFIER && !attributeKeywords[after.Contents]) {
+					v.next()
+					continue
+				}
+			}
+
+			// a type is required before the declarator, so any other
+			// identifier is the name of a type
+			res.typ = &cType{Kind: C_TYPEDEF, Name: v.next().Contents}
+		}
+	}
+
+	if words != nil {
+		res.typ = primitiveType(v, words)
+	}
+
+	if res.typ == nil {
+		if v.pos != start && v.peek(0).Type != TOKEN_EOF {
+			v.err("Expected type, found `%s`", v.peek(0).Contents)
+		}
+		return nil
+	}
+	return res
+}
+
+// Returns true if the token can only start the specifiers of a declaration,
+// or ends the header. Qualifiers are left out, as they can also follow the
+// type.
+func (v *cParser) startsSpecifiers(tok *token) bool {
+	if tok.Type == TOKEN_EOF {
+		return true
+	}
+	if tok.Type != TOKEN_IDENTIFIER || ignoredKeywords[tok.Contents] {
+		return false
+	}
+
+	switch tok.Contents {
+	case "static", "extern":
+		return true
+	}
+	return v.isTypeName(tok.Contents)
+}
+
+// Turns a list of primitive type keywords into a type
+func primitiveType(v *cParser, words []string) *cType {
+	longs, signed, unsigned := 0, false, false
+	base := ""
+
+	for _, word := range words {
+		switch word {
+		case "long":
+			longs++
+		case "signed":
+			signed = true
+		case "unsigned":
+			unsigned = true
+		case "_Complex":
+			v.err("Complex types aren't supported")
+		default:
+			if base != "" && !(base == "int" || word == "int") {
+				v.err("Invalid type `%s`", strings.Join(words, " "))
+			}
+			if base == "" || base == "int" {
+				base = word
+			}
+		}
+	}
+
+	switch {
+	case longs == 1 && base == "double":
+		base = "long double"
+	case longs == 1:
+		base = "long"
+	case longs == 2:
+		base = "long long"
+	case base == "":
+		base = "int"
+	}
+
+	if unsigned {
+		if base == "char" || base == "short" || base == "int" || base == "long" || base == "long long" || base == "__int128" {
+			base = "unsigned " + base
+		} else {
+			v.err("Invalid type `%s`", strings.Join(words, " "))
+		}
+	} else if signed && base == "char" {
+		base = "signed char"
+	}
+
+	return &cType{Kind: C_PRIMITIVE, Name: base}
+}
+
+func (v *cParser) parseRecord() *cType {
+	isUnion := v.next().Contents == "union"
+	packed := v.skipAttributes()
+
+	tag := ""
+	if v.peek(0).Type == TOKEN_IDENTIFIER {
+		tag = v.next().Contents
+	}
+
+	rec := v.gen.record(tag, isUnion)
+	if !v.is("{") {
+		return &cType{Kind: C_RECORD, Record: rec}
+	}
+
+	// records are read twice when they are defined in both branches of a
+	// conditional, the first definition is used
+	if rec.Defined {
+		rec = &cRecord{Tag: tag, IsUnion: isUnion}
+	}
+
+	v.expect("{")
+	for !v.accept("}") {
+		v.parseField(rec)
+	}
+	packed = v.skipAttributes() || packed
+
+	rec.Defined = true
+	rec.Packed = packed
+	return &cType{Kind: C_RECORD, Record: rec}
+}
+
+func (v *cParser) parseField(rec *cRecord) {
+	spec := v.parseSpecifiers()
+	if spec == nil {
+		v.err("Expected field in `%s`, found `%s`", rec.Tag, v.peek(0).Contents)
+	}
+
+	// anonymous structs and unions
+	if v.accept(";") {
+		if spec.typ.Kind == C_RECORD && spec.typ.Record.Tag == "" {
+			name := fmt.Sprintf("anon%d", rec.anonFields)
+			rec.anonFields++
+			v.addField(rec, name, spec.typ)
+		}
+		return
+	}
+
+	for {
+		name, typ := v.parseDeclarator(spec.typ)
+		if v.accept(":") {
+			rec.Bitfields = true
+			v.parseConstExpr()
+		}
+		v.skipAttributes()
+
+		if name != "" {
+			v.addField(rec, name, typ)
+		}
+
+		if !v.accept(",") {
+			break
+		}
+	}
+	v.expect(";")
+}
+
+func (v *cParser) addField(rec *cRecord, name string, typ *cType) {
+	// records without a name are named after the field
+	inner := typ
+	for inner.Kind == C_ARRAY || inner.Kind == C_POINTER {
+		inner = inner.Elem
+	}
+	if inner.Kind == C_RECORD && inner.Record.Tag == "" && inner.Record.parent == nil {
+		inner.Record.parent, inner.Record.field = rec, name
+	}
+
+	rec.Fields = append(rec.Fields, &cVariable{Name: name, Type: typ})
+}
+
+func (v *cParser) parseEnum() *cType {
+	v.next()
+	v.skipAttributes()
+
+	tag := ""
+	if v.peek(0).Type == TOKEN_IDENTIFIER {
+		tag = v.next().Contents
+	}
+
+	enum := v.gen.enum(tag)
+	if !v.is("{") {
+		return &cType{Kind: C_ENUM, Enum: enum}
+	}
+
+	if enum.Defined {
+		enum = &cEnum{Tag: tag}
+	} else {
+		v.gen.decls = append(v.gen.decls, enum)
+	}
+
+	v.expect("{")
+	value := int64(0)
+	for !v.is("}") {
+		name := v.expectIdentifier()
+		v.skipAttributes()
+
+		if v.accept("=") {
+			value = v.parseConstExpr().Value
+		}
+
+		enum.Members = append(enum.Members, &cEnumMember{Name: name, Value: value})
+		if _, ok := v.gen.constants[name]; !ok {
+			v.gen.constants[name] = constValue{Value: value}
+		}
+		value++
+
+		if !v.accept(",") {
+			break
+		}
+	}
+	v.expect("}")
+	v.skipAttributes()
+
+	enum.Defined = true
+	return &cType{Kind: C_ENUM, Enum: enum}
+}
+
+// Parses a declarator, which may be abstract, for the base type typ. Returns
+// the declared name and type.
+func (v *cParser) parseDeclarator(typ *cType) (string, *cType) {
+	for v.accept("*") {
+		typ = pointerTo(typ)
+		for ignoredKeywords[v.peek(0).Contents] || attributeKeywords[v.peek(0).Contents] {
+			if attributeKeywords[v.peek(0).Contents] {
+				v.skipAttributes()
+			} else {
+				v.next()
+			}
+		}
+	}
+
+	// in a nested declarator like the one in `int (*fn)(int)`, the type of
+	// the inner part is only known once the suffixes after it are parsed, so
+	// the inner part is given a placeholder which is filled in later
+	if v.is("(") && v.isNestedDeclarator() {
+		v.next()
+		placeholder := &cType{}
+		name, inner := v.parseDeclarator(placeholder)
+		v.expect(")")
+
+		*placeholder = *v.parseSuffixes(typ)
+		v.skipMacros()
+		return name, inner
+	}
+
+	name := ""
+	if v.peek(0).Type == TOKEN_IDENTIFIER && !attributeKeywords[v.peek(0).Contents] {
+		name = v.next().Contents
+	}
+	v.skipAttributes()
+
+	typ = v.parseSuffixes(typ)
+	v.skipMacros()
+	return name, typ
+}
+
+// Skips attributes after a declarator. No identifier can follow a declarator
+// in C, so any identifier is taken to be a macro for an attribute, like the
+// `__THROW` or `__nonnull ((1))` of the system headers.
+func (v *cParser) skipMacros() {
+	for v.peek(0).Type == TOKEN_IDENTIFIER {
+		if attributeKeywords[v.peek(0).Contents] {
+			v.skipAttributes()
+			continue
+		}
+
+		v.next()
+		if v.is("(") {
+			v.skipParens()
+		}
+	}
+}
+
+func (v *cParser) isNestedDeclarator() bool {
+	next := v.peek(1)
+	if next.Type == TOKEN_IDENTIFIER {
+		return !v.isTypeName(next.Contents) && !attributeKeywords[next.Contents]
+	}
+	return next.Contents == "*" || next.Contents == "("
+}
+
+// Parses the array and function suffixes of a declarator
+func (v *cParser) parseSuffixes(typ *cType) *cType {
+	var suffixes []*cType
+	for {
+		if v.accept("[") {
+			array := &cType{Kind: C_ARRAY, Length: -1}
+			for ignoredKeywords[v.peek(0).Contents] || v.is("static") {
+				v.next()
+			}
+			if !v.is("]") {
+				array.Length = v.parseArrayLength()
+			}
+			v.expect("]")
+			suffixes = append(suffixes, array)
+		} else if v.is("(") {
+			suffixes = append(suffixes, v.parseParameters())
+		} else {
+			break
+		}
+	}
+
+	for idx := len(suffixes) - 1; idx >= 0; idx-- {
+		suffixes[idx].Elem = typ
+		typ = suffixes[idx]
+	}
+	return typ
+}
+
+// Parses the length of an array up to the closing bracket. A length which
+// isn't a constant, like one using sizeof, is left unknown.
+func (v *cParser) parseArrayLength() int {
+	start := v.pos
+	for depth := 0; depth > 0 || !v.is("]"); {
+		tok := v.next()
+		if tok.Type == TOKEN_EOF {
+			v.err("Unexpected end of file")
+		} else if tok.Contents == "[" {
+			depth++
+		} else if tok.Contents == "]" {
+			depth--
+		}
+	}
+
+	tokens := append(v.tokens[start:v.pos:v.pos], &token{Type: TOKEN_EOF, Line: v.peek(0).Line})
+	length, ok := v.gen.evalConstant(tokens)
+	if !ok {
+		return -1
+	}
+	if length.Value < 0 {
+		v.err("Negative array length")
+	}
+	return int(length.Value)
+}
+
+func (v *cParser) parseParameters() *cType {
+	fn := &cType{Kind: C_FUNCTION}
+	v.expect("(")
+
+	if v.is("void") && v.peek(1).Contents == ")" {
+		v.next()
+	}
+
+	for !v.accept(")") {
+		if v.accept("...") {
+			fn.IsVariadic = true
+			v.expect(")")
+			break
+		}
+
+		spec := v.parseSpecifiers()
+		if spec == nil {
+			v.err("Expected parameter, found `%s`", v.peek(0).Contents)
+		}
+
+		name, typ := v.parseDeclarator(spec.typ)
+		fn.Params = append(fn.Params, &cVariable{Name: name, Type: v.gen.adjustParameter(typ)})
+
+		if !v.accept(",") {
+			v.expect(")")
+			break
+		}
+	}
+
+	return fn
+}
+
+// A constant integer, and whether it has an unsigned type in C
+type constValue struct {
+	Value    int64
+	Unsigned bool
+}
+
+// Binary operators by precedence, from lowest to highest
+var binaryOperators = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<", ">", "<=", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (v *cParser) parseConstExpr() constValue {
+	cond := v.parseBinaryExpr(0)
+	if !v.accept("?") {
+		return cond
+	}
+
+	then := v.parseConstExpr()
+	v.expect(":")
+	els := v.parseConstExpr()
+	if cond.Value != 0 {
+		return then
+	}
+	return els
+}
+
+func (v *cParser) parseBinaryExpr(prec int) constValue {
+	if prec == len(binaryOperators) {
+		return v.parseUnaryExpr()
+	}
+
+	lhs := v.parseBinaryExpr(prec + 1)
+	for {
+		op := ""
+		for _, candidate := range binaryOperators[prec] {
+			if v.peek(0).Type == TOKEN_PUNCT && v.is(candidate) {
+				op = candidate
+			}
+		}
+		if op == "" {
+			return lhs
+		}
+		v.next()
+
+		rhs := v.parseBinaryExpr(prec + 1)
+		lhs = v.evalBinary(op, lhs, rhs)
+	}
+}
+
+func (v *cParser) evalBinary(op string, lhs, rhs constValue) constValue {
+	unsigned := lhs.Unsigned || rhs.Unsigned
+	a, b := lhs.Value, rhs.Value
+
+	boolValue := func(cond bool) constValue {
+		if cond {
+			return constValue{Value: 1}
+		}
+		return constValue{Value: 0}
+	}
+
+	switch op {
+	case "||":
+		return boolValue(a != 0 || b != 0)
+	case "&&":
+		return boolValue(a != 0 && b != 0)
+	case "==":
+		return boolValue(a == b)
+	case "!=":
+		return boolValue(a != b)
+	case "<", ">", "<=", ">=":
+		cmp := 0
+		if unsigned {
+			if uint64(a) < uint64(b) {
+				cmp = -1
+			} else if uint64(a) > uint64(b) {
+				cmp = 1
+			}
+		} else if a < b {
+			cmp = -1
+		} else if a > b {
+			cmp = 1
+		}
+
+		switch op {
+		case "<":
+			return boolValue(cmp < 0)
+		case ">":
+			return boolValue(cmp > 0)
+		case "<=":
+			return boolValue(cmp <= 0)
+		default:
+			return boolValue(cmp >= 0)
+		}
+	}
+
+	res := constValue{Unsigned: unsigned}
+	switch op {
+	case "|":
+		res.Value = a | b
+	case "^":
+		res.Value = a ^ b
+	case "&":
+		res.Value = a & b
+	case "<<":
+		res.Value = a << uint64(b)
+		res.Unsigned = lhs.Unsigned
+	case ">>":
+		if lhs.Unsigned {
+			res.Value = int64(uint64(a) >> uint64(b))
+		} else {
+			res.Value = a >> uint64(b)
+		}
+		res.Unsigned = lhs.Unsigned
+	case "+":
+		res.Value = a + b
+	case "-":
+		res.Value = a - b
+	case "*":
+		res.Value = a * b
+	case "/", "%":
+		if b == 0 {
+			v.err("Division by zero in constant expression")
+		}
+		if unsigned {
+			if op == "/" {
+				res.Value = int64(uint64(a) / uint64(b))
+			} else {
+				res.Value = int64(uint64(a) % uint64(b))
+			}
+		} else if op == "/" {
+			res.Value = a / b
+		} else {
+			res.Value = a % b
+		}
+	}
+	return res
+}
+
+func (v *cParser) parseUnaryExpr() constValue {
+	tok := v.peek(0)
+
+	if tok.Type == TOKEN_PUNCT {
+		switch tok.Contents {
+		case "-":
+			v.next()
+			val := v.parseUnaryExpr()
+			val.Value = -val.Value
+			return val
+		case "+":
+			v.next()
+			return v.parseUnaryExpr()
+		case "~":
+			v.next()
+			val := v.parseUnaryExpr()
+			val.Value = ^val.Value
+			return val
+		case "!":
+			v.next()
+			if v.parseUnaryExpr().Value == 0 {
+				return constValue{Value: 1}
+			}
+			return constValue{Value: 0}
+
+		case "(":
+			// a cast, which only changes the signedness of the value
+			if next := v.peek(1); next.Type == TOKEN_IDENTIFIER && v.isTypeName(next.Contents) {
+				v.next()
+				spec := v.parseSpecifiers()
+				_, typ := v.parseDeclarator(spec.typ)
+				v.expect(")")
+
+				val := v.parseUnaryExpr()
+				val.Unsigned = v.gen.isUnsigned(typ)
+				return val
+			}
+
+			v.next()
+			val := v.parseConstExpr()
+			v.expect(")")
+			return val
+		}
+	}
+
+	v.next()
+	switch tok.Type {
+	case TOKEN_NUMBER:
+		return v.parseNumber(tok.Contents)
+
+	case TOKEN_CHAR:
+		quoted := tok.Contents[strings.IndexRune(tok.Contents, '\''):]
+		value, _, tail, err := strconv.UnquoteChar(quoted[1:len(quoted)-1], '\'')
+		if err != nil || tail != "" {
+			v.err("Invalid character literal %s", tok.Contents)
+		}
+		return constValue{Value: int64(value)}
+
+	case TOKEN_IDENTIFIER:
+		if val, ok := v.gen.constants[tok.Contents]; ok {
+			return val
+		}
+		v.err("`%s` is not a constant", tok.Contents)
+	}
+
+	v.err("Expected constant expression, found `%s`", tok.Contents)
+	return constValue{}
+}
+
+func (v *cParser) parseNumber(lit string) constValue {
+	digits := strings.TrimRight(lit, "uUlL")
+	suffix := strings.ToLower(lit[len(digits):])
+
+	if digits == "" || strings.ContainsAny(digits, ".") ||
+		(!strings.HasPrefix(digits, "0x") && !strings.HasPrefix(digits, "0X") && strings.ContainsAny(digits, "eEfF")) {
+		v.err("Expected integer, found `%s`", lit)
+	}
+
+	value, err := strconv.ParseUint(digits, 0, 64)
+	if err != nil {
+		v.err("Invalid integer `%s`", lit)
+	}
+
+	// as in C, hexadecimal and octal literals have an unsigned type if the
+	// value doesn't fit the signed type
+	decimal := digits == "0" || digits[0] != '0'
+	res := constValue{Value: int64(value), Unsigned: strings.Contains(suffix, "u")}
+	if !res.Unsigned && value > math.MaxInt64 {
+		res.Unsigned = true
+	} else if !res.Unsigned && !decimal && suffix == "" && value > math.MaxInt32 && value <= math.MaxUint32 {
+		res.Unsigned = true
+	}
+	return res
+}
@@ -0,0 +1,216 @@
+package bindgen
+
+import (
+	"fmt"
+)
+
+type cTypeKind int
+
+const (
+	C_PRIMITIVE cTypeKind = iota
+	C_POINTER
+	C_ARRAY
+	C_FUNCTION
+	C_RECORD
+	C_ENUM
+	C_TYPEDEF
+)
+
+type cType struct {
+	Kind cTypeKind
+
+	Name string // primitive types and typedef names
+
+	Elem   *cType // addressee of pointers, member of arrays, return type of functions
+	Length int    // length of arrays, -1 if not given
+
+	Params     []*cVariable
+	IsVariadic bool
+
+	Record *cRecord
+	Enum   *cEnum
+}
+
+type cVariable struct {
+	Name string
+	Type *cType
+}
+
+// A struct or union
+type cRecord struct {
+	Tag     string
+	IsUnion bool
+	Fields  []*cVariable
+
+	Defined   bool
+	Packed    bool
+	Bitfields bool // records with bitfields are left opaque
+
+	// records without tag or typedef are named after the field they are
+	// the type of
+	parent *cRecord
+	field  string
+
+	anonFields int
+
+	name string // name of the type in Ark
+}
+
+type cEnum struct {
+	Tag     string
+	Members []*cEnumMember
+
+	Defined bool
+
+	name string
+}
+
+type cEnumMember struct {
+	Name  string
+	Value int64
+}
+
+func pointerTo(typ *cType) *cType {
+	return &cType{Kind: C_POINTER, Elem: typ}
+}
+
+// The Ark types of C primitive types. Where the size of the type is fixed by
+// the C scope of the compiler, the type from the C scope is used.
+var primitiveArkTypes = map[string]string{
+	"char":               "u8",
+	"signed char":        "s8",
+	"unsigned char":      "u8",
+	"short":              "s16",
+	"unsigned short":     "u16",
+	"int":                "C::int",
+	"unsigned int":       "C::uint",
+	"long":               "int",
+	"unsigned long":      "uint",
+	"long long":          "s64",
+	"unsigned long long": "u64",
+	"__int128":           "s128",
+	"unsigned __int128":  "u128",
+	"float":              "f32",
+	"double":             "f64",
+	"_Bool":              "bool",
+}
+
+type builtinTypedef struct {
+	C   string // the primitive type it is defined as
+	Ark string
+}
+
+// Typedefs from the standard headers, which are usually included rather than
+// defined in the header itself
+var builtinTypedefs = map[string]builtinTypedef{
+	"bool":      {"_Bool", "bool"},
+	"size_t":    {"unsigned long", "uint"},
+	"ssize_t":   {"long", "int"},
+	"ptrdiff_t": {"long", "int"},
+	"intptr_t":  {"long", "int"},
+	"uintptr_t": {"unsigned long", "uint"},
+	"wchar_t":   {"int", "s32"},
+	"char16_t":  {"unsigned short", "u16"},
+	"char32_t":  {"unsigned int", "u32"},
+	"int8_t":    {"signed char", "s8"},
+	"int16_t":   {"short", "s16"},
+	"int32_t":   {"int", "s32"},
+	"int64_t":   {"long", "s64"},
+	"uint8_t":   {"unsigned char", "u8"},
+	"uint16_t":  {"unsigned short", "u16"},
+	"uint32_t":  {"unsigned int", "u32"},
+	"uint64_t":  {"unsigned long", "u64"},
+}
+
+// Size and alignment of primitive types, assuming the LP64 data model
+var primitiveLayouts = map[string][2]int{
+	"char":               {1, 1},
+	"signed char":        {1, 1},
+	"unsigned char":      {1, 1},
+	"short":              {2, 2},
+	"unsigned short":     {2, 2},
+	"int":                {4, 4},
+	"unsigned int":       {4, 4},
+	"long":               {8, 8},
+	"unsigned long":      {8, 8},
+	"long long":          {8, 8},
+	"unsigned long long": {8, 8},
+	"__int128":           {16, 16},
+	"unsigned __int128":  {16, 16},
+	"float":              {4, 4},
+	"double":             {8, 8},
+	"long double":        {16, 16},
+	"_Bool":              {1, 1},
+}
+
+// Returns the size and alignment of the type, the same way a C compiler
+// would lay it out
+func (v *Bindgen) layout(typ *cType) (int, int, error) {
+	switch typ.Kind {
+	case C_PRIMITIVE:
+		if layout, ok := primitiveLayouts[typ.Name]; ok {
+			return layout[0], layout[1], nil
+		}
+		return 0, 0, fmt.Errorf("type `%s` has no size", typ.Name)
+
+	case C_POINTER:
+		return 8, 8, nil
+
+	case C_ARRAY:
+		if typ.Length < 0 {
+			return 0, 0, fmt.Errorf("array has no length")
+		}
+		size, align, err := v.layout(typ.Elem)
+		return size * typ.Length, align, err
+
+	case C_ENUM:
+		return 4, 4, nil
+
+	case C_TYPEDEF:
+		if actual, ok := v.typedefs[typ.Name]; ok {
+			return v.layout(actual)
+		}
+		if builtin, ok := builtinTypedefs[typ.Name]; ok {
+			return v.layout(&cType{Kind: C_PRIMITIVE, Name: builtin.C})
+		}
+		return 0, 0, fmt.Errorf("unknown type `%s`", typ.Name)
+
+	case C_RECORD:
+		rec := typ.Record
+		if !rec.Defined {
+			return 0, 0, fmt.Errorf("`%s` is incomplete", v.recordName(rec))
+		}
+		if rec.Bitfields {
+			return 0, 0, fmt.Errorf("`%s` has bitfields", v.recordName(rec))
+		}
+
+		size, align := 0, 1
+		for _, field := range rec.Fields {
+			fieldSize, fieldAlign, err := v.layout(field.Type)
+			if err != nil {
+				return 0, 0, err
+			}
+			if rec.Packed {
+				fieldAlign = 1
+			}
+
+			if rec.IsUnion {
+				if fieldSize > size {
+					size = fieldSize
+				}
+			} else {
+				size = alignTo(size, fieldAlign) + fieldSize
+			}
+			if fieldAlign > align {
+				align = fieldAlign
+			}
+		}
+		return alignTo(size, align), align, nil
+	}
+
+	return 0, 0, fmt.Errorf("functions have no size")
+}
+
+func alignTo(offset, align int) int {
+	return (offset + align - 1) / align * align
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
 	"syscall"
@@ -23,6 +24,32 @@ type Job struct {
 	CompilerError, RunError   int
 	Input                     string
 	CompilerOutput, RunOutput string
+
+	// A shell command run instead of the compiled program, for outputs that
+	// aren't executables. The output of the compiler is in $ARK_OUTPUT, and
+	// the directory of the test in $ARK_TEST_DIR.
+	RunCommand string
+
+	// The operating systems and architectures the test can run on, as named
+	// by GOOS and GOARCH. Empty means all of them.
+	OS, Arch []string
+}
+
+// Whether the test can run on the machine running the tests
+func (v Job) Supported() bool {
+	return matchesPlatform(v.OS, runtime.GOOS) && matchesPlatform(v.Arch, runtime.GOARCH)
+}
+
+func matchesPlatform(supported []string, platform string) bool {
+	if len(supported) == 0 {
+		return true
+	}
+	for _, name := range supported {
+		if name == platform {
+			return true
+		}
+	}
+	return false
 }
 
 type Result struct {
@@ -108,7 +135,13 @@ func realmain() int {
 	// Do jobs
 	outBuf := new(bytes.Buffer)
 	var results []Result
+	var skipped []string
 	for _, job := range jobs {
+		if !job.Supported() {
+			skipped = append(skipped, job.Name)
+			continue
+		}
+
 		outpath := fmt.Sprintf("%s_test", job.Sourcefile)
 
 		// Compile the test program
@@ -144,7 +177,13 @@ func realmain() int {
 			fmt.Printf("\nRunning test: %s\n", job.Name)
 		}
 
-		res.RunError, err = runCommand(outBuf, fmt.Sprintf("./%s", outpath), job.RunArgs...)
+		if job.RunCommand != "" {
+			os.Setenv("ARK_OUTPUT", outpath)
+			os.Setenv("ARK_TEST_DIR", filepath.Dir(job.Sourcefile))
+			res.RunError, err = runCommand(outBuf, "sh", "-c", job.RunCommand)
+		} else {
+			res.RunError, err = runCommand(outBuf, fmt.Sprintf("./%s", outpath), job.RunArgs...)
+		}
 		if err != nil {
 			fmt.Printf("Error while running test:\n%s\n", err.Error())
 			return 1
@@ -155,8 +194,8 @@ func realmain() int {
 			fmt.Printf("\n")
 		}
 
-		// Remove test executable
-		if err := os.Remove(outpath); err != nil {
+		// Remove test executable, which a run command may have done already
+		if err := os.Remove(outpath); err != nil && !os.IsNotExist(err) {
 			fmt.Printf("Error while removing test executable:\n%s\n", err.Error())
 			return 1
 		}
@@ -223,6 +262,9 @@ func realmain() int {
 	}
 
 	fmt.Printf("\nTotal: %d / %d tests ran succesfully\n", numSucceses, len(results))
+	if len(skipped) > 0 {
+		fmt.Printf("Skipped on %s/%s: %s\n", runtime.GOOS, runtime.GOARCH, strings.Join(skipped, ", "))
+	}
 	if numSucceses < len(results) {
 		return 1
 	}
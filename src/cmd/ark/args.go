@@ -1,6 +1,9 @@
 package main
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/ark-lang/ark/src/codegen/LLVMCodegen"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
@@ -21,6 +24,40 @@ func newInputList(s kingpin.Settings) (target *[]string) {
 	return
 }
 
+// A bool flag that remembers whether it was passed, so its default can depend
+// on other flags
+type optionalBool struct {
+	value, set bool
+}
+
+func (b *optionalBool) String() string   { return strconv.FormatBool(b.value) }
+func (b *optionalBool) IsBoolFlag() bool { return true }
+
+func (b *optionalBool) Set(value string) error {
+	val, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	b.value, b.set = val, true
+	return nil
+}
+
+// Returns the value of the flag if it was passed, def otherwise
+func (b *optionalBool) Or(def bool) bool {
+	if b.set {
+		return b.value
+	}
+	return def
+}
+
+func newOptionalBool(s kingpin.Settings) (target *optionalBool) {
+	target = new(optionalBool)
+	s.SetValue(target)
+	return
+}
+
+const defaultCacheDir = ".ark-cache"
+
 var (
 	app = kingpin.New("ark", "Compiler for the Ark programming language.").Version(VERSION).Author(AUTHOR)
 
@@ -32,16 +69,61 @@ var (
 	buildSearchpaths = buildCom.Flag("searchpaths", "Paths to search for used modules if not found in base directory").Short('I').Strings()
 	buildInputs      = newInputList(buildCom.Arg("input", "Ark source files."))
 	buildCodegen     = buildCom.Flag("codegen", "Codegen backend to use").Default("llvm").Enum("none", "llvm")
-	buildOutputType  = buildCom.Flag("output-type", "The format to produce after code generation").Default("executable").Enum("executable", "assembly", "object", "llvm-ir")
-	buildOptLevel    = buildCom.Flag("opt-level", "LLVM optimization level").Short('O').Default("0").Int()
+	buildOutputType  = buildCom.Flag("output-type", "The format to produce after code generation").Default("executable").Enum("executable", "assembly", "object", "llvm-ir", "bitcode", "static-lib", "shared-lib")
+	buildOptLevel    = buildCom.Flag("opt-level", "LLVM optimization level, s or z to optimize for size").Short('O').Default("0").Enum("0", "1", "2", "3", "s", "z")
+	buildPasses      = buildCom.Flag("passes", "Comma separated list of LLVM passes to run instead of the ones of the optimization level").String()
+	buildDebugInfo   = buildCom.Flag("debug-info", "Emit DWARF debug information").Short('g').Bool()
+	buildTarget      = buildCom.Flag("target", "Target triple to generate code for, defaults to the host").String()
+	buildCPU         = buildCom.Flag("cpu", "Target CPU to generate code for").String()
+	buildFeatures    = buildCom.Flag("features", "Comma separated list of target features to enable or disable, e.g. +sse4.1,-avx").String()
+	buildOverflow    = newOptionalBool(buildCom.Flag("overflow-checks", "Panic on integer overflow and division by zero, on by default at -O0"))
+	buildChecks      = buildCom.Flag("runtime-checks", "Additional checks at runtime, null to panic when a null pointer is dereferenced, can be repeated").Enums("null")
+	buildJobs        = buildCom.Flag("jobs", "Number of modules to generate code for concurrently").Short('j').Default("1").Int()
+	buildLTO         = buildCom.Flag("lto", "Link all modules into one before optimizing, so calls across modules can be inlined. Has no effect at -O0 unless --passes is given").Bool()
+	buildCacheDir    = buildCom.Flag("cache-dir", "Directory to cache the objects of modules in, caching is disabled if empty").Default(defaultCacheDir).String()
+	buildEmitIR      = buildCom.Flag("emit-ir-after", "Write the LLVM IR of every module after this phase next to the output, can be repeated").Enums("codegen", "optimization")
+	buildHeader      = buildCom.Flag("emit-header", "Write a C header declaring the public functions with the [c] attribute to this file").String()
 	buildOwnership   = buildCom.Flag("ownership", "Do ownership checks").Bool()
 	ignoreUnused     = buildCom.Flag("unused", "Do not error on unused declarations").Bool()
 
+	cleanCom      = app.Command("clean", "Remove the cache of module objects.")
+	cleanCacheDir = cleanCom.Flag("cache-dir", "Cache directory to remove").Default(defaultCacheDir).String()
+
+	bindgenCom    = app.Command("bindgen", "Generate Ark declarations from a C header.")
+	bindgenOutput = bindgenCom.Flag("output", "Ark file to write, defaults to the name of the header with the .ark extension").Short('o').String()
+	bindgenInput  = bindgenCom.Arg("header", "C header file.").Required().String()
+
 	docgenCom    = app.Command("docgen", "Generate documentation.")
 	docgenDir    = docgenCom.Flag("dir", "Directory to place generated docs in.").Default("docgen").String()
 	docgenInputs = newInputList(docgenCom.Arg("input", "Ark source files."))
 )
 
+// Returns the optimization and size levels of an optimization level flag. The
+// size levels optimize like -O2, as in clang.
+func parseOptLevel(name string) (optLevel int, sizeLevel int) {
+	switch name {
+	case "s":
+		return 2, 1
+	case "z":
+		return 2, 2
+	default:
+		optLevel, _ = strconv.Atoi(name)
+		return optLevel, 0
+	}
+}
+
+// Splits the list of passes, an empty list runs the passes of the
+// optimization level
+func parsePasses(list string) []string {
+	var passes []string
+	for _, pass := range strings.Split(list, ",") {
+		if pass = strings.TrimSpace(pass); pass != "" {
+			passes = append(passes, pass)
+		}
+	}
+	return passes
+}
+
 func parseOutputType(name string) LLVMCodegen.OutputType {
 	switch name {
 	case "executable":
@@ -52,7 +134,38 @@ func parseOutputType(name string) LLVMCodegen.OutputType {
 		return LLVMCodegen.OUTPUT_OBJECT
 	case "llvm-ir":
 		return LLVMCodegen.OUTPUT_LLVM_IR
+	case "bitcode":
+		return LLVMCodegen.OUTPUT_LLVM_BITCODE
+	case "static-lib":
+		return LLVMCodegen.OUTPUT_STATIC_LIB
+	case "shared-lib":
+		return LLVMCodegen.OUTPUT_SHARED_LIB
 	default:
 		panic("unimplemented output type")
 	}
 }
+
+func parsePhases(names []string) []LLVMCodegen.Phase {
+	var phases []LLVMCodegen.Phase
+	for _, name := range names {
+		switch name {
+		case "codegen":
+			phases = append(phases, LLVMCodegen.PHASE_CODEGEN)
+		case "optimization":
+			phases = append(phases, LLVMCodegen.PHASE_OPTIMIZATION)
+		default:
+			panic("unimplemented phase")
+		}
+	}
+	return phases
+}
+
+// Whether the check was enabled with --runtime-checks
+func runtimeCheck(name string) bool {
+	for _, check := range *buildChecks {
+		if check == name {
+			return true
+		}
+	}
+	return false
+}
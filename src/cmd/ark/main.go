@@ -1,7 +1,10 @@
 package main
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -10,6 +13,7 @@ import (
 
 	"gopkg.in/alecthomas/kingpin.v2"
 
+	"github.com/ark-lang/ark/src/bindgen"
 	"github.com/ark-lang/ark/src/codegen"
 	"github.com/ark-lang/ark/src/codegen/LLVMCodegen"
 	"github.com/ark-lang/ark/src/doc"
@@ -42,10 +46,18 @@ func main() {
 
 		// build the files
 		outputType := parseOutputType(*buildOutputType)
-		build(*buildInputs, *buildOutput, *buildCodegen, outputType, *buildOptLevel)
+		optLevel, sizeLevel := parseOptLevel(*buildOptLevel)
+		build(*buildInputs, *buildOutput, *buildCodegen, outputType, optLevel, sizeLevel)
 
 		printFinishedMessage(startTime, buildCom.FullCommand(), len(*buildInputs))
 
+	case cleanCom.FullCommand():
+		clean(*cleanCacheDir)
+
+	case bindgenCom.FullCommand():
+		generateBindings(*bindgenInput, *bindgenOutput)
+		printFinishedMessage(startTime, bindgenCom.FullCommand(), 1)
+
 	case docgenCom.FullCommand():
 		docgen(*docgenInputs, *docgenDir)
 		printFinishedMessage(startTime, docgenCom.FullCommand(), len(*docgenInputs))
@@ -65,7 +77,7 @@ func setupErr(err string, stuff ...interface{}) {
 	os.Exit(util.EXIT_FAILURE_SETUP)
 }
 
-func parseFiles(inputs []string) ([]*parser.Module, *parser.ModuleLookup) {
+func parseFiles(inputs []string) ([]*parser.Module, *parser.ModuleLookup, *parser.DependencyGraph) {
 	if len(inputs) != 1 {
 		setupErr("Please specify only one file or module to build")
 	}
@@ -197,7 +209,7 @@ func parseFiles(inputs []string) ([]*parser.Module, *parser.ModuleLookup) {
 		}
 	})
 
-	return modules, moduleLookup
+	return modules, moduleLookup, depGraph
 }
 
 func findModuleDir(searchPaths []string, modulePath string) (fi os.FileInfo, path string) {
@@ -216,8 +228,8 @@ func findModuleDir(searchPaths []string, modulePath string) (fi os.FileInfo, pat
 	return nil, ""
 }
 
-func build(files []string, outputFile string, cg string, outputType LLVMCodegen.OutputType, optLevel int) {
-	constructedModules, moduleLookup := parseFiles(files)
+func build(files []string, outputFile string, cg string, outputType LLVMCodegen.OutputType, optLevel int, sizeLevel int) {
+	constructedModules, moduleLookup, depGraph := parseFiles(files)
 
 	// resolve
 
@@ -236,7 +248,7 @@ func build(files []string, outputFile string, cg string, outputType LLVMCodegen.
 
 	// and here we check if we should
 	// bother continuing any further...
-	if !hasMainFunc {
+	if !hasMainFunc && !outputType.IsLibrary() {
 		log.Error("main", util.Red("error: ")+"main function not found\n")
 		os.Exit(1)
 	}
@@ -274,12 +286,41 @@ func build(files []string, outputFile string, cg string, outputType LLVMCodegen.
 	if cg != "none" {
 		var gen codegen.Codegen
 
+		cacheDir := *buildCacheDir
+		buildID, err := compilerBuildID()
+		if err != nil && cacheDir != "" {
+			log.Warning("main", util.Yellow("warning: ")+"Not caching module objects, couldn't identify the compiler: %s\n", err.Error())
+			cacheDir = ""
+		}
+
 		switch cg {
 		case "llvm":
+			// linking the modules only pays off if the result is optimized
+			if *buildLTO && optLevel == 0 && *buildPasses == "" {
+				log.Warning("main", util.Yellow("warning: ")+"--lto has no effect at -O0, pass -O or --passes to optimize the linked program\n")
+			}
+
 			gen = &LLVMCodegen.Codegen{
 				OutputName: outputFile,
 				OutputType: outputType,
 				OptLevel:   optLevel,
+				SizeLevel:  sizeLevel,
+				Passes:     parsePasses(*buildPasses),
+				DebugInfo:  *buildDebugInfo,
+				Target:     *buildTarget,
+				CPU:        *buildCPU,
+				Features:   *buildFeatures,
+
+				OverflowChecks: buildOverflow.Or(optLevel == 0),
+				NullChecks:     runtimeCheck("null"),
+				LTO:            *buildLTO,
+				Jobs:           *buildJobs,
+				HeaderFile:     *buildHeader,
+				EmitIRAfter:    parsePhases(*buildEmitIR),
+
+				CacheDir:     cacheDir,
+				Version:      VERSION + " " + buildID,
+				Dependencies: depGraph,
 			}
 		default:
 			log.Error("main", util.Red("error: ")+"Invalid backend choice `"+cg+"`")
@@ -293,8 +334,49 @@ func build(files []string, outputFile string, cg string, outputType LLVMCodegen.
 
 }
 
+// Identifies the build of the compiler by the hash of its executable, so the
+// objects cached by other builds of the same version aren't used
+func compilerBuildID() (string, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha1.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Removes the cache of module objects
+func clean(cacheDir string) {
+	if err := os.RemoveAll(cacheDir); err != nil {
+		setupErr("Couldn't remove cache directory %s: %s", cacheDir, err.Error())
+	}
+}
+
+func generateBindings(input string, output string) {
+	if output == "" {
+		output = strings.TrimSuffix(filepath.Base(input), filepath.Ext(input)) + ".ark"
+	}
+
+	gen := &bindgen.Bindgen{
+		Input:  input,
+		Output: output,
+	}
+
+	gen.Generate()
+}
+
 func docgen(input []string, dir string) {
-	constructedModules, _ := parseFiles(input)
+	constructedModules, _, _ := parseFiles(input)
 
 	gen := &doc.Docgen{
 		Input: constructedModules,
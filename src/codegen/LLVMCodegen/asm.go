@@ -0,0 +1,45 @@
+package LLVMCodegen
+
+import (
+	"strings"
+
+	"github.com/ark-lang/ark/src/parser"
+	"llvm.org/llvm/bindings/go/llvm"
+)
+
+func (v *Codegen) genAsmStat(n *parser.AsmStat) {
+	v.genAsmExpr(n.Asm)
+}
+
+func (v *Codegen) genAsmExpr(n *parser.AsmExpr) llvm.Value {
+	var constraints []string
+	for _, output := range n.Outputs {
+		constraints = append(constraints, output.Constraint)
+	}
+
+	args := make([]llvm.Value, len(n.Inputs))
+	argTypes := make([]llvm.Type, len(n.Inputs))
+	for idx, input := range n.Inputs {
+		value := input.Value
+
+		// immediates are encoded into the instruction
+		if input.Constraint == "i" || input.Constraint == "n" {
+			value = v.evaluateConstant(value)
+		}
+
+		args[idx] = v.genExpr(value)
+		argTypes[idx] = args[idx].Type()
+		constraints = append(constraints, input.Constraint)
+	}
+
+	for _, clobber := range n.Clobbers {
+		constraints = append(constraints, "~{"+clobber+"}")
+	}
+
+	// the asm is always treated as having side effects, as reading a counter or
+	// making a syscall mustn't be merged with an identical one or removed
+	fnType := llvm.FunctionType(v.typeToLLVMType(n.GetType()), argTypes, false)
+	asm := llvm.InlineAsm(fnType, n.Template, strings.Join(constraints, ","), true, false)
+
+	return v.builder().CreateCall(asm, args, "")
+}
@@ -18,12 +18,53 @@ const (
 	OUTPUT_ASSEMBLY OutputType = iota
 	OUTPUT_OBJECT
 	OUTPUT_LLVM_IR
+	OUTPUT_LLVM_BITCODE
 	OUTPUT_EXECUTABLE
+	OUTPUT_STATIC_LIB
+	OUTPUT_SHARED_LIB
 )
 
-func (v *Codegen) createIR(mod *WrappedModule) string {
-	filename := v.OutputName + ".ll"
+// Whether the output is a library linked into other programs, which doesn't
+// need a main function. The interface of a library are its public functions
+// with the [c] attribute, as only these have names that don't depend on the
+// version of the compiler.
+func (v OutputType) IsLibrary() bool {
+	return v == OUTPUT_STATIC_LIB || v == OUTPUT_SHARED_LIB
+}
+
+// A phase of the code generation, after which the IR can be written with
+// EmitIRAfter
+type Phase int
+
+const (
+	PHASE_CODEGEN      Phase = iota // before the optimisation passes
+	PHASE_OPTIMIZATION              // after the optimisation passes, or link-time optimisation
+)
+
+func (v Phase) String() string {
+	switch v {
+	case PHASE_CODEGEN:
+		return "codegen"
+	case PHASE_OPTIMIZATION:
+		return "optimization"
+	default:
+		panic("unimplemented phase")
+	}
+}
 
+// Writes the IR of the module to a file next to the output if it was
+// requested for the phase
+func (v *Codegen) emitIR(mod *WrappedModule, phase Phase) {
+	for _, emitted := range v.EmitIRAfter {
+		if emitted == phase {
+			filename := v.OutputName + "-" + mod.MangledName(parser.MANGLE_ARK_UNSTABLE) + "." + phase.String() + ".ll"
+			v.createIR(mod, filename)
+			return
+		}
+	}
+}
+
+func (v *Codegen) createIR(mod *WrappedModule, filename string) string {
 	err := ioutil.WriteFile(filename, []byte(mod.LlvmModule.String()), 0666)
 	if err != nil {
 		v.err("Couldn't write IR file "+filename+": `%s`", err.Error())
@@ -32,12 +73,52 @@ func (v *Codegen) createIR(mod *WrappedModule) string {
 	return filename
 }
 
+func (v *Codegen) createBitcode(mod *WrappedModule, filename string) string {
+	membuf := llvm.WriteBitcodeToMemoryBuffer(mod.LlvmModule)
+	defer membuf.Dispose()
+
+	err := ioutil.WriteFile(filename, membuf.Bytes(), 0666)
+	if err != nil {
+		v.err("Couldn't write bitcode file "+filename+": `%s`", err.Error())
+	}
+
+	return filename
+}
+
+// Whether the output is made from the object files of the modules
+func (v *Codegen) emitsObject() bool {
+	switch v.OutputType {
+	case OUTPUT_OBJECT, OUTPUT_EXECUTABLE, OUTPUT_STATIC_LIB, OUTPUT_SHARED_LIB:
+		return true
+	default:
+		return false
+	}
+}
+
+// Creates the object file of the module, or copies it from the cache
+func (v *Codegen) createModuleObject(mod *WrappedModule) {
+	if mod.cached {
+		mod.objectFile = v.objectName(mod)
+		v.loadCachedObject(mod, mod.objectFile)
+		return
+	}
+
+	mod.objectFile = v.createObjectOrAssembly(mod, llvm.ObjectFile)
+	if v.cacheEnabled() {
+		v.storeCachedObject(mod, mod.objectFile)
+	}
+}
+
+func (v *Codegen) objectName(mod *WrappedModule) string {
+	return v.OutputName + "-" + mod.MangledName(parser.MANGLE_ARK_UNSTABLE) + ".o"
+}
+
 func (v *Codegen) createObjectOrAssembly(mod *WrappedModule, typ llvm.CodeGenFileType) string {
-	filename := v.OutputName + "-" + mod.MangledName(parser.MANGLE_ARK_UNSTABLE)
+	var filename string
 	if typ == llvm.AssemblyFile {
-		filename += ".s"
+		filename = v.OutputName + "-" + mod.MangledName(parser.MANGLE_ARK_UNSTABLE) + ".s"
 	} else {
-		filename += ".o"
+		filename = v.objectName(mod)
 	}
 
 	membuf, err := v.targetMachine.EmitToMemoryBuffer(mod.LlvmModule, typ)
@@ -54,12 +135,16 @@ func (v *Codegen) createObjectOrAssembly(mod *WrappedModule, typ llvm.CodeGenFil
 }
 
 func (v *Codegen) createBinary() {
+	// the modules have been linked into one, see linksModules
 	if v.OutputType == OUTPUT_LLVM_IR {
-		for _, mod := range v.input {
-			log.Timed("creating ir", mod.Name.String(), func() {
-				v.createIR(mod)
-			})
-		}
+		log.Timed("creating ir", "", func() {
+			v.createIR(v.input[0], v.OutputName+".ll")
+		})
+		return
+	} else if v.OutputType == OUTPUT_LLVM_BITCODE {
+		log.Timed("creating bitcode", "", func() {
+			v.createBitcode(v.input[0], v.OutputName+".bc")
+		})
 		return
 	} else if v.OutputType == OUTPUT_ASSEMBLY {
 		for _, mod := range v.input {
@@ -70,19 +155,30 @@ func (v *Codegen) createBinary() {
 		return
 	}
 
-	linkArgs := append(v.LinkerArgs, "-fno-PIE", "-nodefaultlibs", "-lc", "-lm")
+	linkArgs := append([]string{}, v.LinkerArgs...)
+	if v.OutputType == OUTPUT_SHARED_LIB {
+		linkArgs = append(linkArgs, "-shared")
+	} else {
+		linkArgs = append(linkArgs, "-fno-PIE")
+	}
+	linkArgs = append(linkArgs, "-nodefaultlibs", "-lc", "-lm")
 
 	objFiles := []string{}
 
+	// the objects are usually created by the workers, and are collected in the
+	// order of the input so the output doesn't depend on the scheduling
 	for _, mod := range v.input {
-		log.Timed("creating object", mod.Name.String(), func() {
-			objName := v.createObjectOrAssembly(mod, llvm.ObjectFile)
-			objFiles = append(objFiles, objName)
-			linkArgs = append(linkArgs, objName)
-			for _, lib := range mod.LinkedLibraries {
-				linkArgs = append(linkArgs, fmt.Sprintf("-l%s", lib))
-			}
-		})
+		if mod.objectFile == "" {
+			log.Timed("creating object", mod.Name.String(), func() {
+				v.createModuleObject(mod)
+			})
+		}
+
+		objFiles = append(objFiles, mod.objectFile)
+		linkArgs = append(linkArgs, mod.objectFile)
+		for _, lib := range mod.LinkedLibraries {
+			linkArgs = append(linkArgs, fmt.Sprintf("-l%s", lib))
+		}
 	}
 
 	if v.OutputType == OUTPUT_OBJECT {
@@ -93,6 +189,18 @@ func (v *Codegen) createBinary() {
 		panic("OutputName is empty")
 	}
 
+	if v.OutputType == OUTPUT_STATIC_LIB {
+		v.createArchive(objFiles)
+	} else {
+		v.link(linkArgs)
+	}
+
+	for _, objFile := range objFiles {
+		os.Remove(objFile)
+	}
+}
+
+func (v *Codegen) link(linkArgs []string) {
 	linkArgs = append(linkArgs, "-o", v.OutputName)
 
 	if v.Linker == "" {
@@ -107,8 +215,28 @@ func (v *Codegen) createBinary() {
 			v.err("failed to link object files: `%s`\n%s", err.Error(), string(out))
 		}
 	})
+}
 
-	for _, objFile := range objFiles {
-		os.Remove(objFile)
+// Bundles the object files into an ar archive. The libraries the modules link
+// to aren't part of the archive, and must be linked by the user of the library.
+func (v *Codegen) createArchive(objFiles []string) {
+	if v.Archiver == "" {
+		v.Archiver = "ar"
 	}
+
+	// ar adds to existing archives, which could leave stale objects behind
+	if err := os.Remove(v.OutputName); err != nil && !os.IsNotExist(err) {
+		v.err("Couldn't remove old archive %s: `%s`", v.OutputName, err.Error())
+	}
+
+	arArgs := append([]string{"rcs", v.OutputName}, objFiles...)
+
+	log.Timed("archiving", "", func() {
+		log.Verboseln("codegen", "%s %v", v.Archiver, arArgs)
+
+		cmd := exec.Command(v.Archiver, arArgs...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			v.err("failed to archive object files: `%s`\n%s", err.Error(), string(out))
+		}
+	})
 }
@@ -0,0 +1,204 @@
+package LLVMCodegen
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ark-lang/ark/src/parser"
+	"github.com/ark-lang/ark/src/util/log"
+)
+
+// The object file of every module is cached in CacheDir, keyed by a hash of
+// the sources of the module, the options of the codegen and the interfaces of
+// the modules it depends on. A module whose key is in the cache isn't
+// generated again, the cached object is used instead.
+//
+// The interface of a module is made up of the signatures of its declarations,
+// so a change to the body of a function only invalidates the module itself.
+// Constants are inlined into the modules using them, so their values are part
+// of the interface too.
+// Instances of generic functions are added to the module of the generic
+// function, so they are part of its key as well.
+
+// Whether the objects of modules are taken from and stored in the cache. The
+// IR of cached modules isn't generated, so it can't be emitted.
+func (v *Codegen) cacheEnabled() bool {
+	return v.CacheDir != "" && !v.LTO && v.emitsObject() && len(v.EmitIRAfter) == 0
+}
+
+func (v *Codegen) cachedObjectPath(mod *WrappedModule) string {
+	return filepath.Join(v.CacheDir, mod.cacheKey+".o")
+}
+
+// Computes the cache key of the module, and returns true if its object is in
+// the cache
+func (v *Codegen) lookupCache(mod *WrappedModule) bool {
+	mod.cacheKey = v.moduleCacheKey(mod)
+
+	_, err := os.Stat(v.cachedObjectPath(mod))
+	if err == nil {
+		log.Verboseln("codegen", "Using cached object for module `%s`", mod.Name.String())
+		mod.cached = true
+	}
+	return mod.cached
+}
+
+// Copies the object of the module to the cache. The object is written to a
+// temporary file first, so concurrent builds never see a partial object.
+func (v *Codegen) storeCachedObject(mod *WrappedModule, objName string) {
+	if err := os.MkdirAll(v.CacheDir, 0777); err != nil {
+		v.err("Couldn't create cache directory %s: `%s`", v.CacheDir, err.Error())
+	}
+
+	tmp, err := ioutil.TempFile(v.CacheDir, mod.cacheKey)
+	if err != nil {
+		v.err("Couldn't create file in cache directory %s: `%s`", v.CacheDir, err.Error())
+	}
+
+	err = copyFile(tmp, objName)
+	tmp.Close()
+	if err == nil {
+		err = os.Rename(tmp.Name(), v.cachedObjectPath(mod))
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		v.err("Couldn't store object %s in cache: `%s`", objName, err.Error())
+	}
+}
+
+// Copies the cached object of the module to the file objName
+func (v *Codegen) loadCachedObject(mod *WrappedModule, objName string) {
+	file, err := os.Create(objName)
+	if err != nil {
+		v.err("Couldn't create file "+objName+": `%s`", err.Error())
+	}
+	defer file.Close()
+
+	if err := copyFile(file, v.cachedObjectPath(mod)); err != nil {
+		v.err("Couldn't read cached object for module `%s`: `%s`", mod.Name.String(), err.Error())
+	}
+}
+
+func copyFile(dest io.Writer, src string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(dest, file)
+	return err
+}
+
+func (v *Codegen) moduleCacheKey(mod *WrappedModule) string {
+	h := sha1.New()
+
+	fmt.Fprintf(h, "version %s\n", v.Version)
+	fmt.Fprintf(h, "options %t %d %d %q %t %q %q %q %t %t\n", v.OutputType == OUTPUT_SHARED_LIB,
+		v.OptLevel, v.SizeLevel, v.Passes, v.DebugInfo, v.Target, v.CPU, v.Features, v.OverflowChecks, v.NullChecks)
+
+	for _, submod := range sortedSubmodules(mod.Module) {
+		fmt.Fprintf(h, "file %s\n", submod.File.Path)
+		h.Write([]byte(string(submod.File.Contents)))
+		h.Write([]byte{0})
+
+		for _, node := range submod.Nodes {
+			if decl, ok := node.(*parser.FunctionDecl); ok && decl.Function.TypeArguments != nil {
+				fmt.Fprintf(h, "instance %s\n", decl.Function.MangledName(parser.MANGLE_ARK_UNSTABLE))
+			}
+		}
+	}
+
+	if v.Dependencies != nil {
+		for _, dep := range v.Dependencies.TransitiveDependencies(mod.Name) {
+			if depMod := v.inputModule(dep); depMod != nil {
+				fmt.Fprintf(h, "dependency %s ", dep)
+				writeInterface(h, depMod.Module)
+			}
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (v *Codegen) inputModule(name string) *WrappedModule {
+	for _, mod := range v.input {
+		if mod.Name.String() == name {
+			return mod
+		}
+	}
+	return nil
+}
+
+// Writes the signatures of the declarations of the module, and the values of
+// its constants. Constants using sizeof can't be evaluated without the LLVM
+// context, but their values only depend on the target, which is in the key.
+func writeInterface(h io.Writer, mod *parser.Module) {
+	constants := &parser.ConstantEvaluator{}
+
+	for _, submod := range sortedSubmodules(mod) {
+		for _, node := range submod.Nodes {
+			switch n := node.(type) {
+			case *parser.TypeDecl:
+				fmt.Fprintf(h, "type %s %s", n.NamedType.MangledName(parser.MANGLE_ARK_UNSTABLE), n.NamedType.Type.TypeName())
+				writeAttrs(h, n.NamedType.Attrs())
+				if enum, ok := n.NamedType.Type.(parser.EnumType); ok {
+					for _, mem := range enum.Members {
+						fmt.Fprintf(h, " %d", mem.Tag)
+					}
+				}
+
+			case *parser.FunctionDecl:
+				fmt.Fprintf(h, "func %s %t", n.Function.MangledName(parser.MANGLE_ARK_UNSTABLE), n.IsPublic())
+				writeAttrs(h, n.Function.Type.Attrs())
+
+			case *parser.VariableDecl:
+				fmt.Fprintf(h, "var %s %s %t %t", n.Variable.MangledName(parser.MANGLE_ARK_UNSTABLE),
+					n.Variable.Type.TypeName(), n.Variable.Mutable, n.IsPublic())
+
+				if n.Variable.IsConstant {
+					// errors are reported when the module itself is generated
+					if value, err := constants.Evaluate(n.Assignment); err == nil {
+						fmt.Fprintf(h, " = %s", value.String())
+					} else {
+						fmt.Fprintf(h, " = error %s", err.Error())
+					}
+				}
+			}
+			h.Write([]byte{'\n'})
+		}
+	}
+}
+
+func writeAttrs(h io.Writer, attrs parser.AttrGroup) {
+	var keys []string
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(h, " [%s=%q]", key, attrs[key].Value)
+	}
+}
+
+func sortedSubmodules(mod *parser.Module) []*parser.Submodule {
+	var names []string
+	for name := range mod.Parts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := make([]*parser.Submodule, len(names))
+	for idx, name := range names {
+		res[idx] = mod.Parts[name]
+	}
+	return res
+}
@@ -0,0 +1,225 @@
+package LLVMCodegen
+
+import (
+	"fmt"
+
+	"github.com/ark-lang/ark/src/parser"
+	"llvm.org/llvm/bindings/go/llvm"
+)
+
+// Closures are pairs of a function pointer and a pointer to the environment of
+// the closure, which holds the variables captured by the lambda. The function
+// takes the environment pointer as its first argument. The environment is
+// allocated with malloc when the lambda is evaluated, so a closure can outlive
+// the function that created it. Variables captured by value are copied into
+// the environment, while mutable variables are captured by reference. These
+// are boxed: they live on the stack until a closure captures them, which
+// moves them to memory allocated with malloc, so they outlive their function
+// too. The function accesses them through a pointer to their current storage.
+// Neither environments nor boxes are freed, as nothing tracks how long
+// closures live, but a variable is only moved once each time its declaration
+// is reached.
+
+// A boxed variable of the function being generated
+type boxedVariable struct {
+	stack   llvm.Value // the storage of the variable on the stack
+	current llvm.Value // pointer to the storage in use, the stack or the heap
+}
+
+func (v *Codegen) closureTypeToLLVMType(typ parser.FunctionType) llvm.Type {
+	bytePtrType := llvm.PointerType(v.context.IntType(8), 0)
+	return v.context.StructType([]llvm.Type{v.closureFunctionType(typ, true), bytePtrType}, false)
+}
+
+// The type of the function of a closure, which takes the environment pointer
+// in place of a receiver
+func (v *Codegen) closureFunctionType(typ parser.FunctionType, ptr bool) llvm.Type {
+	typ.IsClosure = false
+	typ.Receiver = parser.PointerTo(parser.PRIMITIVE_u8)
+	return v.functionTypeToLLVMType(typ, ptr)
+}
+
+func (v *Codegen) environmentType(fn *parser.Function) llvm.Type {
+	fields := make([]llvm.Type, len(fn.Captures))
+	for idx, vari := range fn.Captures {
+		fields[idx] = v.typeToLLVMType(vari.Type)
+		if vari.Mutable {
+			fields[idx] = llvm.PointerType(fields[idx], 0)
+		}
+	}
+	return v.context.StructType(fields, false)
+}
+
+func (v *Codegen) genClosure(n *parser.LambdaExpr) llvm.Value {
+	typ := n.GetType().(parser.FunctionType)
+	fn := llvm.AddFunction(v.curFile.LlvmModule, fmt.Sprintf("_Lambda%d", v.nextLambdaID()), v.closureFunctionType(typ, false))
+	fn.SetLinkage(nonPublicLinkage)
+
+	env := v.genEnvironment(n.Function)
+
+	// the body of the lambda accesses the captured variables through the
+	// environment, so their lookups are restored for the enclosing function
+	outer := make(map[*parser.Variable]llvm.Value, len(n.Function.Captures))
+	outerBoxes := make(map[*parser.Variable]boxedVariable)
+	for _, vari := range n.Function.Captures {
+		outer[vari] = v.variableLookup[vari]
+		if box, ok := v.boxes[vari]; ok {
+			outerBoxes[vari] = box
+			delete(v.boxes, vari)
+		}
+	}
+
+	v.genFunctionBody(n.Function, fn, n.Pos())
+
+	for vari, value := range outer {
+		v.variableLookup[vari] = value
+	}
+	for vari, box := range outerBoxes {
+		v.boxes[vari] = box
+	}
+
+	res := llvm.Undef(v.closureTypeToLLVMType(typ))
+	res = v.builder().CreateInsertValue(res, fn, 0, "")
+	res = v.builder().CreateInsertValue(res, env, 1, "")
+	return res
+}
+
+// Allocates the environment of the lambda, and stores the captured variables
+// in it
+func (v *Codegen) genEnvironment(fn *parser.Function) llvm.Value {
+	envType := v.environmentType(fn)
+	size := llvm.ConstInt(v.intPtrType(), v.targetData.TypeAllocSize(envType), false)
+
+	mem := v.builder().CreateCall(v.getMalloc(), []llvm.Value{size}, "env")
+	env := v.builder().CreateBitCast(mem, llvm.PointerType(envType, 0), "")
+
+	for idx, vari := range fn.Captures {
+		var value llvm.Value
+		if vari.Mutable {
+			value = v.genBox(vari)
+		} else {
+			value = v.builder().CreateLoad(v.getVariable(vari), "")
+		}
+		v.builder().CreateStore(value, v.builder().CreateStructGEP(env, idx, ""))
+	}
+
+	return mem
+}
+
+// Makes the captured variables of the lambda refer to its environment. Called
+// at the start of the body of the lambda.
+func (v *Codegen) genCaptureAccess(fn *parser.Function, envPtr llvm.Value) {
+	env := v.builder().CreateBitCast(envPtr, llvm.PointerType(v.environmentType(fn), 0), "env")
+
+	for idx, vari := range fn.Captures {
+		field := v.builder().CreateStructGEP(env, idx, vari.Name)
+		if vari.Mutable {
+			field = v.builder().CreateLoad(field, vari.Name)
+		}
+		v.variableLookup[vari] = field
+	}
+}
+
+func (v *Codegen) genClosureCall(n *parser.CallExpr) llvm.Value {
+	closure := v.genExpr(n.Function)
+
+	args := []llvm.Value{v.builder().CreateExtractValue(closure, 1, "env")}
+	for _, arg := range n.Arguments {
+		args = append(args, v.genExpr(arg))
+	}
+
+	return v.builder().CreateCall(v.builder().CreateExtractValue(closure, 0, ""), args, "")
+}
+
+// Converts a function that isn't a closure to a closure of the type typ. The
+// function pointer is used as the environment, and called by a thunk.
+func (v *Codegen) genClosureConversion(expr parser.Expr, typ parser.FunctionType) llvm.Value {
+	if !v.inFunction() {
+		v.err("Cannot convert to closure `%s` outside of a function", typ.TypeName())
+	}
+
+	bytePtrType := llvm.PointerType(v.context.IntType(8), 0)
+	fn := v.builder().CreateBitCast(v.genExpr(expr), bytePtrType, "")
+
+	res := llvm.Undef(v.closureTypeToLLVMType(typ))
+	res = v.builder().CreateInsertValue(res, v.getClosureThunk(typ), 0, "")
+	res = v.builder().CreateInsertValue(res, fn, 1, "")
+	return res
+}
+
+// Gets the function of closures converted from functions of the type typ,
+// which calls the function pointer passed in place of the environment
+func (v *Codegen) getClosureThunk(typ parser.FunctionType) llvm.Value {
+	name := "_CT" + parser.TypeMangledName(parser.MANGLE_ARK_UNSTABLE, typ)
+
+	thunk := v.curFile.LlvmModule.NamedFunction(name)
+	if !thunk.IsNil() {
+		return thunk
+	}
+
+	thunk = llvm.AddFunction(v.curFile.LlvmModule, name, v.closureFunctionType(typ, false))
+	thunk.SetLinkage(nonPublicLinkage)
+
+	builder := v.context.NewBuilder()
+	defer builder.Dispose()
+	builder.SetInsertPointAtEnd(v.context.AddBasicBlock(thunk, "entry"))
+
+	fnType := typ
+	fnType.IsClosure = false
+
+	params := thunk.Params()
+	fn := builder.CreateBitCast(params[0], v.functionTypeToLLVMType(fnType, true), "")
+	call := builder.CreateCall(fn, params[1:], "")
+
+	if typ.Return.IsVoidType() {
+		builder.CreateRetVoid()
+	} else {
+		builder.CreateRet(call)
+	}
+
+	return thunk
+}
+
+// Makes the boxed variable use its storage on the stack, alloc. Called each
+// time the declaration is reached, as closures may still refer to the storage
+// of the previous run of it.
+func (v *Codegen) genBoxedVariable(vari *parser.Variable, alloc llvm.Value) {
+	current := v.createEntryAlloca(alloc.Type(), vari.Name+".box")
+	v.builder().CreateStore(alloc, current)
+	v.boxes[vari] = boxedVariable{stack: alloc, current: current}
+}
+
+// Moves the boxed variable to the heap if it is still on the stack, and
+// returns its storage there
+func (v *Codegen) genBox(vari *parser.Variable) llvm.Value {
+	box, ok := v.boxes[vari]
+	if !ok {
+		// variables captured by the current lambda are boxed already
+		return v.variableLookup[vari]
+	}
+
+	onStack := v.builder().CreateICmp(llvm.IntEQ, v.builder().CreateLoad(box.current, ""), box.stack, "")
+
+	moveBlock := v.context.AddBasicBlock(v.currentLLVMFunction(), "box")
+	moveBlock.MoveAfter(v.builder().GetInsertBlock())
+	doneBlock := v.context.AddBasicBlock(v.currentLLVMFunction(), "box_done")
+	doneBlock.MoveAfter(moveBlock)
+	v.builder().CreateCondBr(onStack, moveBlock, doneBlock)
+
+	v.builder().SetInsertPointAtEnd(moveBlock)
+	typ := box.stack.Type().ElementType()
+	size := llvm.ConstInt(v.intPtrType(), v.targetData.TypeAllocSize(typ), false)
+	mem := v.builder().CreateCall(v.getMalloc(), []llvm.Value{size}, "")
+	heap := v.builder().CreateBitCast(mem, box.stack.Type(), vari.Name)
+	v.builder().CreateStore(v.builder().CreateLoad(box.stack, ""), heap)
+	v.builder().CreateStore(heap, box.current)
+	v.builder().CreateBr(doneBlock)
+
+	v.builder().SetInsertPointAtEnd(doneBlock)
+	return v.builder().CreateLoad(box.current, vari.Name)
+}
+
+func (v *Codegen) getMalloc() llvm.Value {
+	bytePtrType := llvm.PointerType(v.context.IntType(8), 0)
+	return v.getCFunction("malloc", llvm.FunctionType(bytePtrType, []llvm.Type{v.intPtrType()}, false))
+}
@@ -3,7 +3,9 @@ package LLVMCodegen
 import (
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/ark-lang/ark/src/lexer"
 	"github.com/ark-lang/ark/src/parser"
 	"github.com/ark-lang/ark/src/semantic"
 	"github.com/ark-lang/ark/src/util"
@@ -18,18 +20,39 @@ type Codegen struct {
 	OutputType OutputType
 	LinkerArgs []string
 	Linker     string // defaults to cc
+	Archiver   string // defaults to ar
 	OptLevel   int
+	SizeLevel  int      // 1 to optimize for size, 2 to optimize harder
+	Passes     []string // LLVM passes to run instead of the ones of the optimization level
+	DebugInfo  bool
+	Target     string // target triple, defaults to the host
+	CPU        string // defaults to a generic cpu of the target
+	Features   string // comma separated list of target features, e.g. +sse4.1,-avx
+
+	OverflowChecks bool    // panic on integer overflow and division by zero
+	NullChecks     bool    // panic when a null pointer is dereferenced
+	LTO            bool    // link all modules into one before optimizing
+	Jobs           int     // number of modules generated concurrently
+	HeaderFile     string  // where to write a C header for the exported functions, none if empty
+	EmitIRAfter    []Phase // phases after which the IR of the modules is written next to the output
+
+	CacheDir     string                  // where module objects are cached, no caching if empty
+	Version      string                  // version and build of the compiler, part of the cache keys
+	Dependencies *parser.DependencyGraph // used for the cache keys
 
 	// private stuff
-	input   []*WrappedModule
-	curFile *WrappedModule
+	input        []*WrappedModule
+	curFile      *WrappedModule
+	curSubmodule *parser.Submodule
 
 	builders     map[*parser.Function]llvm.Builder      // map of functions to builders
 	curLoopExits map[*parser.Function][]llvm.BasicBlock // map of functions to slices of blocks, where each block is the exit block for current loops
 	curLoopNexts map[*parser.Function][]llvm.BasicBlock // map of functions to slices of blocks, where each block is the eval block for current loops
+	curLoopDepth map[*parser.Function][]int             // map of functions to slices of block depths, where each depth is the number of blocks entered outside current loops
 
 	globalBuilder   llvm.Builder // used non-function stuff
 	variableLookup  map[*parser.Variable]llvm.Value
+	boxes           map[*parser.Variable]boxedVariable // boxed variables of the functions being generated
 	namedTypeLookup map[string]llvm.Type
 
 	referenceAccess bool
@@ -43,10 +66,21 @@ type Codegen struct {
 	// dirty thing for global arrays
 	arrayIndex int
 
+	// created once the target is known, see constantEvaluator
+	constants *parser.ConstantEvaluator
+
+	// every worker generating modules has its own context
+	context     llvm.Context
+	passManager llvm.PassManager
+
 	// size calculation stuff
+	triple        string
 	target        llvm.Target
 	targetMachine llvm.TargetMachine
 	targetData    llvm.TargetData
+
+	// nil unless DebugInfo is set
+	debug *debugInfo
 }
 
 func (v *Codegen) builder() llvm.Builder {
@@ -100,6 +134,21 @@ func (v *Codegen) nextLambdaID() int {
 type WrappedModule struct {
 	*parser.Module
 	LlvmModule llvm.Module
+
+	cacheKey   string
+	cached     bool   // LlvmModule isn't generated if the object is cached
+	objectFile string // set once the object of the module is created
+
+	// symbols kept visible by link-time optimisation
+	exportedSymbols map[string]bool
+
+	// globals initialized at runtime, and the [init] and [fini] functions,
+	// see genStructors
+	initializedGlobals           []*parser.VariableDecl
+	initFunctions, finiFunctions []llvm.Value
+	globalOrder                  map[*parser.Variable]int // see orderGlobals
+
+	usedValues []llvm.Value // kept by llvm.used, see genUsedValues
 }
 
 type deferData struct {
@@ -114,76 +163,169 @@ func (v *Codegen) err(err string, stuff ...interface{}) {
 }
 
 func (v *Codegen) Generate(input []*parser.Module) {
-	v.builders = make(map[*parser.Function]llvm.Builder)
-	v.inBlocks = make(map[*parser.Function][]*parser.Block)
-	v.globalBuilder = llvm.NewBuilder()
-	defer v.globalBuilder.Dispose()
-
-	v.curLoopExits = make(map[*parser.Function][]llvm.BasicBlock)
-	v.curLoopNexts = make(map[*parser.Function][]llvm.BasicBlock)
-
 	v.input = make([]*WrappedModule, len(input))
 	for idx, mod := range input {
-		v.input[idx] = &WrappedModule{Module: mod}
+		v.input[idx] = &WrappedModule{Module: mod, exportedSymbols: make(map[string]bool)}
 	}
 
-	v.variableLookup = make(map[*parser.Variable]llvm.Value)
-	v.namedTypeLookup = make(map[string]llvm.Type)
-
-	// initialize llvm target
-	llvm.InitializeNativeTarget()
-	llvm.InitializeNativeAsmPrinter()
+	// initialize llvm targets, all of them so we can cross compile
+	llvm.InitializeAllTargetInfos()
+	llvm.InitializeAllTargets()
+	llvm.InitializeAllTargetMCs()
+	llvm.InitializeAllAsmPrinters()
 
 	// setup target stuff
+	v.triple = v.Target
+	if v.triple == "" {
+		v.triple = llvm.DefaultTargetTriple()
+	}
+
 	var err error
-	v.target, err = llvm.GetTargetFromTriple(llvm.DefaultTargetTriple())
+	v.target, err = llvm.GetTargetFromTriple(v.triple)
 	if err != nil {
-		panic(err)
+		v.err("Invalid target `%s`: %s", v.triple, err.Error())
 	}
-	v.targetMachine = v.target.CreateTargetMachine(llvm.DefaultTargetTriple(), "", "", llvm.CodeGenLevelNone, llvm.RelocDefault, llvm.CodeModelDefault)
+
+	v.targetMachine = v.createTargetMachine()
 	v.targetData = v.targetMachine.TargetData()
 
+	v.checkPasses()
+
+	var modules []*WrappedModule
+	for _, infile := range v.input {
+		if !v.cacheEnabled() || !v.lookupCache(infile) {
+			v.orderGlobals(infile)
+			modules = append(modules, infile)
+		}
+	}
+
+	if v.HeaderFile != "" {
+		log.Timed("creating header", "", func() {
+			v.createHeader()
+		})
+	}
+
+	log.Timed("codegenning", "", func() {
+		v.generateModules(modules)
+	})
+
+	if v.LTO {
+		log.Timed("link-time optimisation", "", func() {
+			v.passManager = v.createPassManager()
+			defer v.passManager.Dispose()
+
+			v.linkModules()
+			v.internalize(v.input[0])
+			v.optimize(v.input[0])
+		})
+	} else if v.linksModules() {
+		log.Timed("linking modules", "", func() {
+			v.linkModules()
+		})
+	}
+
+	log.Timed("creating binary", "", func() {
+		v.createBinary()
+	})
+
+}
+
+func (v *Codegen) createTargetMachine() llvm.TargetMachine {
+	// shared libraries can be loaded at any address
+	reloc := llvm.RelocDefault
+	if v.OutputType == OUTPUT_SHARED_LIB {
+		reloc = llvm.RelocPIC
+	}
+
+	return v.target.CreateTargetMachine(v.triple, v.CPU, v.Features, v.codeGenLevel(), reloc, llvm.CodeModelDefault)
+}
+
+func (v *Codegen) createPassManager() llvm.PassManager {
 	passManager := llvm.NewPassManager()
 	passBuilder := llvm.NewPassManagerBuilder()
-	if v.OptLevel > 0 {
+	defer passBuilder.Dispose()
+
+	if len(v.Passes) > 0 {
+		v.addPasses(passManager)
+	} else if v.OptLevel > 0 {
 		passBuilder.SetOptLevel(v.OptLevel)
+		passBuilder.SetSizeLevel(v.SizeLevel)
+		if v.OptLevel > 1 {
+			// the C API doesn't add an inliner by default
+			passBuilder.UseInlinerWithThreshold(inlineThreshold(v.OptLevel, v.SizeLevel))
+		}
 		passBuilder.Populate(passManager)
 	}
 
-	v.blockDeferData = make(map[*parser.Block][]*deferData)
+	return passManager
+}
 
-	for _, infile := range v.input {
-		log.Timed("codegenning", infile.Name.String(), func() {
-			infile.LlvmModule = llvm.NewModule(infile.Name.String())
-			v.curFile = infile
+func (v *Codegen) generateModule(infile *WrappedModule) {
+	start := time.Now()
 
-			for _, submod := range infile.Parts {
-				v.declareDecls(submod.Nodes)
+	// values of other modules can only be used through declarations
+	v.variableLookup = make(map[*parser.Variable]llvm.Value)
+	v.boxes = make(map[*parser.Variable]boxedVariable)
 
-				for _, node := range submod.Nodes {
-					v.genNode(node)
-				}
-			}
+	infile.LlvmModule = v.context.NewModule(infile.Name.String())
+	infile.LlvmModule.SetTarget(v.triple)
+	infile.LlvmModule.SetDataLayout(v.targetData.String())
+	v.curFile = infile
+	v.debugEnterModule(infile)
 
-			if err := llvm.VerifyModule(infile.LlvmModule, llvm.ReturnStatusAction); err != nil {
-				infile.LlvmModule.Dump()
-				v.err("%s", err.Error())
-			}
+	for _, submod := range sortedSubmodules(infile.Module) {
+		v.curSubmodule = submod
+		v.debugEnterSubmodule(submod)
+		v.declareDecls(submod.Nodes)
+
+		for _, node := range submod.Nodes {
+			v.genNode(node)
+		}
+	}
 
-			passManager.Run(infile.LlvmModule)
+	v.genStructors(infile)
+	v.genUsedValues(infile)
+	v.debugExitModule(infile)
 
-			if log.AtLevel(log.LevelDebug) {
-				infile.LlvmModule.Dump()
-			}
-		})
+	if err := llvm.VerifyModule(infile.LlvmModule, llvm.ReturnStatusAction); err != nil {
+		infile.LlvmModule.Dump()
+		v.err("%s", err.Error())
 	}
+	v.emitIR(infile, PHASE_CODEGEN)
 
-	passManager.Dispose()
+	if !v.LTO {
+		v.optimize(infile)
 
-	log.Timed("creating binary", "", func() {
-		v.createBinary()
-	})
+		if v.emitsObject() {
+			v.createModuleObject(infile)
+		}
+	}
 
+	log.Verboseln("codegen", "Generated module `%s` (%.2fms)", infile.Name.String(),
+		float32(time.Since(start).Nanoseconds())/1000000)
+}
+
+func (v *Codegen) optimize(mod *WrappedModule) {
+	v.passManager.Run(mod.LlvmModule)
+	v.emitIR(mod, PHASE_OPTIMIZATION)
+
+	if log.AtLevel(log.LevelDebug) {
+		mod.LlvmModule.Dump()
+	}
+}
+
+// The inline thresholds clang uses for -O2, -O3, -Os and -Oz
+func inlineThreshold(optLevel, sizeLevel int) uint {
+	switch {
+	case sizeLevel == 1:
+		return 75
+	case sizeLevel > 1:
+		return 25
+	case optLevel > 2:
+		return 275
+	default:
+		return 225
+	}
 }
 
 func (v *Codegen) declareDecls(nodes []parser.Node) {
@@ -201,7 +343,10 @@ func (v *Codegen) declareDecls(nodes []parser.Node) {
 		if n, ok := node.(parser.Decl); ok {
 			switch n.(type) {
 			case *parser.FunctionDecl:
-				v.declareFunctionDecl(n.(*parser.FunctionDecl))
+				// generic functions are only generated through their instances
+				if !n.(*parser.FunctionDecl).Function.IsGeneric() {
+					v.declareFunctionDecl(n.(*parser.FunctionDecl))
+				}
 			}
 		}
 	}
@@ -225,7 +370,7 @@ func (v *Codegen) addStructType(typ parser.StructType, name string) {
 		return
 	}
 
-	structure := v.curFile.LlvmModule.Context().StructCreateNamed(name)
+	structure := v.context.StructCreateNamed(name)
 
 	v.namedTypeLookup[name] = structure
 
@@ -245,9 +390,9 @@ func (v *Codegen) addEnumType(typ parser.EnumType, name string) {
 
 	if typ.Simple {
 		// TODO: Handle other integer size, maybe dynamic depending on max value?
-		v.namedTypeLookup[name] = llvm.IntType(32)
+		v.namedTypeLookup[name] = v.context.IntType(32)
 	} else {
-		enum := v.curFile.LlvmModule.Context().StructCreateNamed(name)
+		enum := v.context.StructCreateNamed(name)
 		v.namedTypeLookup[name] = enum
 
 		for _, member := range typ.Members {
@@ -306,6 +451,14 @@ func (v *Codegen) declareFunctionDecl(n *parser.FunctionDecl) {
 			function.SetLinkage(nonPublicLinkage)
 		}
 
+		// the mangled names of other functions aren't stable, so libraries
+		// only export the functions with the [c] attribute
+		if cBinding || n.Function.Name == "main" {
+			v.exportSymbol(functionName)
+		} else if n.IsPublic() && v.OutputType == OUTPUT_SHARED_LIB {
+			function.SetVisibility(llvm.HiddenVisibility)
+		}
+
 		if ccAttr := attrs.Get("call_conv"); ccAttr != nil {
 			// TODO: move value checking to parser?
 			if callConv, ok := callConvTypes[ccAttr.Value]; ok {
@@ -319,6 +472,11 @@ func (v *Codegen) declareFunctionDecl(n *parser.FunctionDecl) {
 			function.AddFunctionAttr(inlineAttrType[inlineAttr.Value])
 		}
 
+		// functions of other modules are only declared to call them
+		if n.Function.ParentModule == v.curFile.Module {
+			v.applyLinkageAttrs(function, attrs, n.Prototype)
+		}
+
 		/*// do some magical shit for later
 		for i := 0; i < numOfParams; i++ {
 			funcParam := function.Param(i)
@@ -328,6 +486,10 @@ func (v *Codegen) declareFunctionDecl(n *parser.FunctionDecl) {
 }
 
 func (v *Codegen) getVariable(vari *parser.Variable) llvm.Value {
+	if box, ok := v.boxes[vari]; ok {
+		return v.builder().CreateLoad(box.current, vari.Name)
+	}
+
 	if value, ok := v.variableLookup[vari]; ok {
 		return value
 	}
@@ -335,6 +497,7 @@ func (v *Codegen) getVariable(vari *parser.Variable) llvm.Value {
 	if vari.ParentModule != v.curFile.Module {
 		value := llvm.AddGlobal(v.curFile.LlvmModule, v.typeToLLVMType(vari.Type), vari.MangledName(parser.MANGLE_ARK_UNSTABLE))
 		value.SetLinkage(llvm.ExternalLinkage)
+		value.SetThreadLocal(vari.Attrs.Contains("thread_local"))
 		v.variableLookup[vari] = value
 		return value
 	}
@@ -344,6 +507,8 @@ func (v *Codegen) getVariable(vari *parser.Variable) llvm.Value {
 }
 
 func (v *Codegen) genNode(n parser.Node) {
+	v.debugSetLocation(n)
+
 	switch n := n.(type) {
 	case parser.Decl:
 		v.genDecl(n)
@@ -368,6 +533,8 @@ func (v *Codegen) genStat(n parser.Stat) {
 		v.genBlockStat(n)
 	case *parser.CallStat:
 		v.genCallStat(n)
+	case *parser.AsmStat:
+		v.genAsmStat(n)
 	case *parser.AssignStat:
 		v.genAssignStat(n)
 	case *parser.BinopAssignStat:
@@ -388,15 +555,28 @@ func (v *Codegen) genStat(n parser.Stat) {
 }
 
 func (v *Codegen) genBreakStat(n *parser.BreakStat) {
+	v.genLoopDefers()
 	curExits := v.curLoopExits[v.currentFunction()]
 	v.builder().CreateBr(curExits[len(curExits)-1])
 }
 
 func (v *Codegen) genNextStat(n *parser.NextStat) {
+	v.genLoopDefers()
 	curNexts := v.curLoopNexts[v.currentFunction()]
 	v.builder().CreateBr(curNexts[len(curNexts)-1])
 }
 
+// runs the defers of all blocks we are leaving by jumping out of the current loop body
+func (v *Codegen) genLoopDefers() {
+	fn := v.currentFunction()
+	depths := v.curLoopDepth[fn]
+	depth := depths[len(depths)-1]
+
+	for i := len(v.inBlocks[fn]) - 1; i >= depth; i-- {
+		v.genRunDefers(v.inBlocks[fn][i])
+	}
+}
+
 func (v *Codegen) genDeferStat(n *parser.DeferStat) {
 	data := &deferData{
 		stat: n,
@@ -421,15 +601,17 @@ func (v *Codegen) genRunDefers(block *parser.Block) {
 
 func (v *Codegen) genBlock(n *parser.Block) {
 	v.pushBlock(n)
+	v.debugPushBlock(n)
 	for i, x := range n.Nodes {
 		v.genNode(x)
 
-		if i == len(n.Nodes)-1 && !n.IsTerminating {
+		if i == len(n.Nodes)-1 && !n.IsTerminating && !isBreakOrNext(x) {
 			v.genRunDefers(n)
 		}
 	}
 
 	delete(v.blockDeferData, n)
+	v.debugPopBlock()
 	v.popBlock()
 }
 
@@ -468,7 +650,7 @@ func (v *Codegen) genBinopAssignStat(n *parser.BinopAssignStat) {
 	storageValue := v.builder().CreateLoad(storage, "")
 	assignmentValue := v.genExpr(n.Assignment)
 
-	value := v.genBinop(n.Operator, n.Access.GetType(), n.Access.GetType(), n.Assignment.GetType(), storageValue, assignmentValue)
+	value := v.genBinop(n.Operator, n.Access.GetType(), n.Access.GetType(), n.Assignment.GetType(), storageValue, assignmentValue, n.Pos())
 	v.builder().CreateStore(value, storage)
 }
 
@@ -493,14 +675,14 @@ func (v *Codegen) genIfStat(n *parser.IfStat) {
 
 	var end llvm.BasicBlock
 	if !statTerm {
-		end = llvm.AddBasicBlock(v.currentLLVMFunction(), "end")
+		end = v.context.AddBasicBlock(v.currentLLVMFunction(), "end")
 	}
 
 	for i, expr := range n.Exprs {
 		cond := v.genExpr(expr)
 
-		ifTrue := llvm.AddBasicBlock(v.currentLLVMFunction(), "if_true")
-		ifFalse := llvm.AddBasicBlock(v.currentLLVMFunction(), "if_false")
+		ifTrue := v.context.AddBasicBlock(v.currentLLVMFunction(), "if_true")
+		ifFalse := v.context.AddBasicBlock(v.currentLLVMFunction(), "if_false")
 
 		v.builder().CreateCondBr(cond, ifTrue, ifFalse)
 
@@ -533,12 +715,13 @@ func (v *Codegen) genIfStat(n *parser.IfStat) {
 
 func (v *Codegen) genLoopStat(n *parser.LoopStat) {
 	curfn := v.currentFunction()
-	afterBlock := llvm.AddBasicBlock(v.currentLLVMFunction(), "loop_exit")
+	afterBlock := v.context.AddBasicBlock(v.currentLLVMFunction(), "loop_exit")
 	v.curLoopExits[curfn] = append(v.curLoopExits[curfn], afterBlock)
+	v.curLoopDepth[curfn] = append(v.curLoopDepth[curfn], len(v.inBlocks[curfn]))
 
 	switch n.LoopType {
 	case parser.LOOP_TYPE_INFINITE:
-		loopBlock := llvm.AddBasicBlock(v.currentLLVMFunction(), "loop_body")
+		loopBlock := v.context.AddBasicBlock(v.currentLLVMFunction(), "loop_body")
 		v.curLoopNexts[curfn] = append(v.curLoopNexts[curfn], loopBlock)
 		v.builder().CreateBr(loopBlock)
 		v.builder().SetInsertPointAtEnd(loopBlock)
@@ -551,11 +734,11 @@ func (v *Codegen) genLoopStat(n *parser.LoopStat) {
 
 		v.builder().SetInsertPointAtEnd(afterBlock)
 	case parser.LOOP_TYPE_CONDITIONAL:
-		evalBlock := llvm.AddBasicBlock(v.currentLLVMFunction(), "loop_condeval")
+		evalBlock := v.context.AddBasicBlock(v.currentLLVMFunction(), "loop_condeval")
 		v.builder().CreateBr(evalBlock)
 		v.curLoopNexts[curfn] = append(v.curLoopNexts[curfn], evalBlock)
 
-		loopBlock := llvm.AddBasicBlock(v.currentLLVMFunction(), "loop_body")
+		loopBlock := v.context.AddBasicBlock(v.currentLLVMFunction(), "loop_body")
 
 		v.builder().SetInsertPointAtEnd(evalBlock)
 		cond := v.genExpr(n.Condition)
@@ -575,10 +758,310 @@ func (v *Codegen) genLoopStat(n *parser.LoopStat) {
 
 	v.curLoopExits[curfn] = v.curLoopExits[curfn][:len(v.curLoopExits[curfn])-1]
 	v.curLoopNexts[curfn] = v.curLoopNexts[curfn][:len(v.curLoopNexts[curfn])-1]
+	v.curLoopDepth[curfn] = v.curLoopDepth[curfn][:len(v.curLoopDepth[curfn])-1]
 }
 
 func (v *Codegen) genMatchStat(n *parser.MatchStat) {
-	// TODO: implement
+	if !v.inFunction() {
+		panic("tried to gen match stat not in function")
+	}
+
+	statTerm := semantic.IsNodeTerminating(n)
+
+	var end llvm.BasicBlock
+	if !statTerm {
+		end = v.context.AddBasicBlock(v.currentLLVMFunction(), "match_end")
+	}
+
+	// arms after the first pattern that matches anything can never be
+	// reached, so we don't generate them
+	numArms := len(n.Patterns)
+	defaultArm := -1
+	for i, pattern := range n.Patterns {
+		if parser.IsIrrefutablePattern(pattern) {
+			numArms = i + 1
+			defaultArm = i
+			break
+		}
+	}
+
+	arms := make([]llvm.BasicBlock, numArms)
+	for i := range arms {
+		arms[i] = v.context.AddBasicBlock(v.currentLLVMFunction(), "match_arm")
+	}
+
+	noMatch := end
+	if defaultArm >= 0 {
+		noMatch = arms[defaultArm]
+	} else if statTerm {
+		// the match is exhaustive, so no value can get here
+		noMatch = v.context.AddBasicBlock(v.currentLLVMFunction(), "match_unreachable")
+
+		insertBlock := v.builder().GetInsertBlock()
+		v.builder().SetInsertPointAtEnd(noMatch)
+		v.builder().CreateUnreachable()
+		v.builder().SetInsertPointAtEnd(insertBlock)
+	}
+
+	target := v.genExpr(n.Target)
+
+	if canMatchWithSwitch(n, numArms) {
+		value := target
+		if enumType, ok := n.Target.GetType().ActualType().(parser.EnumType); ok {
+			// enums with data carrying members are matched on their tag
+			value = v.genEnumTag(enumType, target)
+		}
+
+		v.genMatchSwitch(n, value, arms, noMatch)
+	} else {
+		v.genMatchChain(n, target, arms, noMatch)
+	}
+
+	for i, arm := range arms {
+		v.builder().SetInsertPointAtEnd(arm)
+		v.genPatternBindings(n.Patterns[i], target)
+		v.genBlock(n.Bodies[i])
+
+		if !statTerm && !n.Bodies[i].IsTerminating && !isBreakOrNext(n.Bodies[i].LastNode()) {
+			v.builder().CreateBr(end)
+		}
+	}
+
+	if !statTerm {
+		end.MoveAfter(v.builder().GetInsertBlock())
+		v.builder().SetInsertPointAtEnd(end)
+	}
+}
+
+// A switch can be used when the target is integer-like or an enum, and each
+// pattern is either a constant or only looks at the enum member.
+func canMatchWithSwitch(n *parser.MatchStat, numArms int) bool {
+	targetType := n.Target.GetType().ActualType()
+	_, isEnum := targetType.(parser.EnumType)
+
+	if !targetType.IsIntegerType() && targetType != parser.PRIMITIVE_rune &&
+		targetType != parser.PRIMITIVE_bool && !isEnum {
+		return false
+	}
+
+	for _, pattern := range n.Patterns[:numArms] {
+		switch pattern := pattern.(type) {
+		case *parser.NumericLiteral, *parser.RuneLiteral, *parser.BoolLiteral, *parser.EnumLiteral:
+			// constant
+
+		case *parser.EnumPattern:
+			if pattern.Payload != nil && !parser.IsIrrefutablePattern(pattern.Payload) {
+				return false
+			}
+
+		default:
+			if !parser.IsIrrefutablePattern(pattern) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (v *Codegen) genMatchSwitch(n *parser.MatchStat, value llvm.Value, arms []llvm.BasicBlock, noMatch llvm.BasicBlock) {
+	cases := make(map[uint64]bool)
+	sw := v.builder().CreateSwitch(value, noMatch, len(arms))
+
+	for i, arm := range arms {
+		var caseValue llvm.Value
+
+		switch pattern := n.Patterns[i].(type) {
+		case *parser.EnumLiteral:
+			member := getEnumMember(pattern.Type, pattern.Member)
+			caseValue = llvm.ConstInt(value.Type(), uint64(member.Tag), false)
+
+		case *parser.EnumPattern:
+			member := getEnumMember(pattern.Type, pattern.Member)
+			caseValue = llvm.ConstInt(value.Type(), uint64(member.Tag), false)
+
+		default:
+			if parser.IsIrrefutablePattern(pattern) {
+				continue
+			}
+
+			caseValue = v.genExpr(pattern)
+			if !caseValue.IsConstant() {
+				v.err("Match pattern `%s` is not a constant", pattern.String())
+			}
+		}
+
+		// LLVM doesn't allow duplicate cases, and the first one wins anyways
+		if cases[caseValue.ZExtValue()] {
+			continue
+		}
+		cases[caseValue.ZExtValue()] = true
+
+		sw.AddCase(caseValue, arm)
+	}
+}
+
+func (v *Codegen) genMatchChain(n *parser.MatchStat, value llvm.Value, arms []llvm.BasicBlock, noMatch llvm.BasicBlock) {
+	for i, arm := range arms {
+		if parser.IsIrrefutablePattern(n.Patterns[i]) {
+			continue
+		}
+
+		next := v.context.AddBasicBlock(v.currentLLVMFunction(), "match_next")
+		v.genPatternTest(n.Patterns[i], value, next)
+		v.builder().CreateBr(arm)
+
+		next.MoveAfter(v.builder().GetInsertBlock())
+		v.builder().SetInsertPointAtEnd(next)
+	}
+
+	v.builder().CreateBr(noMatch)
+}
+
+// Generates code branching to fail if value doesn't match the pattern. If it
+// does match, code generation continues at the end of the current block.
+func (v *Codegen) genPatternTest(pattern parser.Expr, value llvm.Value, fail llvm.BasicBlock) {
+	switch pattern := pattern.(type) {
+	case *parser.DefaultMatchBranch, *parser.BindingPattern:
+		// matches anything
+
+	case *parser.TuplePattern:
+		for idx, elem := range pattern.Elements {
+			v.genPatternTest(elem, v.builder().CreateExtractValue(value, idx, ""), fail)
+		}
+
+	case *parser.StructPattern:
+		for idx, val := range pattern.Values {
+			v.genPatternTest(val, v.builder().CreateExtractValue(value, idx, ""), fail)
+		}
+
+	case *parser.EnumPattern:
+		enumType := pattern.Type.ActualType().(parser.EnumType)
+		member := getEnumMember(pattern.Type, pattern.Member)
+		v.genPatternCondBr(v.genEnumTagEquals(enumType, value, member), fail)
+
+		if pattern.Payload != nil {
+			v.genPatternTest(pattern.Payload, v.genEnumPayload(value, member), fail)
+		}
+
+	case *parser.EnumLiteral:
+		enumType := pattern.Type.ActualType().(parser.EnumType)
+		member := getEnumMember(pattern.Type, pattern.Member)
+		v.genPatternCondBr(v.genEnumTagEquals(enumType, value, member), fail)
+
+	default:
+		patternType := pattern.GetType().ActualType()
+		patternValue := v.genExpr(pattern)
+
+		var cond llvm.Value
+		if patternType.Equals(parser.ArrayOf(parser.PRIMITIVE_u8)) {
+			cond = v.genStringEquals(value, patternValue)
+		} else if patternType.IsFloatingType() {
+			cond = v.builder().CreateFCmp(llvm.FloatOEQ, value, patternValue, "")
+		} else {
+			cond = v.builder().CreateICmp(llvm.IntEQ, value, patternValue, "")
+		}
+
+		v.genPatternCondBr(cond, fail)
+	}
+}
+
+func (v *Codegen) genPatternCondBr(cond llvm.Value, fail llvm.BasicBlock) {
+	matched := v.context.AddBasicBlock(v.currentLLVMFunction(), "match_test")
+	matched.MoveAfter(v.builder().GetInsertBlock())
+
+	v.builder().CreateCondBr(cond, matched, fail)
+	v.builder().SetInsertPointAtEnd(matched)
+}
+
+// Stores the parts of value bound by the pattern in the variables of the pattern
+func (v *Codegen) genPatternBindings(pattern parser.Expr, value llvm.Value) {
+	switch pattern := pattern.(type) {
+	case *parser.BindingPattern:
+		alloc := v.createVariableAlloca(pattern.Variable, value.Type())
+		v.builder().CreateStore(value, alloc)
+		v.variableLookup[pattern.Variable] = alloc
+		v.debugDeclareVariable(pattern.Variable, alloc, pattern.Pos(), 0)
+
+	case *parser.TuplePattern:
+		for idx, elem := range pattern.Elements {
+			v.genPatternBindings(elem, v.builder().CreateExtractValue(value, idx, ""))
+		}
+
+	case *parser.StructPattern:
+		for idx, val := range pattern.Values {
+			v.genPatternBindings(val, v.builder().CreateExtractValue(value, idx, ""))
+		}
+
+	case *parser.EnumPattern:
+		if pattern.Payload != nil {
+			member := getEnumMember(pattern.Type, pattern.Member)
+			v.genPatternBindings(pattern.Payload, v.genEnumPayload(value, member))
+		}
+	}
+}
+
+func getEnumMember(typ parser.Type, name string) parser.EnumTypeMember {
+	member, ok := typ.ActualType().(parser.EnumType).GetMember(name)
+	if !ok {
+		panic("INTERNAL ERROR: No such enum member `" + name + "`")
+	}
+	return member
+}
+
+func (v *Codegen) genEnumTag(enumType parser.EnumType, value llvm.Value) llvm.Value {
+	if enumType.Simple {
+		return value
+	}
+	return v.builder().CreateExtractValue(value, 0, "")
+}
+
+func (v *Codegen) genEnumTagEquals(enumType parser.EnumType, value llvm.Value, member parser.EnumTypeMember) llvm.Value {
+	tag := v.genEnumTag(enumType, value)
+	return v.builder().CreateICmp(llvm.IntEQ, tag, llvm.ConstInt(tag.Type(), uint64(member.Tag), false), "")
+}
+
+// Reads the data of an enum member from the data field of the enum, see
+// enumTypeToLLVMTypeFields for the layout.
+func (v *Codegen) genEnumPayload(value llvm.Value, member parser.EnumTypeMember) llvm.Value {
+	alloc := v.createEntryAlloca(value.Type(), "")
+	v.builder().CreateStore(value, alloc)
+
+	dataGep := v.builder().CreateStructGEP(alloc, 1, "")
+	dataGep = v.builder().CreateBitCast(dataGep, llvm.PointerType(v.typeToLLVMType(member.Type), 0), "")
+
+	return v.builder().CreateLoad(dataGep, "")
+}
+
+// Compares the lengths of two strings, then their contents
+func (v *Codegen) genStringEquals(lhand, rhand llvm.Value) llvm.Value {
+	uintType := v.typeToLLVMType(parser.PRIMITIVE_uint)
+
+	lhandLen := v.builder().CreateExtractValue(lhand, 0, "")
+	rhandLen := v.builder().CreateExtractValue(rhand, 0, "")
+	lenEq := v.builder().CreateICmp(llvm.IntEQ, lhandLen, rhandLen, "")
+
+	// when the lengths differ we compare zero bytes, so we never read past the end of either string
+	numBytes := v.builder().CreateSelect(lenEq, lhandLen, llvm.ConstInt(uintType, 0, false), "")
+
+	lhandPtr := v.builder().CreateExtractValue(lhand, 1, "")
+	rhandPtr := v.builder().CreateExtractValue(rhand, 1, "")
+	cmp := v.builder().CreateCall(v.getMemcmp(), []llvm.Value{lhandPtr, rhandPtr, numBytes}, "")
+	bytesEq := v.builder().CreateICmp(llvm.IntEQ, cmp, llvm.ConstInt(cmp.Type(), 0, false), "")
+
+	return v.builder().CreateAnd(lenEq, bytesEq, "")
+}
+
+func (v *Codegen) getMemcmp() llvm.Value {
+	fn := v.curFile.LlvmModule.NamedFunction("memcmp")
+
+	if fn.IsNil() {
+		bytePtrType := llvm.PointerType(v.typeToLLVMType(parser.PRIMITIVE_u8), 0)
+		fnType := llvm.FunctionType(v.context.IntType(32), []llvm.Type{bytePtrType, bytePtrType, v.typeToLLVMType(parser.PRIMITIVE_uint)}, false)
+		fn = llvm.AddFunction(v.curFile.LlvmModule, "memcmp", fnType)
+	}
+
+	return fn
 }
 
 func (v *Codegen) genDefaultStat(n *parser.DefaultStat) {
@@ -611,19 +1094,21 @@ func (v *Codegen) genFunctionDecl(n *parser.FunctionDecl) llvm.Value {
 		// hmmmm seems we just ignore this here
 	} else {
 		if !n.Prototype {
-			v.genFunctionBody(n.Function, function)
+			v.genFunctionBody(n.Function, function, n.Pos())
+			v.addStructor(n.Function, function)
 		}
 	}
 
 	return res
 }
 
-func (v *Codegen) genFunctionBody(fn *parser.Function, llvmFn llvm.Value) {
-	block := llvm.AddBasicBlock(llvmFn, "entry")
+func (v *Codegen) genFunctionBody(fn *parser.Function, llvmFn llvm.Value, pos lexer.Position) {
+	block := v.context.AddBasicBlock(llvmFn, "entry")
 
 	v.pushFunction(fn)
-	v.builders[v.currentFunction()] = llvm.NewBuilder()
+	v.builders[v.currentFunction()] = v.context.NewBuilder()
 	v.builder().SetInsertPointAtEnd(block)
+	v.debugPushFunction(fn, llvmFn, pos)
 
 	pars := fn.Parameters
 
@@ -634,14 +1119,27 @@ func (v *Codegen) genFunctionBody(fn *parser.Function, llvmFn llvm.Value) {
 		pars = newPars
 	}
 
+	params := llvmFn.Params()
+	if len(fn.Captures) > 0 {
+		v.genCaptureAccess(fn, params[0])
+		params = params[1:]
+	}
+
 	for i, par := range pars {
-		alloc := v.builder().CreateAlloca(v.typeToLLVMType(par.Variable.Type), par.Variable.Name)
+		var alloc llvm.Value
+		if par.Variable.Boxed {
+			alloc = v.createVariableAlloca(par.Variable, v.typeToLLVMType(par.Variable.Type))
+		} else {
+			alloc = v.builder().CreateAlloca(v.typeToLLVMType(par.Variable.Type), par.Variable.Name)
+		}
 		v.variableLookup[par.Variable] = alloc
 
-		v.builder().CreateStore(llvmFn.Params()[i], alloc)
+		v.builder().CreateStore(params[i], alloc)
+		v.debugDeclareVariable(par.Variable, alloc, par.Pos(), i+1)
 	}
 
 	v.genBlock(fn.Body)
+	v.debugPopFunction(fn)
 	v.builder().Dispose()
 	delete(v.builders, v.currentFunction())
 	delete(v.curLoopExits, v.currentFunction())
@@ -653,27 +1151,10 @@ func (v *Codegen) genVariableDecl(n *parser.VariableDecl, semicolon bool) llvm.V
 	var res llvm.Value
 
 	if v.inFunction() {
-		mangledName := n.Variable.MangledName(parser.MANGLE_ARK_UNSTABLE)
-
-		funcEntry := v.currentLLVMFunction().EntryBasicBlock()
-
-		// use this builder() for the variable alloca
-		// this means all allocas go at the start of the function
-		// so each variable is only allocated once
-		allocBuilder := llvm.NewBuilder()
-
-		if funcEntry == v.builder().GetInsertBlock() {
-			allocBuilder.SetInsertPointAtEnd(funcEntry)
-		} else {
-			allocBuilder.SetInsertPointBefore(funcEntry.LastInstruction())
-		}
-
-		varType := v.typeToLLVMType(n.Variable.Type)
-		alloc := allocBuilder.CreateAlloca(varType, mangledName)
-
-		allocBuilder.Dispose()
+		alloc := v.createVariableAlloca(n.Variable, v.typeToLLVMType(n.Variable.Type))
 
 		v.variableLookup[n.Variable] = alloc
+		v.debugDeclareVariable(n.Variable, alloc, n.Pos(), 0)
 
 		if n.Assignment != nil {
 			if value := v.genExpr(n.Assignment); !value.IsNil() {
@@ -693,16 +1174,55 @@ func (v *Codegen) genVariableDecl(n *parser.VariableDecl, semicolon bool) llvm.V
 		if !cBinding && !n.IsPublic() {
 			value.SetLinkage(nonPublicLinkage)
 		}
+
 		value.SetGlobalConstant(!n.Variable.Mutable)
 		if n.Assignment != nil {
-			value.SetInitializer(v.genExpr(n.Assignment))
+			if n.Variable.IsConstant {
+				value.SetInitializer(v.genExpr(v.evaluateConstant(n.Assignment)))
+			} else if init, err := v.constantEvaluator().Evaluate(n.Assignment); err == nil {
+				value.SetInitializer(v.genExpr(init))
+			} else {
+				// set before main runs, see genStructors
+				value.SetInitializer(llvm.ConstNull(varType))
+				value.SetGlobalConstant(false)
+				v.curFile.initializedGlobals = append(v.curFile.initializedGlobals, n)
+			}
 		}
+		v.applyLinkageAttrs(value, n.Variable.Attrs, false)
 		v.variableLookup[n.Variable] = value
 	}
 
 	return res
 }
 
+// Allocates the storage of a local variable. Boxed variables start out on the
+// stack every time their declaration is reached, see genBoxedVariable.
+func (v *Codegen) createVariableAlloca(vari *parser.Variable, typ llvm.Type) llvm.Value {
+	name := vari.MangledName(parser.MANGLE_ARK_UNSTABLE)
+	alloc := v.createEntryAlloca(typ, name)
+	if vari.Boxed {
+		v.genBoxedVariable(vari, alloc)
+	}
+	return alloc
+}
+
+// Allocates a value at the start of the function, so that it is only
+// allocated once even if the alloca is reached multiple times.
+func (v *Codegen) createEntryAlloca(typ llvm.Type, name string) llvm.Value {
+	funcEntry := v.currentLLVMFunction().EntryBasicBlock()
+
+	allocBuilder := v.context.NewBuilder()
+	defer allocBuilder.Dispose()
+
+	if funcEntry == v.builder().GetInsertBlock() {
+		allocBuilder.SetInsertPointAtEnd(funcEntry)
+	} else {
+		allocBuilder.SetInsertPointBefore(funcEntry.LastInstruction())
+	}
+
+	return allocBuilder.CreateAlloca(typ, name)
+}
+
 func (v *Codegen) genExpr(n parser.Expr) llvm.Value {
 	switch n := n.(type) {
 	case *parser.AddressOfExpr:
@@ -729,6 +1249,8 @@ func (v *Codegen) genExpr(n parser.Expr) llvm.Value {
 		return v.genCastExpr(n)
 	case *parser.CallExpr:
 		return v.genCallExpr(n)
+	case *parser.AsmExpr:
+		return v.genAsmExpr(n)
 	case *parser.VariableAccessExpr, *parser.StructAccessExpr,
 		*parser.ArrayAccessExpr, *parser.TupleAccessExpr,
 		*parser.DerefAccessExpr, *parser.FunctionAccessExpr:
@@ -748,11 +1270,16 @@ func (v *Codegen) genExpr(n parser.Expr) llvm.Value {
 }
 
 func (v *Codegen) genLambdaExpr(n *parser.LambdaExpr) llvm.Value {
+	if len(n.Function.Captures) > 0 {
+		return v.genClosure(n)
+	}
+
 	typ := v.functionTypeToLLVMType(n.Function.Type, false)
 	mod := v.curFile.LlvmModule
 	fn := llvm.AddFunction(mod, fmt.Sprintf("_Lambda%d", v.nextLambdaID()), typ)
+	fn.SetLinkage(nonPublicLinkage)
 
-	v.genFunctionBody(n.Function, fn)
+	v.genFunctionBody(n.Function, fn, n.Pos())
 
 	return fn
 }
@@ -762,6 +1289,14 @@ func (v *Codegen) genAddressOfExpr(n *parser.AddressOfExpr) llvm.Value {
 }
 
 func (v *Codegen) genAccessExpr(n parser.Expr) llvm.Value {
+	// constants and their members are replaced by their value, so they can
+	// be used before they are declared
+	if v.isConstantAccess(n) {
+		if value, err := v.constantEvaluator().Evaluate(n); err == nil {
+			return v.genExpr(value)
+		}
+	}
+
 	if fae, ok := n.(*parser.FunctionAccessExpr); ok {
 		fnName := fae.Function.MangledName(parser.MANGLE_ARK_UNSTABLE)
 
@@ -800,7 +1335,7 @@ func (v *Codegen) genAccessGEP(n parser.Expr) llvm.Value {
 		if varType.IsNil() {
 			panic("varType was nil")
 		}
-		gep := v.builder().CreateGEP(varType, []llvm.Value{llvm.ConstInt(llvm.Int32Type(), 0, false)}, "")
+		gep := v.builder().CreateGEP(varType, []llvm.Value{llvm.ConstInt(v.context.Int32Type(), 0, false)}, "")
 
 		if _, ok := access.GetType().(parser.MutableReferenceType); ok {
 			return v.builder().CreateLoad(gep, "")
@@ -821,11 +1356,15 @@ func (v *Codegen) genAccessGEP(n parser.Expr) llvm.Value {
 		return v.builder().CreateStructGEP(gep, index, "")
 
 	case *parser.ArrayAccessExpr:
+		if vec, ok := access.Array.GetType().ActualType().(parser.VectorType); ok {
+			return v.genVectorElementGEP(access, vec)
+		}
+
 		gep := v.genAccessGEP(access.Array)
 		subscriptExpr := v.genExpr(access.Subscript)
 
 		v.genBoundsCheck(v.builder().CreateLoad(v.builder().CreateStructGEP(gep, 0, ""), ""),
-			subscriptExpr, access.Subscript.GetType())
+			subscriptExpr, access.Subscript.GetType(), access.Pos())
 
 		gep = v.builder().CreateStructGEP(gep, 1, "")
 
@@ -841,20 +1380,24 @@ func (v *Codegen) genAccessGEP(n parser.Expr) llvm.Value {
 		return v.builder().CreateStructGEP(gep, int(access.Index), "")
 
 	case *parser.DerefAccessExpr:
-		return v.genExpr(access.Expr)
+		ptr := v.genExpr(access.Expr)
+		if v.nullChecked(access.Expr.GetType()) {
+			v.genNullCheck(access, ptr)
+		}
+		return ptr
 
 	default:
 		panic("unhandled access type")
 	}
 }
 
-func (v *Codegen) genBoundsCheck(limit llvm.Value, index llvm.Value, indexType parser.Type) {
-	segvBlock := llvm.AddBasicBlock(v.currentLLVMFunction(), "boundscheck_segv")
-	endBlock := llvm.AddBasicBlock(v.currentLLVMFunction(), "boundscheck_end")
-	upperCheckBlock := llvm.AddBasicBlock(v.currentLLVMFunction(), "boundscheck_upper_block")
+func (v *Codegen) genBoundsCheck(limit llvm.Value, index llvm.Value, indexType parser.Type, pos lexer.Position) {
+	failBlock := v.context.AddBasicBlock(v.currentLLVMFunction(), "boundscheck_fail")
+	endBlock := v.context.AddBasicBlock(v.currentLLVMFunction(), "boundscheck_end")
+	upperCheckBlock := v.context.AddBasicBlock(v.currentLLVMFunction(), "boundscheck_upper_block")
 
 	tooLow := v.builder().CreateICmp(llvm.IntSGT, llvm.ConstInt(index.Type(), 0, false), index, "boundscheck_lower")
-	v.builder().CreateCondBr(tooLow, segvBlock, upperCheckBlock)
+	v.builder().CreateCondBr(tooLow, failBlock, upperCheckBlock)
 
 	v.builder().SetInsertPointAtEnd(upperCheckBlock)
 
@@ -872,27 +1415,15 @@ func (v *Codegen) genBoundsCheck(limit llvm.Value, index llvm.Value, indexType p
 	}
 
 	tooHigh := v.builder().CreateICmp(llvm.IntSLE, castedLimit, castedIndex, "boundscheck_upper")
-	v.builder().CreateCondBr(tooHigh, segvBlock, endBlock)
+	v.builder().CreateCondBr(tooHigh, failBlock, endBlock)
 
-	v.builder().SetInsertPointAtEnd(segvBlock)
-	v.genRaiseSegfault()
-	v.builder().CreateUnreachable()
+	v.builder().SetInsertPointAtEnd(failBlock)
+	v.genPanic(pos, "index "+panicIntFormat(indexType)+" out of range [0, %llu)",
+		v.genPanicInt(index, indexType), v.genPanicInt(limit, parser.PRIMITIVE_uint))
 
 	v.builder().SetInsertPointAtEnd(endBlock)
 }
 
-func (v *Codegen) genRaiseSegfault() {
-	fn := v.curFile.LlvmModule.NamedFunction("raise")
-	intType := v.typeToLLVMType(parser.PRIMITIVE_int)
-
-	if fn.IsNil() {
-		fnType := llvm.FunctionType(intType, []llvm.Type{intType}, false)
-		fn = llvm.AddFunction(v.curFile.LlvmModule, "raise", fnType)
-	}
-
-	v.builder().CreateCall(fn, []llvm.Value{llvm.ConstInt(intType, 11, false)}, "segfault")
-}
-
 func (v *Codegen) genBoolLiteral(n *parser.BoolLiteral) llvm.Value {
 	var num uint64
 
@@ -920,7 +1451,7 @@ func (v *Codegen) genStringLiteral(n *parser.StringLiteral) llvm.Value {
 	if v.inFunction() {
 		// allocate backing array
 		backingArray := v.builder().CreateAlloca(llvm.ArrayType(memberLLVMType, length), "stackstr")
-		v.builder().CreateStore(llvm.ConstString(n.Value, nullTerm), backingArray)
+		v.builder().CreateStore(v.context.ConstString(n.Value, nullTerm), backingArray)
 
 		backingArrayPointer = v.builder().CreateBitCast(backingArray, llvm.PointerType(memberLLVMType, 0), "")
 	} else {
@@ -930,7 +1461,7 @@ func (v *Codegen) genStringLiteral(n *parser.StringLiteral) llvm.Value {
 		backingArray := llvm.AddGlobal(v.curFile.LlvmModule, llvm.ArrayType(memberLLVMType, length), backName)
 		backingArray.SetLinkage(llvm.InternalLinkage)
 		backingArray.SetGlobalConstant(false)
-		backingArray.SetInitializer(llvm.ConstString(n.Value, nullTerm))
+		backingArray.SetInitializer(v.context.ConstString(n.Value, nullTerm))
 
 		backingArrayPointer = llvm.ConstBitCast(backingArray, llvm.PointerType(memberLLVMType, 0))
 	}
@@ -952,6 +1483,8 @@ func (v *Codegen) genCompositeLiteral(n *parser.CompositeLiteral) llvm.Value {
 		return v.genArrayLiteral(n)
 	case parser.StructType:
 		return v.genStructLiteral(n)
+	case parser.VectorType:
+		return v.genVectorLiteral(n)
 	default:
 		panic("invalid composite literal type")
 	}
@@ -962,7 +1495,9 @@ func (v *Codegen) genArrayLiteral(n *parser.CompositeLiteral) llvm.Value {
 	arrayLLVMType := v.typeToLLVMType(n.Type)
 	memberLLVMType := v.typeToLLVMType(n.Type.ActualType().(parser.ArrayType).MemberType)
 
-	arrayValues := make([]llvm.Value, len(n.Values))
+	length := v.arrayLiteralLength(n)
+
+	arrayValues := make([]llvm.Value, len(n.Values), length)
 	for idx, mem := range n.Values {
 		value := v.genExpr(mem)
 		if !v.inFunction() && !value.IsConstant() {
@@ -971,12 +1506,18 @@ func (v *Codegen) genArrayLiteral(n *parser.CompositeLiteral) llvm.Value {
 		arrayValues[idx] = value
 	}
 
-	lengthValue := llvm.ConstInt(v.typeToLLVMType(parser.PRIMITIVE_uint), uint64(len(n.Values)), false)
+	lengthValue := llvm.ConstInt(v.typeToLLVMType(parser.PRIMITIVE_uint), uint64(length), false)
 	var backingArrayPointer llvm.Value
 
 	if v.inFunction() {
 		// allocate backing array
-		backingArray := v.builder().CreateAlloca(llvm.ArrayType(memberLLVMType, len(n.Values)), "")
+		backingArrayType := llvm.ArrayType(memberLLVMType, length)
+		backingArray := v.builder().CreateAlloca(backingArrayType, "")
+
+		// the elements without a value are zeroed
+		if length > len(n.Values) {
+			v.builder().CreateStore(llvm.ConstNull(backingArrayType), backingArray)
+		}
 
 		// copy the constant array to the backing array
 		for idx, value := range arrayValues {
@@ -989,7 +1530,11 @@ func (v *Codegen) genArrayLiteral(n *parser.CompositeLiteral) llvm.Value {
 		backName := fmt.Sprintf("_globarr_back_%d", v.arrayIndex)
 		v.arrayIndex++
 
-		backingArray := llvm.AddGlobal(v.curFile.LlvmModule, llvm.ArrayType(memberLLVMType, len(n.Values)), backName)
+		for len(arrayValues) < length {
+			arrayValues = append(arrayValues, llvm.ConstNull(memberLLVMType))
+		}
+
+		backingArray := llvm.AddGlobal(v.curFile.LlvmModule, llvm.ArrayType(memberLLVMType, length), backName)
 		backingArray.SetLinkage(llvm.InternalLinkage)
 		backingArray.SetGlobalConstant(false)
 		backingArray.SetInitializer(llvm.ConstArray(memberLLVMType, arrayValues))
@@ -1054,7 +1599,7 @@ func (v *Codegen) genEnumLiteral(n *parser.EnumLiteral) llvm.Value {
 	}
 
 	// TODO: Handle other integer size, maybe dynamic depending on max value?
-	tagValue := llvm.ConstInt(llvm.IntType(32), uint64(member.Tag), false)
+	tagValue := llvm.ConstInt(v.context.IntType(32), uint64(member.Tag), false)
 
 	enumValue := llvm.Undef(enumLLVMType)
 	enumValue = v.builder().CreateInsertValue(enumValue, tagValue, 0, "")
@@ -1099,8 +1644,8 @@ func (v *Codegen) genNumericLiteral(n *parser.NumericLiteral) llvm.Value {
 func (v *Codegen) genLogicalBinop(n *parser.BinaryExpr) llvm.Value {
 	and := n.Op == parser.BINOP_LOG_AND
 
-	next := llvm.AddBasicBlock(v.currentLLVMFunction(), "and_next")
-	exit := llvm.AddBasicBlock(v.currentLLVMFunction(), "and_exit")
+	next := v.context.AddBasicBlock(v.currentLLVMFunction(), "and_next")
+	exit := v.context.AddBasicBlock(v.currentLLVMFunction(), "and_exit")
 
 	b1 := v.genExpr(n.Lhand)
 	first := v.builder().GetInsertBlock()
@@ -1125,7 +1670,7 @@ func (v *Codegen) genLogicalBinop(n *parser.BinaryExpr) llvm.Value {
 		testIncVal = 1
 	}
 
-	phi.AddIncoming([]llvm.Value{llvm.ConstInt(llvm.IntType(1), testIncVal, false), b2}, []llvm.BasicBlock{first, next})
+	phi.AddIncoming([]llvm.Value{llvm.ConstInt(v.context.IntType(1), testIncVal, false), b2}, []llvm.BasicBlock{first, next})
 
 	return phi
 }
@@ -1138,30 +1683,38 @@ func (v *Codegen) genBinaryExpr(n *parser.BinaryExpr) llvm.Value {
 	lhand := v.genExpr(n.Lhand)
 	rhand := v.genExpr(n.Rhand)
 
-	return v.genBinop(n.Op, n.GetType(), n.Lhand.GetType(), n.Rhand.GetType(), lhand, rhand)
+	return v.genBinop(n.Op, n.GetType(), n.Lhand.GetType(), n.Rhand.GetType(), lhand, rhand, n.Pos())
 }
 
-func (v *Codegen) genBinop(operator parser.BinOpType, resType, lhandType, rhandType parser.Type, lhand, rhand llvm.Value) llvm.Value {
+func (v *Codegen) genBinop(operator parser.BinOpType, resType, lhandType, rhandType parser.Type, lhand, rhand llvm.Value, pos lexer.Position) llvm.Value {
 	if lhand.IsNil() || rhand.IsNil() {
 		v.err("invalid binary expr")
+	} else if vec, ok := lhandType.ActualType().(parser.VectorType); ok {
+		return v.genVectorBinop(operator, vec, lhand, rhand)
 	} else {
 		switch operator {
 		// Arithmetic
 		case parser.BINOP_ADD:
 			if resType.IsFloatingType() {
 				return v.builder().CreateFAdd(lhand, rhand, "")
+			} else if v.overflowChecked(resType) {
+				return v.genCheckedArith(operator, resType, lhandType, rhandType, lhand, rhand, pos)
 			} else {
 				return v.builder().CreateAdd(lhand, rhand, "")
 			}
 		case parser.BINOP_SUB:
 			if resType.IsFloatingType() {
 				return v.builder().CreateFSub(lhand, rhand, "")
+			} else if v.overflowChecked(resType) {
+				return v.genCheckedArith(operator, resType, lhandType, rhandType, lhand, rhand, pos)
 			} else {
 				return v.builder().CreateSub(lhand, rhand, "")
 			}
 		case parser.BINOP_MUL:
 			if resType.IsFloatingType() {
 				return v.builder().CreateFMul(lhand, rhand, "")
+			} else if v.overflowChecked(resType) {
+				return v.genCheckedArith(operator, resType, lhandType, rhandType, lhand, rhand, pos)
 			} else {
 				return v.builder().CreateMul(lhand, rhand, "")
 			}
@@ -1169,6 +1722,10 @@ func (v *Codegen) genBinop(operator parser.BinOpType, resType, lhandType, rhandT
 			if resType.IsFloatingType() {
 				return v.builder().CreateFDiv(lhand, rhand, "")
 			} else {
+				if v.overflowChecked(resType) {
+					v.genDivisionCheck(operator, resType, lhandType, rhandType, lhand, rhand, pos)
+				}
+
 				if resType.(parser.PrimitiveType).IsSigned() {
 					return v.builder().CreateSDiv(lhand, rhand, "")
 				} else {
@@ -1179,6 +1736,10 @@ func (v *Codegen) genBinop(operator parser.BinOpType, resType, lhandType, rhandT
 			if resType.IsFloatingType() {
 				return v.builder().CreateFRem(lhand, rhand, "")
 			} else {
+				if v.overflowChecked(resType) {
+					v.genDivisionCheck(operator, resType, lhandType, rhandType, lhand, rhand, pos)
+				}
+
 				if resType.(parser.PrimitiveType).IsSigned() {
 					return v.builder().CreateSRem(lhand, rhand, "")
 				} else {
@@ -1300,6 +1861,12 @@ func (v *Codegen) genCastExpr(n *parser.CastExpr) llvm.Value {
 	exprType := n.Expr.GetType()
 	castType := n.GetType()
 
+	if _, ok := castType.ActualType().(parser.InterfaceType); ok {
+		return v.genInterfaceConversion(n.Expr, castType)
+	} else if parser.ConvertsToClosure(exprType, castType) {
+		return v.genClosureConversion(n.Expr, castType.ActualType().(parser.FunctionType))
+	}
+
 	if exprType.IsIntegerType() || exprType == parser.PRIMITIVE_rune {
 		if _, ok := castType.(parser.PointerType); ok {
 			if _, ok := exprType.(parser.PointerType); ok {
@@ -1367,7 +1934,17 @@ func (v *Codegen) genCallExprWithArgs(n *parser.CallExpr, args []llvm.Value) llv
 }
 
 func (v *Codegen) genCallExpr(n *parser.CallExpr) llvm.Value {
+	if n.ReceiverAccess != nil {
+		if _, ok := n.ReceiverAccess.GetType().ActualType().(parser.InterfaceType); ok {
+			return v.genInterfaceCall(n)
+		}
+	}
+
 	fnType := n.Function.GetType().(parser.FunctionType)
+	if fnType.IsClosure {
+		return v.genClosureCall(n)
+	}
+
 	numArgs := len(n.Arguments)
 	if fnType.Receiver != nil {
 		numArgs++
@@ -1387,10 +1964,14 @@ func (v *Codegen) genCallExpr(n *parser.CallExpr) llvm.Value {
 }
 
 func (v *Codegen) genArrayLenExpr(n *parser.ArrayLenExpr) llvm.Value {
+	if vec, ok := n.Expr.GetType().ActualType().(parser.VectorType); ok {
+		return llvm.ConstInt(v.typeToLLVMType(parser.PRIMITIVE_uint), uint64(vec.Length), false)
+	}
+
 	if arrayLit, ok := n.Expr.(*parser.CompositeLiteral); ok {
-		arrayLen := len(arrayLit.Values)
+		arrayLen := v.arrayLiteralLength(arrayLit)
 
-		return llvm.ConstInt(llvm.IntType(64), uint64(arrayLen), false)
+		return llvm.ConstInt(v.typeToLLVMType(parser.PRIMITIVE_uint), uint64(arrayLen), false)
 	}
 
 	gep := v.genAccessGEP(n.Expr)
@@ -1407,7 +1988,7 @@ func (v *Codegen) genSizeofExpr(n *parser.SizeofExpr) llvm.Value {
 		typ = v.typeToLLVMType(n.Type)
 	}
 
-	return llvm.ConstInt(v.targetData.IntPtrType(), v.targetData.TypeAllocSize(typ), false)
+	return llvm.ConstInt(v.intPtrType(), v.targetData.TypeAllocSize(typ), false)
 }
 
 func (v *Codegen) genDefaultExpr(n *parser.DefaultExpr) llvm.Value {
@@ -1432,7 +2013,7 @@ func (v *Codegen) genDefaultValue(typ parser.Type) llvm.Value {
 		for idx, member := range tupleType.Members {
 			values[idx] = v.genDefaultValue(member)
 		}
-		return llvm.ConstStruct(values, false)
+		return v.context.ConstStruct(values, false)
 	}
 
 	if atyp.IsIntegerType() || atyp == parser.PRIMITIVE_bool {
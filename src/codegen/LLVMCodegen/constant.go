@@ -0,0 +1,78 @@
+package LLVMCodegen
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ark-lang/ark/src/parser"
+	"github.com/ark-lang/ark/src/util"
+	"github.com/ark-lang/ark/src/util/log"
+)
+
+// Constants, the initial values of global variables and the lengths of array
+// literals are evaluated at compile time. The semantic analysis already checked
+// that they can be, except for values depending on the size of types, which
+// are only known here.
+
+func (v *Codegen) constantEvaluator() *parser.ConstantEvaluator {
+	if v.constants == nil {
+		v.constants = &parser.ConstantEvaluator{
+			Sizeof: func(typ parser.Type) uint64 {
+				return v.targetData.TypeAllocSize(v.typeToLLVMType(typ))
+			},
+		}
+	}
+	return v.constants
+}
+
+// Returns the literal the expression evaluates to
+func (v *Codegen) evaluateConstant(n parser.Expr) parser.Expr {
+	res, err := v.constantEvaluator().Evaluate(n)
+	if err != nil {
+		cerr := err.(*parser.ConstantError)
+		v.errPos(cerr.Node, "%s", cerr.Msg)
+	}
+	return res
+}
+
+// Whether the expression accesses a constant, or a member of one
+func (v *Codegen) isConstantAccess(n parser.Expr) bool {
+	switch n := n.(type) {
+	case *parser.VariableAccessExpr:
+		return n.Variable.IsConstant
+	case *parser.StructAccessExpr:
+		return v.isConstantAccess(n.Struct)
+	case *parser.TupleAccessExpr:
+		return v.isConstantAccess(n.Tuple)
+	case *parser.ArrayAccessExpr:
+		return v.isConstantAccess(n.Array)
+	}
+	return false
+}
+
+// Returns the number of elements of an array literal
+func (v *Codegen) arrayLiteralLength(n *parser.CompositeLiteral) int {
+	if n.Length == nil {
+		return len(n.Values)
+	}
+
+	length, err := v.constantEvaluator().EvaluateInt(n.Length)
+	if err != nil {
+		cerr := err.(*parser.ConstantError)
+		v.errPos(cerr.Node, "%s", cerr.Msg)
+	}
+
+	// only lengths depending on the size of a type aren't checked in semantic
+	// analysis
+	if length.Sign() < 0 || !length.IsInt64() || length.Int64() < int64(len(n.Values)) {
+		v.errPos(n.Length, "Invalid length %s for array literal with %d values", length, len(n.Values))
+	}
+	return int(length.Int64())
+}
+
+func (v *Codegen) errPos(n parser.Locatable, err string, stuff ...interface{}) {
+	pos := n.Pos()
+	log.Error("codegen", util.TEXT_RED+util.TEXT_BOLD+"error:"+util.TEXT_RESET+" [%s:%d:%d] %s\n",
+		pos.Filename, pos.Line, pos.Char, fmt.Sprintf(err, stuff...))
+	os.Exit(util.EXIT_FAILURE_CODEGEN)
+}
@@ -0,0 +1,409 @@
+package LLVMCodegen
+
+import (
+	"path/filepath"
+	"strconv"
+
+	"github.com/ark-lang/ark/src/lexer"
+	"github.com/ark-lang/ark/src/parser"
+	"llvm.org/llvm/bindings/go/llvm"
+)
+
+// DWARF has no language code for Ark, so we claim to be C
+const dwarfLanguage llvm.DwarfLang = 0x000c // DW_LANG_C99
+
+const (
+	dwarfVersion             = 4
+	debugMetadataVersion     = 2
+	moduleFlagWarning        = 2
+	moduleFlagError          = 1
+	debugInfoProducerName    = "ark"
+	debugInfoEnumTagName     = "tag"
+	debugInfoEnumDataName    = "data"
+	debugInfoArrayLenName    = "length"
+	debugInfoArrayDataName   = "data"
+	debugInfoIfaceDataName   = "data"
+	debugInfoIfaceVtableName = "vtable"
+
+	debugInfoClosureFunctionName = "function"
+	debugInfoClosureEnvName      = "env"
+)
+
+type debugInfo struct {
+	builder     *llvm.DIBuilder
+	compileUnit llvm.Value
+	file        llvm.Value // the file of the submodule currently being generated
+
+	scopes map[*parser.Function][]llvm.Value // the lexical scopes we are in, per function
+	types  map[string]llvm.Value             // debug types of named types, by mangled name
+}
+
+func (v *Codegen) debugEnterModule(mod *WrappedModule) {
+	if !v.DebugInfo {
+		return
+	}
+
+	v.debug = &debugInfo{
+		builder: llvm.NewDIBuilder(mod.LlvmModule),
+		scopes:  make(map[*parser.Function][]llvm.Value),
+		types:   make(map[string]llvm.Value),
+	}
+
+	v.debug.compileUnit = v.debug.builder.CreateCompileUnit(llvm.DICompileUnit{
+		Language:  dwarfLanguage,
+		File:      mod.Name.String(),
+		Dir:       mod.Dirpath,
+		Producer:  debugInfoProducerName,
+		Optimized: v.OptLevel > 0,
+	})
+}
+
+func (v *Codegen) debugExitModule(mod *WrappedModule) {
+	if !v.DebugInfo {
+		return
+	}
+
+	v.debug.builder.Finalize()
+	v.debug.builder.Destroy()
+
+	mod.LlvmModule.AddNamedMetadataOperand("llvm.module.flags", v.context.MDNode([]llvm.Value{
+		llvm.ConstInt(v.context.Int32Type(), moduleFlagWarning, false),
+		v.context.MDString("Dwarf Version"),
+		llvm.ConstInt(v.context.Int32Type(), dwarfVersion, false),
+	}))
+	mod.LlvmModule.AddNamedMetadataOperand("llvm.module.flags", v.context.MDNode([]llvm.Value{
+		llvm.ConstInt(v.context.Int32Type(), moduleFlagError, false),
+		v.context.MDString("Debug Info Version"),
+		llvm.ConstInt(v.context.Int32Type(), debugMetadataVersion, false),
+	}))
+
+	v.debug = nil
+}
+
+func (v *Codegen) debugEnterSubmodule(submod *parser.Submodule) {
+	if !v.DebugInfo {
+		return
+	}
+
+	dir, file := filepath.Split(submod.File.Path)
+	v.debug.file = v.debug.builder.CreateFile(file, dir)
+}
+
+func (v *Codegen) debugPushFunction(fn *parser.Function, llvmFn llvm.Value, pos lexer.Position) {
+	if !v.DebugInfo {
+		return
+	}
+
+	subprogram := v.debug.builder.CreateFunction(v.debug.file, llvm.DIFunction{
+		Name:         fn.Name,
+		LinkageName:  llvmFn.Name(),
+		File:         v.debug.file,
+		Line:         pos.Line,
+		Type:         v.debugFunctionType(fn.Type),
+		LocalToUnit:  llvmFn.Linkage() == nonPublicLinkage,
+		IsDefinition: true,
+		ScopeLine:    pos.Line,
+		Optimized:    v.OptLevel > 0,
+		Function:     llvmFn,
+	})
+
+	v.debug.scopes[fn] = []llvm.Value{subprogram}
+}
+
+func (v *Codegen) debugPopFunction(fn *parser.Function) {
+	if !v.DebugInfo {
+		return
+	}
+
+	delete(v.debug.scopes, fn)
+}
+
+func (v *Codegen) debugPushBlock(block *parser.Block) {
+	if !v.DebugInfo {
+		return
+	}
+
+	fn := v.currentFunction()
+	pos := block.Pos()
+
+	scope := v.debug.builder.CreateLexicalBlock(v.debugScope(), llvm.DILexicalBlock{
+		File:   v.debug.file,
+		Line:   pos.Line,
+		Column: pos.Char,
+	})
+	v.debug.scopes[fn] = append(v.debug.scopes[fn], scope)
+}
+
+func (v *Codegen) debugPopBlock() {
+	if !v.DebugInfo {
+		return
+	}
+
+	fn := v.currentFunction()
+	v.debug.scopes[fn] = v.debug.scopes[fn][:len(v.debug.scopes[fn])-1]
+}
+
+func (v *Codegen) debugScope() llvm.Value {
+	scopes := v.debug.scopes[v.currentFunction()]
+	return scopes[len(scopes)-1]
+}
+
+// Attaches the position of the node to all instructions generated after this
+func (v *Codegen) debugSetLocation(n parser.Node) {
+	if !v.DebugInfo || !v.inFunction() {
+		return
+	}
+
+	pos := n.Pos()
+	v.builder().SetCurrentDebugLocation(v.context.MDNode([]llvm.Value{
+		llvm.ConstInt(v.context.Int32Type(), uint64(pos.Line), false),
+		llvm.ConstInt(v.context.Int32Type(), uint64(pos.Char), false),
+		v.debugScope(),
+		llvm.Value{},
+	}))
+}
+
+// argNo is the 1-based index of the variable in the parameter list, or 0 if
+// it isn't a parameter
+func (v *Codegen) debugDeclareVariable(vari *parser.Variable, storage llvm.Value, pos lexer.Position, argNo int) {
+	if !v.DebugInfo {
+		return
+	}
+
+	tag := llvm.DW_TAG_auto_variable
+	if argNo > 0 {
+		tag = llvm.DW_TAG_arg_variable
+	}
+
+	diVar := v.debug.builder.CreateLocalVariable(v.debugScope(), llvm.DILocalVariable{
+		Tag:   tag,
+		Name:  vari.Name,
+		File:  v.debug.file,
+		Line:  pos.Line,
+		Type:  v.debugType(vari.Type),
+		ArgNo: argNo,
+	})
+
+	v.debug.builder.InsertDeclareAtEnd(storage, diVar, v.debug.builder.CreateExpression(nil), v.builder().GetInsertBlock())
+}
+
+func (v *Codegen) debugFunctionType(typ parser.FunctionType) llvm.Value {
+	// the first type is the return type, a null value is void
+	params := []llvm.Value{llvm.Value{}}
+	if typ.Return != nil && !typ.Return.IsVoidType() {
+		params[0] = v.debugType(typ.Return)
+	}
+
+	if typ.Receiver != nil {
+		params = append(params, v.debugType(typ.Receiver))
+	}
+	for _, par := range typ.Parameters {
+		params = append(params, v.debugType(par))
+	}
+
+	return v.debug.builder.CreateSubroutineType(llvm.DISubroutineType{
+		File:       v.debug.file,
+		Parameters: params,
+	})
+}
+
+func (v *Codegen) debugType(typ parser.Type) llvm.Value {
+	switch typ := typ.(type) {
+	case parser.PrimitiveType:
+		if typ == parser.PRIMITIVE_void {
+			return llvm.Value{}
+		}
+		return v.debugBasicType(typ.TypeName(), v.typeToLLVMType(typ), primitiveTypeEncoding(typ))
+
+	case parser.PointerType:
+		return v.debugPointerType(typ.Addressee)
+
+	case parser.MutableReferenceType:
+		return v.debugPointerType(typ.Referrer)
+
+	case parser.ConstantReferenceType:
+		return v.debugPointerType(typ.Referrer)
+
+	case parser.FunctionType:
+		if typ.IsClosure {
+			bytePtr := v.debugPointerType(parser.PRIMITIVE_u8)
+			return v.debugStructType(typ.TypeName(), v.closureTypeToLLVMType(typ),
+				[]string{debugInfoClosureFunctionName, debugInfoClosureEnvName},
+				[]llvm.Value{bytePtr, bytePtr})
+		}
+
+		llvmType := v.functionTypeToLLVMType(typ, true)
+		return v.debug.builder.CreatePointerType(llvm.DIPointerType{
+			Pointee:     v.debugFunctionType(typ),
+			SizeInBits:  v.targetData.TypeSizeInBits(llvmType),
+			AlignInBits: uint64(v.targetData.ABITypeAlignment(llvmType)) * 8,
+		})
+
+	case parser.ArrayType:
+		return v.debugStructType(typ.TypeName(), v.typeToLLVMType(typ),
+			[]string{debugInfoArrayLenName, debugInfoArrayDataName},
+			[]llvm.Value{v.debugType(parser.PRIMITIVE_uint), v.debugPointerType(typ.MemberType)})
+
+	case parser.VectorType:
+		// the DIBuilder has no vector types, debuggers see a fixed size array
+		llvmType := v.typeToLLVMType(typ)
+		return v.debug.builder.CreateArrayType(llvm.DIArrayType{
+			SizeInBits:  v.targetData.TypeSizeInBits(llvmType),
+			AlignInBits: uint64(v.targetData.ABITypeAlignment(llvmType)) * 8,
+			ElementType: v.debugType(typ.MemberType),
+			Subscripts:  []llvm.DISubrange{{Lo: 0, Count: int64(typ.Length)}},
+		})
+
+	case parser.TupleType:
+		names := make([]string, len(typ.Members))
+		types := make([]llvm.Value, len(typ.Members))
+		for idx, mem := range typ.Members {
+			names[idx] = strconv.Itoa(idx)
+			types[idx] = v.debugType(mem)
+		}
+		return v.debugStructType(typ.TypeName(), v.typeToLLVMType(typ), names, types)
+
+	case parser.StructType:
+		return v.debugNamedStructType(typ.TypeName(), typ)
+
+	case parser.EnumType:
+		return v.debugEnumType(typ.TypeName(), typ)
+
+	case parser.InterfaceType:
+		return v.debugInterfaceType(typ.TypeName(), typ)
+
+	case *parser.NamedType:
+		return v.debugNamedType(typ)
+	}
+
+	return llvm.Value{}
+}
+
+func (v *Codegen) debugNamedType(typ *parser.NamedType) llvm.Value {
+	mangledName := typ.MangledName(parser.MANGLE_ARK_UNSTABLE)
+	if res, ok := v.debug.types[mangledName]; ok {
+		// this is a null value while the type is being created, so a
+		// recursive type refers to itself as void
+		return res
+	}
+	v.debug.types[mangledName] = llvm.Value{}
+
+	var res llvm.Value
+	switch actual := typ.Type.(type) {
+	case parser.StructType:
+		res = v.debugNamedStructType(typ.Name, actual)
+
+	case parser.EnumType:
+		res = v.debugEnumType(typ.Name, actual)
+
+	case parser.InterfaceType:
+		res = v.debugInterfaceType(typ.Name, actual)
+
+	default:
+		res = v.debug.builder.CreateTypedef(llvm.DITypedef{
+			Type:    v.debugType(typ.Type),
+			Name:    typ.Name,
+			File:    v.debug.file,
+			Context: v.debug.compileUnit,
+		})
+	}
+
+	v.debug.types[mangledName] = res
+	return res
+}
+
+func (v *Codegen) debugNamedStructType(name string, typ parser.StructType) llvm.Value {
+	names := make([]string, len(typ.Variables))
+	types := make([]llvm.Value, len(typ.Variables))
+	for idx, decl := range typ.Variables {
+		names[idx] = decl.Variable.Name
+		types[idx] = v.debugType(decl.Variable.Type)
+	}
+	return v.debugStructType(name, v.structTypeToLLVMType(typ), names, types)
+}
+
+func (v *Codegen) debugEnumType(name string, typ parser.EnumType) llvm.Value {
+	if typ.Simple {
+		return v.debugBasicType(name, v.enumTypeToLLVMType(typ), llvm.DW_ATE_unsigned)
+	}
+
+	// see enumTypeToLLVMTypeFields for the layout
+	llvmType := v.enumTypeToLLVMType(typ)
+	dataType := llvmType.StructElementTypes()[1]
+
+	data := v.debug.builder.CreateArrayType(llvm.DIArrayType{
+		SizeInBits:  v.targetData.TypeSizeInBits(dataType),
+		AlignInBits: uint64(v.targetData.ABITypeAlignment(dataType)) * 8,
+		ElementType: v.debugType(parser.PRIMITIVE_u8),
+		Subscripts:  []llvm.DISubrange{{Lo: 0, Count: int64(dataType.ArrayLength())}},
+	})
+
+	return v.debugStructType(name, llvmType,
+		[]string{debugInfoEnumTagName, debugInfoEnumDataName},
+		[]llvm.Value{v.debugType(parser.PRIMITIVE_u32), data})
+}
+
+// see interfaceTypeToLLVMType for the layout
+func (v *Codegen) debugInterfaceType(name string, typ parser.InterfaceType) llvm.Value {
+	bytePtr := v.debugPointerType(parser.PRIMITIVE_u8)
+	return v.debugStructType(name, v.interfaceTypeToLLVMType(typ),
+		[]string{debugInfoIfaceDataName, debugInfoIfaceVtableName},
+		[]llvm.Value{bytePtr, bytePtr})
+}
+
+func (v *Codegen) debugBasicType(name string, llvmType llvm.Type, encoding llvm.DwarfTypeEncoding) llvm.Value {
+	return v.debug.builder.CreateBasicType(llvm.DIBasicType{
+		Name:        name,
+		SizeInBits:  v.targetData.TypeSizeInBits(llvmType),
+		AlignInBits: uint64(v.targetData.ABITypeAlignment(llvmType)) * 8,
+		Encoding:    encoding,
+	})
+}
+
+func (v *Codegen) debugPointerType(addressee parser.Type) llvm.Value {
+	ptrType := llvm.PointerType(v.context.Int8Type(), 0)
+	return v.debug.builder.CreatePointerType(llvm.DIPointerType{
+		Pointee:     v.debugType(addressee),
+		SizeInBits:  v.targetData.TypeSizeInBits(ptrType),
+		AlignInBits: uint64(v.targetData.ABITypeAlignment(ptrType)) * 8,
+	})
+}
+
+func (v *Codegen) debugStructType(name string, llvmType llvm.Type, names []string, types []llvm.Value) llvm.Value {
+	fieldTypes := llvmType.StructElementTypes()
+
+	members := make([]llvm.Value, len(names))
+	for idx, fieldName := range names {
+		members[idx] = v.debug.builder.CreateMemberType(v.debug.compileUnit, llvm.DIMemberType{
+			Name:         fieldName,
+			File:         v.debug.file,
+			SizeInBits:   v.targetData.TypeSizeInBits(fieldTypes[idx]),
+			AlignInBits:  uint64(v.targetData.ABITypeAlignment(fieldTypes[idx])) * 8,
+			OffsetInBits: v.targetData.ElementOffset(llvmType, idx) * 8,
+			Type:         types[idx],
+		})
+	}
+
+	return v.debug.builder.CreateStructType(v.debug.compileUnit, llvm.DIStructType{
+		Name:        name,
+		File:        v.debug.file,
+		SizeInBits:  v.targetData.TypeSizeInBits(llvmType),
+		AlignInBits: uint64(v.targetData.ABITypeAlignment(llvmType)) * 8,
+		Elements:    members,
+	})
+}
+
+func primitiveTypeEncoding(typ parser.PrimitiveType) llvm.DwarfTypeEncoding {
+	switch {
+	case typ == parser.PRIMITIVE_bool:
+		return llvm.DW_ATE_boolean
+	case typ == parser.PRIMITIVE_rune:
+		return llvm.DW_ATE_unsigned_char
+	case typ.IsFloatingType():
+		return llvm.DW_ATE_float
+	case typ.IsSigned():
+		return llvm.DW_ATE_signed
+	default:
+		return llvm.DW_ATE_unsigned
+	}
+}
@@ -0,0 +1,299 @@
+package LLVMCodegen
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/ark-lang/ark/src/parser"
+)
+
+// The C header declares every public function with the [c] attribute, along
+// with the types it uses. Types are lowered the same way typeToLLVMType does,
+// so the declarations match the generated code: pointers and references become
+// C pointers, arrays become a struct of length and pointer, tuples become
+// structs, and simple enums become 32 bit integers.
+
+type headerWriter struct {
+	v *Codegen
+
+	forward bytes.Buffer // typedefs of the structs, so they can point to each other
+	types   bytes.Buffer // the type definitions, each after the types it contains
+	funcs   bytes.Buffer
+
+	names      map[string]string // C names of the mangled types already written
+	inProgress map[string]bool   // structs whose fields are being written
+
+	exporting string // name of the function whose types are written
+}
+
+func (v *Codegen) createHeader() {
+	w := &headerWriter{
+		v:          v,
+		names:      make(map[string]string),
+		inProgress: make(map[string]bool),
+	}
+
+	for _, mod := range v.input {
+		for _, submod := range sortedSubmodules(mod.Module) {
+			for _, node := range submod.Nodes {
+				if decl, ok := node.(*parser.FunctionDecl); ok && isExportedToC(decl) {
+					w.writeFunction(decl.Function)
+				}
+			}
+		}
+	}
+
+	guard := headerGuard(v.HeaderFile)
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "/* Generated by the Ark compiler, do not edit. */\n\n")
+	fmt.Fprintf(buf, "#ifndef %s\n#define %s\n\n", guard, guard)
+	fmt.Fprintf(buf, "#include <stdbool.h>\n#include <stddef.h>\n#include <stdint.h>\n\n")
+	fmt.Fprintf(buf, "#ifdef __cplusplus\nextern \"C\" {\n#endif\n\n")
+
+	if w.forward.Len() > 0 {
+		buf.Write(w.forward.Bytes())
+		buf.WriteString("\n")
+	}
+	buf.Write(w.types.Bytes())
+	if w.funcs.Len() > 0 {
+		buf.Write(w.funcs.Bytes())
+		buf.WriteString("\n")
+	}
+
+	fmt.Fprintf(buf, "#ifdef __cplusplus\n}\n#endif\n\n")
+	fmt.Fprintf(buf, "#endif /* %s */\n", guard)
+
+	if err := ioutil.WriteFile(v.HeaderFile, buf.Bytes(), 0666); err != nil {
+		v.err("Couldn't write header file %s: `%s`", v.HeaderFile, err.Error())
+	}
+}
+
+func isExportedToC(decl *parser.FunctionDecl) bool {
+	return decl.IsPublic() && !decl.Prototype && decl.Function.Type.Attrs().Contains("c")
+}
+
+func (w *headerWriter) writeFunction(fn *parser.Function) {
+	w.exporting = fn.Name
+
+	var params []string
+	if fn.Receiver != nil {
+		params = append(params, w.declaration(fn.Receiver.Variable.Type, fn.Receiver.Variable.Name))
+	}
+	for _, par := range fn.Parameters {
+		params = append(params, w.declaration(par.Variable.Type, par.Variable.Name))
+	}
+
+	if fn.Type.IsVariadic {
+		params = append(params, "...")
+	} else if len(params) == 0 {
+		params = append(params, "void")
+	}
+
+	ret := "void"
+	if fn.Type.Return != nil {
+		ret = w.typeName(fn.Type.Return)
+	}
+
+	fmt.Fprintf(&w.funcs, "%s %s(%s);\n", ret, fn.Name, strings.Join(params, ", "))
+}
+
+func (w *headerWriter) declaration(typ parser.Type, name string) string {
+	return w.typeName(typ) + " " + name
+}
+
+// Returns the name of the type in C, writing the definition of the type first
+// if needed
+func (w *headerWriter) typeName(typ parser.Type) string {
+	switch typ := typ.(type) {
+	case parser.PrimitiveType:
+		return w.primitiveTypeName(typ)
+
+	case parser.PointerType:
+		return w.typeName(typ.Addressee) + "*"
+
+	case parser.MutableReferenceType:
+		return w.typeName(typ.Referrer) + "*"
+
+	case parser.ConstantReferenceType:
+		return "const " + w.typeName(typ.Referrer) + "*"
+
+	case *parser.NamedType:
+		return w.namedTypeName(typ)
+
+	case parser.ArrayType, parser.TupleType:
+		mangled := parser.TypeMangledName(parser.MANGLE_ARK_UNSTABLE, typ)
+		if name, ok := w.names[mangled]; ok {
+			return name
+		}
+
+		var name string
+		if array, ok := typ.(parser.ArrayType); ok {
+			name = "ark_array_" + identifierPart(w.typeName(array.MemberType))
+		} else {
+			name = "ark_tuple" + mangled
+		}
+		w.names[mangled] = name
+
+		w.writeStruct(name, typ, false)
+		return name
+	}
+
+	w.v.err("Cannot export `%s` to C: type `%s` has no C equivalent", w.exporting, typ.TypeName())
+	return ""
+}
+
+func (w *headerWriter) primitiveTypeName(typ parser.PrimitiveType) string {
+	switch typ {
+	case parser.PRIMITIVE_int:
+		return "intptr_t"
+	case parser.PRIMITIVE_uint:
+		return "uintptr_t"
+
+	case parser.PRIMITIVE_s8, parser.PRIMITIVE_s16, parser.PRIMITIVE_s32, parser.PRIMITIVE_s64:
+		return "int" + typ.TypeName()[1:] + "_t"
+	case parser.PRIMITIVE_u8, parser.PRIMITIVE_u16, parser.PRIMITIVE_u32, parser.PRIMITIVE_u64:
+		return "uint" + typ.TypeName()[1:] + "_t"
+	case parser.PRIMITIVE_s128:
+		return "__int128"
+	case parser.PRIMITIVE_u128:
+		return "unsigned __int128"
+
+	case parser.PRIMITIVE_f32:
+		return "float"
+	case parser.PRIMITIVE_f64:
+		return "double"
+	case parser.PRIMITIVE_f128:
+		return "__float128"
+
+	case parser.PRIMITIVE_rune:
+		return "int32_t"
+	case parser.PRIMITIVE_bool:
+		return "bool"
+	case parser.PRIMITIVE_void:
+		return "void"
+	}
+
+	w.v.err("Cannot export `%s` to C: type `%s` has no C equivalent", w.exporting, typ.TypeName())
+	return ""
+}
+
+func (w *headerWriter) namedTypeName(typ *parser.NamedType) string {
+	mangled := namedTypeKey(typ)
+	if name, ok := w.names[mangled]; ok {
+		return name
+	}
+
+	name := typ.Name
+	if typ.ParentModule == nil {
+		// builtin types could clash with C names
+		name = "ark_" + name
+	}
+	if typ.GenericType != nil {
+		name = typ.GenericType.Name
+		for _, arg := range typ.TypeArguments {
+			name += "_" + identifierPart(w.typeName(arg))
+		}
+	}
+
+	switch actual := typ.Type.(type) {
+	case parser.StructType, parser.ArrayType, parser.TupleType:
+		// structs are declared before they are defined, so they can point to
+		// themselves
+		w.names[mangled] = name
+		w.writeStruct(name, actual, actual.Attrs().Contains("packed"))
+
+	case parser.EnumType:
+		if !actual.Simple {
+			w.v.err("Cannot export `%s` to C: enum `%s` has members with values", w.exporting, typ.TypeName())
+		}
+
+		w.names[mangled] = name
+		fmt.Fprintf(&w.types, "typedef int32_t %s;\n", name)
+		fmt.Fprintf(&w.types, "enum {\n")
+		for _, mem := range actual.Members {
+			fmt.Fprintf(&w.types, "\t%s_%s = %d,\n", name, mem.Name, mem.Tag)
+		}
+		fmt.Fprintf(&w.types, "};\n\n")
+
+	default:
+		underlying := w.typeName(actual)
+		w.names[mangled] = name
+		fmt.Fprintf(&w.types, "typedef %s %s;\n\n", underlying, name)
+	}
+
+	return name
+}
+
+// Writes the definition of a struct for a struct, array or tuple type
+func (w *headerWriter) writeStruct(name string, typ parser.Type, packed bool) {
+	fmt.Fprintf(&w.forward, "typedef struct %s %s;\n", name, name)
+	w.inProgress[name] = true
+
+	var fields []string
+	switch typ := typ.(type) {
+	case parser.StructType:
+		for _, decl := range typ.Variables {
+			fields = append(fields, w.fieldDeclaration(decl.Variable.Type, decl.Variable.Name))
+		}
+
+	case parser.ArrayType:
+		fields = append(fields, "size_t len", w.fieldDeclaration(parser.PointerTo(typ.MemberType), "ptr"))
+
+	case parser.TupleType:
+		for idx, mem := range typ.Members {
+			fields = append(fields, w.fieldDeclaration(mem, fmt.Sprintf("_%d", idx)))
+		}
+	}
+
+	delete(w.inProgress, name)
+
+	fmt.Fprintf(&w.types, "struct %s {\n", name)
+	for _, field := range fields {
+		fmt.Fprintf(&w.types, "\t%s;\n", field)
+	}
+	if packed {
+		fmt.Fprintf(&w.types, "} __attribute__((packed));\n\n")
+	} else {
+		fmt.Fprintf(&w.types, "};\n\n")
+	}
+}
+
+// A struct containing a struct that is still being written can only point to
+// it, as C doesn't allow recursive types either
+func (w *headerWriter) fieldDeclaration(typ parser.Type, name string) string {
+	if named, ok := typ.(*parser.NamedType); ok {
+		if cName, ok := w.names[namedTypeKey(named)]; ok && w.inProgress[cName] {
+			w.v.err("Cannot export `%s` to C: type `%s` contains itself", w.exporting, named.TypeName())
+		}
+	}
+	return w.declaration(typ, name)
+}
+
+// Builtin types like string have no module, so they have no mangled name
+func namedTypeKey(typ *parser.NamedType) string {
+	if typ.ParentModule == nil {
+		return "builtin " + typ.Name
+	}
+	return typ.MangledName(parser.MANGLE_ARK_UNSTABLE)
+}
+
+// Turns a C type name into something that can be part of an identifier
+func identifierPart(name string) string {
+	name = strings.Replace(name, "*", "_ptr", -1)
+	return strings.Replace(name, " ", "_", -1)
+}
+
+func headerGuard(filename string) string {
+	guard := []rune(strings.ToUpper(filepath.Base(filename)))
+	for idx, r := range guard {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			guard[idx] = '_'
+		}
+	}
+	return "ARK_" + string(guard)
+}
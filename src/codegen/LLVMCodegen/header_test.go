@@ -0,0 +1,63 @@
+package LLVMCodegen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ark-lang/ark/src/lexer"
+	"github.com/ark-lang/ark/src/parser"
+)
+
+// Runs the frontend on a single file, like building a .ark file does
+func parseTestModule(t *testing.T, filename string) *parser.Module {
+	modname := &parser.ModuleName{Parts: []string{"__main"}}
+	module := &parser.Module{Name: modname}
+	lookup := parser.NewModuleLookup("")
+	lookup.Create(modname).Module = module
+
+	sourcefile, err := lexer.NewSourcefile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sourcefile.Tokens = lexer.Lex(sourcefile)
+
+	tree, _ := parser.Parse(sourcefile)
+	module.Trees = append(module.Trees, tree)
+
+	parser.Construct(module, lookup)
+	parser.Resolve(module, lookup)
+	for _, submod := range module.Parts {
+		inf := &parser.TypeInferer{Submodule: submod}
+		inf.Infer()
+	}
+
+	return module
+}
+
+func TestCreateHeader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ark-header")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	v := &Codegen{HeaderFile: filepath.Join(dir, "header.h")}
+	v.input = []*WrappedModule{{Module: parseTestModule(t, "testdata/header.ark")}}
+	v.createHeader()
+
+	have, err := ioutil.ReadFile(v.HeaderFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := ioutil.ReadFile("testdata/header.h")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(have) != string(want) {
+		t.Errorf("header doesn't match testdata/header.h, have:\n%s", have)
+	}
+}
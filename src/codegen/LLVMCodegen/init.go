@@ -0,0 +1,230 @@
+package LLVMCodegen
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ark-lang/ark/src/parser"
+	"llvm.org/llvm/bindings/go/llvm"
+)
+
+// Global variables whose initial value can't be evaluated at compile time are
+// zeroed, and set by an initialization function of their module before main
+// runs. This function and the functions with the [init] attribute are
+// registered in llvm.global_ctors, the functions with the [fini] attribute in
+// llvm.global_dtors.
+//
+// The constructors of a module run after the ones of the modules it depends
+// on, and its destructors before theirs. Within a module, a global is
+// initialized after the globals its initializer uses, directly or through the
+// functions of the module it calls, and otherwise in the order the globals are
+// declared in, taking the files of the module in alphabetical order. Globals
+// that depend on themselves are an error. The globals are initialized before
+// the [init] functions run, which run in an unspecified order, as do the
+// [fini] functions.
+
+// The priority of the constructors of modules without dependencies. Lower
+// priorities are left to C code linked into the program.
+const firstStructorPriority = 1000
+
+// Registers the function as a constructor or destructor if it has the [init]
+// or [fini] attribute
+func (v *Codegen) addStructor(fn *parser.Function, llvmFn llvm.Value) {
+	attrs := fn.Type.Attrs()
+	if attrs.Contains("init") {
+		v.curFile.initFunctions = append(v.curFile.initFunctions, llvmFn)
+	}
+	if attrs.Contains("fini") {
+		v.curFile.finiFunctions = append(v.curFile.finiFunctions, llvmFn)
+	}
+}
+
+func (v *Codegen) genStructors(mod *WrappedModule) {
+	// constructors run from the lowest priority up, destructors from the
+	// highest down
+	priority := firstStructorPriority
+	if v.Dependencies != nil {
+		priority += 2 * v.Dependencies.Depth(mod.Name)
+	}
+
+	var ctors, dtors []llvm.Value
+	if initGlobals := v.genGlobalInitializer(mod); !initGlobals.IsNil() {
+		ctors = append(ctors, v.structorEntry(initGlobals, priority))
+	}
+	for _, fn := range mod.initFunctions {
+		ctors = append(ctors, v.structorEntry(fn, priority+1))
+	}
+	for _, fn := range mod.finiFunctions {
+		dtors = append(dtors, v.structorEntry(fn, priority+1))
+	}
+
+	v.addStructorArray(mod, "llvm.global_ctors", ctors)
+	v.addStructorArray(mod, "llvm.global_dtors", dtors)
+}
+
+// Generates the function initializing the globals of the module at runtime,
+// returns a nil value if all of them are initialized at compile time
+func (v *Codegen) genGlobalInitializer(mod *WrappedModule) llvm.Value {
+	if len(mod.initializedGlobals) == 0 {
+		return llvm.Value{}
+	}
+
+	// the name can't clash with the name of an Ark function
+	fn := &parser.Function{
+		Name:         ".init_globals",
+		Type:         parser.FunctionType{Return: parser.PRIMITIVE_void},
+		ParentModule: mod.Module,
+	}
+
+	fnType := llvm.FunctionType(v.context.VoidType(), nil, false)
+	llvmFn := llvm.AddFunction(mod.LlvmModule, fn.MangledName(parser.MANGLE_ARK_UNSTABLE), fnType)
+	llvmFn.SetLinkage(nonPublicLinkage)
+
+	v.pushFunction(fn)
+	v.builders[fn] = v.context.NewBuilder()
+	v.builder().SetInsertPointAtEnd(v.context.AddBasicBlock(llvmFn, "entry"))
+
+	sort.SliceStable(mod.initializedGlobals, func(i, j int) bool {
+		return mod.globalOrder[mod.initializedGlobals[i].Variable] < mod.globalOrder[mod.initializedGlobals[j].Variable]
+	})
+	for _, decl := range mod.initializedGlobals {
+		v.builder().CreateStore(v.genExpr(decl.Assignment), v.getVariable(decl.Variable))
+	}
+	v.builder().CreateRetVoid()
+
+	v.builder().Dispose()
+	delete(v.builders, fn)
+	v.popFunction()
+
+	return llvmFn
+}
+
+// Numbers the globals of the module in the order they are initialized in, so
+// the initializer of a global runs after the ones of the globals it uses.
+// Called before the modules are generated, as it visits the functions of the
+// module, which must not happen while they are generated concurrently.
+func (v *Codegen) orderGlobals(mod *WrappedModule) {
+	var decls []*parser.VariableDecl
+	declOf := make(map[*parser.Variable]*parser.VariableDecl)
+	for _, submod := range sortedSubmodules(mod.Module) {
+		for _, node := range submod.Nodes {
+			if decl, ok := node.(*parser.VariableDecl); ok && decl.Assignment != nil && !decl.Variable.IsConstant {
+				decls = append(decls, decl)
+				declOf[decl.Variable] = decl
+			}
+		}
+	}
+
+	mod.globalOrder = make(map[*parser.Variable]int)
+	visiting := make(map[*parser.Variable]bool)
+	var path []string
+
+	var visit func(decl *parser.VariableDecl)
+	visit = func(decl *parser.VariableDecl) {
+		if _, ok := mod.globalOrder[decl.Variable]; ok {
+			return
+		}
+
+		path = append(path, decl.Variable.Name)
+		if visiting[decl.Variable] {
+			start := 0
+			for path[start] != decl.Variable.Name {
+				start++
+			}
+			v.errPos(decl, "Initialization cycle: `%s`", strings.Join(path[start:], "` uses `"))
+		}
+		visiting[decl.Variable] = true
+
+		for _, used := range globalsUsedBy(mod.Module, decl.Assignment) {
+			if dep, ok := declOf[used]; ok {
+				visit(dep)
+			}
+		}
+
+		path = path[:len(path)-1]
+		mod.globalOrder[decl.Variable] = len(mod.globalOrder)
+	}
+
+	for _, decl := range decls {
+		visit(decl)
+	}
+}
+
+// Collects the variables an expression uses, directly or through the
+// functions of the module it refers to
+type usedGlobals struct {
+	module    *parser.Module
+	variables []*parser.Variable
+	seen      map[*parser.Variable]bool
+	functions map[*parser.Function]bool
+	pending   []*parser.Function
+}
+
+func globalsUsedBy(mod *parser.Module, expr parser.Expr) []*parser.Variable {
+	uses := &usedGlobals{
+		module:    mod,
+		seen:      make(map[*parser.Variable]bool),
+		functions: make(map[*parser.Function]bool),
+	}
+
+	visitor := parser.NewASTVisitor(uses)
+	visitor.VisitExpr(expr)
+	for len(uses.pending) > 0 {
+		fn := uses.pending[0]
+		uses.pending = uses.pending[1:]
+		visitor.VisitBlock(fn.Body)
+	}
+
+	return uses.variables
+}
+
+func (v *usedGlobals) Visit(n *parser.Node) bool {
+	switch n := (*n).(type) {
+	case *parser.VariableAccessExpr:
+		if !v.seen[n.Variable] {
+			v.seen[n.Variable] = true
+			v.variables = append(v.variables, n.Variable)
+		}
+
+	case *parser.FunctionAccessExpr:
+		fn := n.Function
+		if fn.ParentModule == v.module && fn.Body != nil && !v.functions[fn] {
+			v.functions[fn] = true
+			v.pending = append(v.pending, fn)
+		}
+	}
+	return true
+}
+
+func (v *usedGlobals) PostVisit(n *parser.Node) {}
+func (v *usedGlobals) EnterScope()              {}
+func (v *usedGlobals) ExitScope()               {}
+
+func (v *Codegen) structorEntryType() llvm.Type {
+	fnType := llvm.FunctionType(v.context.VoidType(), nil, false)
+	return v.context.StructType([]llvm.Type{
+		v.context.Int32Type(),
+		llvm.PointerType(fnType, 0),
+		llvm.PointerType(v.context.Int8Type(), 0),
+	}, false)
+}
+
+func (v *Codegen) structorEntry(fn llvm.Value, priority int) llvm.Value {
+	fnType := llvm.FunctionType(v.context.VoidType(), nil, false)
+	return v.context.ConstStruct([]llvm.Value{
+		llvm.ConstInt(v.context.Int32Type(), uint64(priority), false),
+		llvm.ConstBitCast(fn, llvm.PointerType(fnType, 0)),
+		llvm.ConstPointerNull(llvm.PointerType(v.context.Int8Type(), 0)),
+	}, false)
+}
+
+func (v *Codegen) addStructorArray(mod *WrappedModule, name string, entries []llvm.Value) {
+	if len(entries) == 0 {
+		return
+	}
+
+	array := llvm.ConstArray(v.structorEntryType(), entries)
+	global := llvm.AddGlobal(mod.LlvmModule, array.Type(), name)
+	global.SetLinkage(llvm.AppendingLinkage)
+	global.SetInitializer(array)
+}
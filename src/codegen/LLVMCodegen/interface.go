@@ -0,0 +1,148 @@
+package LLVMCodegen
+
+import (
+	"github.com/ark-lang/ark/src/parser"
+	"llvm.org/llvm/bindings/go/llvm"
+)
+
+// Interface values are fat pointers: a pointer to the data of the value, and
+// a pointer to the vtable of its type for the interface. A vtable holds a
+// function pointer for each function of the interface, which takes the data
+// pointer in place of the receiver. Both are stored as ^u8, so interface
+// functions can take and return the interface itself.
+
+func (v *Codegen) interfaceTypeToLLVMType(typ parser.InterfaceType) llvm.Type {
+	bytePtrType := llvm.PointerType(v.context.IntType(8), 0)
+	return v.context.StructType([]llvm.Type{bytePtrType, bytePtrType}, false)
+}
+
+func (v *Codegen) vtableType(typ parser.InterfaceType) llvm.Type {
+	fields := make([]llvm.Type, len(typ.Functions))
+	for idx, fn := range typ.Functions {
+		fields[idx] = v.vtableFunctionType(fn.Type, true)
+	}
+	return v.context.StructType(fields, false)
+}
+
+// The type of a function in a vtable, which is the interface function with the
+// data pointer as receiver
+func (v *Codegen) vtableFunctionType(typ parser.FunctionType, ptr bool) llvm.Type {
+	typ.Receiver = parser.PointerTo(parser.PRIMITIVE_u8)
+	return v.functionTypeToLLVMType(typ, ptr)
+}
+
+// Converts a value of a type implementing the interface typ to an interface
+// value. A value that isn't a pointer or reference is copied to memory
+// allocated with malloc, so the interface value can outlive the function that
+// created it. Like closure environments, the copy is never freed.
+func (v *Codegen) genInterfaceConversion(expr parser.Expr, typ parser.Type) llvm.Value {
+	if !v.inFunction() {
+		v.err("Cannot convert to interface `%s` outside of a function", typ.TypeName())
+	}
+
+	bytePtrType := llvm.PointerType(v.context.IntType(8), 0)
+	named, _ := parser.InterfaceImplementor(expr.GetType())
+	value := v.genExpr(expr)
+
+	var data llvm.Value
+	switch expr.GetType().(type) {
+	case parser.PointerType, parser.MutableReferenceType, parser.ConstantReferenceType:
+		data = value
+
+	default:
+		size := llvm.ConstInt(v.intPtrType(), v.targetData.TypeAllocSize(value.Type()), false)
+		mem := v.builder().CreateCall(v.getMalloc(), []llvm.Value{size}, "iface_data")
+		data = v.builder().CreateBitCast(mem, llvm.PointerType(value.Type(), 0), "")
+		v.builder().CreateStore(value, data)
+	}
+
+	res := llvm.Undef(v.typeToLLVMType(typ))
+	res = v.builder().CreateInsertValue(res, v.builder().CreateBitCast(data, bytePtrType, ""), 0, "")
+	res = v.builder().CreateInsertValue(res, llvm.ConstBitCast(v.getVtable(named, typ), bytePtrType), 1, "")
+	return res
+}
+
+// Calls a function of an interface through the vtable of the receiver
+func (v *Codegen) genInterfaceCall(n *parser.CallExpr) llvm.Value {
+	fn := n.Function.(*parser.FunctionAccessExpr).Function
+	iface := n.ReceiverAccess.GetType().ActualType().(parser.InterfaceType)
+	_, idx := iface.GetFunction(fn.Name)
+
+	receiver := v.genExpr(n.ReceiverAccess)
+	data := v.builder().CreateExtractValue(receiver, 0, "iface_data")
+	vtable := v.builder().CreateBitCast(v.builder().CreateExtractValue(receiver, 1, ""),
+		llvm.PointerType(v.vtableType(iface), 0), "vtable")
+
+	target := v.builder().CreateLoad(v.builder().CreateStructGEP(vtable, idx, ""), "")
+
+	args := []llvm.Value{data}
+	for _, arg := range n.Arguments {
+		args = append(args, v.genExpr(arg))
+	}
+
+	return v.builder().CreateCall(target, args, "")
+}
+
+// Gets the vtable of the named type for the interface, generating it if needed
+func (v *Codegen) getVtable(named *parser.NamedType, ifaceType parser.Type) llvm.Value {
+	name := "_VT" + parser.TypeMangledName(parser.MANGLE_ARK_UNSTABLE, named) +
+		parser.TypeMangledName(parser.MANGLE_ARK_UNSTABLE, ifaceType)
+
+	vtable := v.curFile.LlvmModule.NamedGlobal(name)
+	if !vtable.IsNil() {
+		return vtable
+	}
+
+	iface := ifaceType.ActualType().(parser.InterfaceType)
+	fns := make([]llvm.Value, len(iface.Functions))
+	for idx, fn := range iface.Functions {
+		fns[idx] = v.genVtableThunk(named.ImplementingMethod(fn), fn.Type, name+"_"+fn.Name)
+	}
+
+	init := v.context.ConstStruct(fns, false)
+	vtable = llvm.AddGlobal(v.curFile.LlvmModule, init.Type(), name)
+	vtable.SetInitializer(init)
+	vtable.SetGlobalConstant(true)
+	vtable.SetLinkage(nonPublicLinkage)
+
+	return vtable
+}
+
+// Generates the function in a vtable that calls the method with the receiver
+// behind the data pointer
+func (v *Codegen) genVtableThunk(method *parser.Function, typ parser.FunctionType, name string) llvm.Value {
+	thunk := llvm.AddFunction(v.curFile.LlvmModule, name, v.vtableFunctionType(typ, false))
+	thunk.SetLinkage(nonPublicLinkage)
+
+	builder := v.context.NewBuilder()
+	defer builder.Dispose()
+	builder.SetInsertPointAtEnd(v.context.AddBasicBlock(thunk, "entry"))
+
+	params := thunk.Params()
+	recType := method.Type.Receiver
+
+	var receiver llvm.Value
+	switch recType.(type) {
+	case parser.PointerType, parser.MutableReferenceType, parser.ConstantReferenceType:
+		receiver = builder.CreateBitCast(params[0], v.typeToLLVMType(recType), "")
+
+	default:
+		ptr := builder.CreateBitCast(params[0], llvm.PointerType(v.typeToLLVMType(recType), 0), "")
+		receiver = builder.CreateLoad(ptr, "")
+	}
+
+	args := append([]llvm.Value{receiver}, params[1:]...)
+	call := builder.CreateCall(v.genAccessExpr(&parser.FunctionAccessExpr{Function: method}), args, "")
+
+	if attr, ok := method.Type.Attrs()["call_conv"]; ok {
+		call.SetInstructionCallConv(callConvTypes[attr.Value])
+	}
+
+	if typ.Return.IsVoidType() {
+		builder.CreateRetVoid()
+	} else {
+		builder.CreateRet(call)
+	}
+
+	return thunk
+}
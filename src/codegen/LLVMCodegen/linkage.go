@@ -0,0 +1,71 @@
+package LLVMCodegen
+
+import (
+	"github.com/ark-lang/ark/src/parser"
+	"llvm.org/llvm/bindings/go/llvm"
+)
+
+var visibilityTypes = map[string]llvm.Visibility{
+	"default": llvm.DefaultVisibility,
+	"hidden":  llvm.HiddenVisibility,
+}
+
+// Applies the [section], [weak], [visibility] and [thread_local] attributes
+// of a global variable or function defined or declared in the current module.
+// The linkage of the value has to be set already.
+func (v *Codegen) applyLinkageAttrs(value llvm.Value, attrs parser.AttrGroup, declaration bool) {
+	if attr := attrs.Get("section"); attr != nil {
+		value.SetSection(attr.Value)
+
+		// values in custom sections are usually collected by the linker
+		// without the program referring to them, so they are kept
+		if !declaration {
+			v.curFile.usedValues = append(v.curFile.usedValues, value)
+		}
+	}
+
+	if attrs.Contains("weak") {
+		if declaration {
+			value.SetLinkage(llvm.ExternalWeakLinkage)
+		} else {
+			value.SetLinkage(llvm.WeakAnyLinkage)
+		}
+
+		// a weak definition can be replaced by one outside the program
+		v.exportSymbol(value.Name())
+	}
+
+	// symbols local to the module are never visible outside of it
+	if attr := attrs.Get("visibility"); attr != nil && value.Linkage() != nonPublicLinkage {
+		value.SetVisibility(visibilityTypes[attr.Value])
+
+		// the symbol is explicitly made visible outside of the program
+		if attr.Value == "default" {
+			v.exportSymbol(value.Name())
+		}
+	}
+
+	if attrs.Contains("thread_local") {
+		value.SetThreadLocal(true)
+	}
+}
+
+// Adds the values to llvm.used, so the optimizer and linker don't remove them
+func (v *Codegen) genUsedValues(mod *WrappedModule) {
+	if len(mod.usedValues) == 0 {
+		return
+	}
+
+	bytePtrType := llvm.PointerType(v.context.Int8Type(), 0)
+
+	values := make([]llvm.Value, len(mod.usedValues))
+	for idx, value := range mod.usedValues {
+		values[idx] = llvm.ConstBitCast(value, bytePtrType)
+	}
+
+	array := llvm.ConstArray(bytePtrType, values)
+	global := llvm.AddGlobal(mod.LlvmModule, array.Type(), "llvm.used")
+	global.SetLinkage(llvm.AppendingLinkage)
+	global.SetSection("llvm.metadata")
+	global.SetInitializer(array)
+}
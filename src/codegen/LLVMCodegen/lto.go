@@ -0,0 +1,66 @@
+package LLVMCodegen
+
+import (
+	"strings"
+
+	"llvm.org/llvm/bindings/go/llvm"
+)
+
+// With link-time optimisation, the modules are linked into a single LLVM
+// module before the optimisation passes run, so calls across Ark modules can
+// be inlined. Every symbol that isn't needed outside the program is made
+// internal, which lets the optimiser drop or specialize it.
+
+// Marks a symbol as needed outside the module, so it isn't internalized
+func (v *Codegen) exportSymbol(name string) {
+	v.curFile.exportedSymbols[name] = true
+}
+
+// Whether the modules are linked into one LLVM module before the output is
+// created. This needs all modules to be generated in the same context.
+func (v *Codegen) linksModules() bool {
+	return v.LTO || v.OutputType == OUTPUT_LLVM_IR || v.OutputType == OUTPUT_LLVM_BITCODE
+}
+
+// Links the LLVM modules of all Ark modules into the first one, which is then
+// the only module left in the input
+func (v *Codegen) linkModules() {
+	dest := v.input[0]
+	for _, mod := range v.input[1:] {
+		for name := range mod.exportedSymbols {
+			dest.exportedSymbols[name] = true
+		}
+
+		if err := llvm.LinkModules(dest.LlvmModule, mod.LlvmModule, llvm.LinkerDestroySource); err != nil {
+			v.err("Couldn't link module `%s`: %s", mod.Name.String(), err.Error())
+		}
+	}
+	v.input = v.input[:1]
+
+	if err := llvm.VerifyModule(dest.LlvmModule, llvm.ReturnStatusAction); err != nil {
+		dest.LlvmModule.Dump()
+		v.err("%s", err.Error())
+	}
+}
+
+func (v *Codegen) internalize(mod *WrappedModule) {
+	for fn := mod.LlvmModule.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		internalizeSymbol(fn, mod.exportedSymbols)
+	}
+
+	for global := mod.LlvmModule.FirstGlobal(); !global.IsNil(); global = llvm.NextGlobal(global) {
+		internalizeSymbol(global, mod.exportedSymbols)
+	}
+}
+
+func internalizeSymbol(value llvm.Value, exported map[string]bool) {
+	// declarations are defined outside the program, and the llvm. globals
+	// have special meaning to LLVM
+	if value.IsDeclaration() || strings.HasPrefix(value.Name(), "llvm.") {
+		return
+	}
+
+	if !exported[value.Name()] {
+		value.SetLinkage(llvm.InternalLinkage)
+	}
+}
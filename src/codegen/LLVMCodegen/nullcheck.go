@@ -0,0 +1,80 @@
+package LLVMCodegen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ark-lang/ark/src/parser"
+	"llvm.org/llvm/bindings/go/llvm"
+)
+
+// Whether dereferences of values of this type get null checks. References
+// always point to a value, so only pointers are checked.
+func (v *Codegen) nullChecked(typ parser.Type) bool {
+	if !v.NullChecks || !v.inFunction() {
+		return false
+	}
+
+	_, ok := typ.ActualType().(parser.PointerType)
+	return ok
+}
+
+// Panics if the pointer dereferenced by the expression is null
+func (v *Codegen) genNullCheck(n *parser.DerefAccessExpr, ptr llvm.Value) {
+	// dereferences inserted by the type inference, like the one of a struct
+	// access through a pointer, have no position of their own
+	pos := n.Pos()
+	if pos.Line == 0 {
+		pos = n.Expr.Pos()
+	}
+
+	message := "null pointer dereference"
+	if name := accessString(n.Expr); name != "" {
+		message += fmt.Sprintf(" of `%s`", strings.Replace(name, "%", "%%", -1))
+	}
+
+	isNull := v.builder().CreateIsNull(ptr, "")
+	v.genRuntimeCheck(isNull, "nullcheck", pos, message)
+}
+
+// Returns the expression as it is written in the source, or an empty string
+// if it isn't an access to a variable, a member or an element
+func accessString(n parser.Expr) string {
+	switch n := n.(type) {
+	case *parser.VariableAccessExpr:
+		return n.Variable.Name
+
+	case *parser.StructAccessExpr:
+		// a struct accessed through a pointer is dereferenced implicitly
+		var inner parser.Expr = n.Struct
+		if deref, ok := inner.(*parser.DerefAccessExpr); ok && deref.Pos().Line == 0 {
+			inner = deref.Expr
+		}
+		if str := accessString(inner); str != "" {
+			return str + "." + n.Member
+		}
+
+	case *parser.TupleAccessExpr:
+		if str := accessString(n.Tuple); str != "" {
+			return fmt.Sprintf("%s.%d", str, n.Index)
+		}
+
+	case *parser.ArrayAccessExpr:
+		array, subscript := accessString(n.Array), accessString(n.Subscript)
+		if lit, ok := n.Subscript.(*parser.NumericLiteral); ok && !lit.IsFloat {
+			subscript = lit.IntValue.String()
+		}
+		if array != "" && subscript != "" {
+			return array + "[" + subscript + "]"
+		} else if array != "" {
+			return array + "[...]"
+		}
+
+	case *parser.DerefAccessExpr:
+		if str := accessString(n.Expr); str != "" {
+			return "^" + str
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,86 @@
+package LLVMCodegen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ark-lang/ark/src/lexer"
+	"github.com/ark-lang/ark/src/parser"
+	"llvm.org/llvm/bindings/go/llvm"
+)
+
+// Whether arithmetic of this type gets overflow checks
+func (v *Codegen) overflowChecked(typ parser.Type) bool {
+	if !v.OverflowChecks || !v.inFunction() {
+		return false
+	}
+
+	_, ok := typ.ActualType().(parser.PrimitiveType)
+	return ok && typ.IsIntegerType()
+}
+
+// Generates + - or * of two integers, panicking if the result overflows
+func (v *Codegen) genCheckedArith(operator parser.BinOpType, resType, lhandType, rhandType parser.Type, lhand, rhand llvm.Value, pos lexer.Position) llvm.Value {
+	var name string
+	switch operator {
+	case parser.BINOP_ADD:
+		name = "add"
+	case parser.BINOP_SUB:
+		name = "sub"
+	case parser.BINOP_MUL:
+		name = "mul"
+	default:
+		panic("INTERNAL ERROR: No overflow intrinsic for " + operator.OpString())
+	}
+
+	if resType.IsSigned() {
+		name = "s" + name
+	} else {
+		name = "u" + name
+	}
+
+	res := v.builder().CreateCall(v.getOverflowIntrinsic(name, lhand.Type()), []llvm.Value{lhand, rhand}, "")
+	overflow := v.builder().CreateExtractValue(res, 1, "overflow")
+
+	v.genRuntimeCheck(overflow, "overflowcheck", pos, overflowMessage("integer overflow", operator, lhandType, rhandType))
+
+	return v.builder().CreateExtractValue(res, 0, "")
+}
+
+// Panics if the divisor of / or % is zero, or if a signed division overflows,
+// which is the case for MIN / -1
+func (v *Codegen) genDivisionCheck(operator parser.BinOpType, resType, lhandType, rhandType parser.Type, lhand, rhand llvm.Value, pos lexer.Position) {
+	typ := rhand.Type()
+
+	isZero := v.builder().CreateICmp(llvm.IntEQ, rhand, llvm.ConstInt(typ, 0, false), "")
+	v.genRuntimeCheck(isZero, "divcheck", pos, overflowMessage("division by zero", operator, lhandType, rhandType))
+
+	if resType.IsSigned() {
+		min := llvm.ConstShl(llvm.ConstInt(typ, 1, false), llvm.ConstInt(typ, uint64(typ.IntTypeWidth()-1), false))
+		isMin := v.builder().CreateICmp(llvm.IntEQ, lhand, min, "")
+		isMinusOne := v.builder().CreateICmp(llvm.IntEQ, rhand, llvm.ConstAllOnes(typ), "")
+
+		overflow := v.builder().CreateAnd(isMin, isMinusOne, "")
+		v.genRuntimeCheck(overflow, "overflowcheck", pos, overflowMessage("integer overflow", operator, lhandType, rhandType))
+	}
+}
+
+// The message is used as a format string, so the % of the modulo operator is escaped
+func overflowMessage(problem string, operator parser.BinOpType, lhandType, rhandType parser.Type) string {
+	msg := fmt.Sprintf("%s in `%s %s %s`", problem, lhandType.TypeName(), operator.OpString(), rhandType.TypeName())
+	return strings.Replace(msg, "%", "%%", -1)
+}
+
+// Gets the llvm.<name>.with.overflow intrinsic for the integer type
+func (v *Codegen) getOverflowIntrinsic(name string, typ llvm.Type) llvm.Value {
+	fnName := fmt.Sprintf("llvm.%s.with.overflow.i%d", name, typ.IntTypeWidth())
+	fn := v.curFile.LlvmModule.NamedFunction(fnName)
+
+	if fn.IsNil() {
+		resType := v.context.StructType([]llvm.Type{typ, v.context.IntType(1)}, false)
+		fnType := llvm.FunctionType(resType, []llvm.Type{typ, typ}, false)
+		fn = llvm.AddFunction(v.curFile.LlvmModule, fnName, fnType)
+	}
+
+	return fn
+}
@@ -0,0 +1,96 @@
+package LLVMCodegen
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ark-lang/ark/src/lexer"
+	"github.com/ark-lang/ark/src/parser"
+	"llvm.org/llvm/bindings/go/llvm"
+)
+
+// the exit code of a program that failed a runtime check
+const panicExitCode = 101
+
+const stderrFileno = 2
+
+// Prints `panic: <message> at <file>:<line>:<char>` to stderr and exits the
+// program with panicExitCode. The message is a printf format string, with args
+// as the values to format. The current block is terminated.
+func (v *Codegen) genPanic(pos lexer.Position, message string, args ...llvm.Value) {
+	file := strings.Replace(filepath.Base(v.curSubmodule.File.Path), "%", "%%", -1)
+	format := fmt.Sprintf("panic: %s at %s:%d:%d\n", message, file, pos.Line, pos.Char)
+
+	intType := v.context.IntType(32)
+	dprintfArgs := []llvm.Value{
+		llvm.ConstInt(intType, stderrFileno, false),
+		v.builder().CreateGlobalStringPtr(format, "panic_msg"),
+	}
+	dprintfArgs = append(dprintfArgs, args...)
+
+	v.builder().CreateCall(v.getDprintf(), dprintfArgs, "")
+	v.builder().CreateCall(v.getExit(), []llvm.Value{llvm.ConstInt(intType, panicExitCode, false)}, "")
+	v.builder().CreateUnreachable()
+}
+
+// Panics with the message if failed is true, and continues in a new block
+// otherwise. name is used for the names of the blocks.
+func (v *Codegen) genRuntimeCheck(failed llvm.Value, name string, pos lexer.Position, message string, args ...llvm.Value) {
+	failBlock := v.context.AddBasicBlock(v.currentLLVMFunction(), name+"_fail")
+	endBlock := v.context.AddBasicBlock(v.currentLLVMFunction(), name+"_end")
+
+	v.builder().CreateCondBr(failed, failBlock, endBlock)
+
+	v.builder().SetInsertPointAtEnd(failBlock)
+	v.genPanic(pos, message, args...)
+
+	v.builder().SetInsertPointAtEnd(endBlock)
+}
+
+// Extends an integer to 64 bits so it can be formatted with %lld or %llu
+func (v *Codegen) genPanicInt(val llvm.Value, typ parser.Type) llvm.Value {
+	i64 := v.context.IntType(64)
+	if val.Type().IntTypeWidth() >= 64 {
+		return val
+	} else if typ.IsSigned() {
+		return v.builder().CreateSExt(val, i64, "")
+	}
+	return v.builder().CreateZExt(val, i64, "")
+}
+
+// The printf format for an integer extended by genPanicInt
+func panicIntFormat(typ parser.Type) string {
+	if typ.IsSigned() {
+		return "%lld"
+	}
+	return "%llu"
+}
+
+func (v *Codegen) getDprintf() llvm.Value {
+	bytePtrType := llvm.PointerType(v.typeToLLVMType(parser.PRIMITIVE_u8), 0)
+	fnType := llvm.FunctionType(v.context.IntType(32), []llvm.Type{v.context.IntType(32), bytePtrType}, true)
+	return v.getCFunction("dprintf", fnType)
+}
+
+func (v *Codegen) getExit() llvm.Value {
+	fn := v.getCFunction("exit", llvm.FunctionType(v.context.VoidType(), []llvm.Type{v.context.IntType(32)}, false))
+	if !fn.IsAFunction().IsNil() {
+		fn.AddFunctionAttr(llvm.NoReturnAttribute)
+	}
+	return fn
+}
+
+// Gets a libc function, declaring it if needed. If the program already
+// declared it with another type, the existing function is cast to fnType.
+func (v *Codegen) getCFunction(name string, fnType llvm.Type) llvm.Value {
+	fn := v.curFile.LlvmModule.NamedFunction(name)
+
+	if fn.IsNil() {
+		return llvm.AddFunction(v.curFile.LlvmModule, name, fnType)
+	} else if fn.Type().ElementType() != fnType {
+		return llvm.ConstBitCast(fn, llvm.PointerType(fnType, 0))
+	}
+
+	return fn
+}
@@ -0,0 +1,78 @@
+package LLVMCodegen
+
+import (
+	"sync"
+
+	"github.com/ark-lang/ark/src/parser"
+	"llvm.org/llvm/bindings/go/llvm"
+)
+
+// Modules are generated by Jobs workers running concurrently. Every worker is
+// a copy of the codegen with its own LLVM context, builders and lookups, as
+// LLVM contexts can't be shared between threads. Modules only refer to the
+// functions and variables of other modules through declarations, so they can
+// be generated in any order.
+
+func (v *Codegen) generateModules(modules []*WrappedModule) {
+	jobs := v.Jobs
+	if jobs < 1 || v.linksModules() {
+		// modules can only be linked if they share a context
+		jobs = 1
+	}
+	if jobs > len(modules) {
+		jobs = len(modules)
+	}
+
+	queue := make(chan *WrappedModule, len(modules))
+	for _, mod := range modules {
+		queue <- mod
+	}
+	close(queue)
+
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		worker := v.newWorker()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer worker.dispose()
+
+			for mod := range queue {
+				worker.generateModule(mod)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (v *Codegen) newWorker() *Codegen {
+	worker := &Codegen{}
+	*worker = *v
+
+	worker.context = llvm.NewContext()
+	worker.globalBuilder = worker.context.NewBuilder()
+	worker.passManager = v.createPassManager()
+	worker.targetMachine = v.createTargetMachine()
+	worker.targetData = worker.targetMachine.TargetData()
+
+	worker.builders = make(map[*parser.Function]llvm.Builder)
+	worker.inBlocks = make(map[*parser.Function][]*parser.Block)
+	worker.blockDeferData = make(map[*parser.Block][]*deferData)
+	worker.curLoopExits = make(map[*parser.Function][]llvm.BasicBlock)
+	worker.curLoopNexts = make(map[*parser.Function][]llvm.BasicBlock)
+	worker.curLoopDepth = make(map[*parser.Function][]int)
+	worker.namedTypeLookup = make(map[string]llvm.Type)
+
+	// the sizes of types depend on the target data of the worker
+	worker.constants = nil
+
+	return worker
+}
+
+// The context is kept alive, as the modules generated in it are used to create
+// the binary
+func (v *Codegen) dispose() {
+	v.globalBuilder.Dispose()
+	v.passManager.Dispose()
+}
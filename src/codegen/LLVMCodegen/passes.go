@@ -0,0 +1,91 @@
+package LLVMCodegen
+
+import (
+	"sort"
+	"strings"
+
+	"llvm.org/llvm/bindings/go/llvm"
+)
+
+// The LLVM passes which can be run with --passes, by the names opt uses for
+// them. Only the passes the C API can create are available.
+var namedPasses = map[string]func(llvm.PassManager){
+	// scalar
+	"adce":              llvm.PassManager.AddAggressiveDCEPass,
+	"simplifycfg":       llvm.PassManager.AddCFGSimplificationPass,
+	"dse":               llvm.PassManager.AddDeadStoreEliminationPass,
+	"gvn":               llvm.PassManager.AddGVNPass,
+	"indvars":           llvm.PassManager.AddIndVarSimplifyPass,
+	"instcombine":       llvm.PassManager.AddInstructionCombiningPass,
+	"jump-threading":    llvm.PassManager.AddJumpThreadingPass,
+	"licm":              llvm.PassManager.AddLICMPass,
+	"loop-deletion":     llvm.PassManager.AddLoopDeletionPass,
+	"loop-rotate":       llvm.PassManager.AddLoopRotatePass,
+	"loop-unroll":       llvm.PassManager.AddLoopUnrollPass,
+	"loop-unswitch":     llvm.PassManager.AddLoopUnswitchPass,
+	"memcpyopt":         llvm.PassManager.AddMemCpyOptPass,
+	"mem2reg":           llvm.PassManager.AddPromoteMemoryToRegisterPass,
+	"reg2mem":           llvm.PassManager.AddDemoteMemoryToRegisterPass,
+	"reassociate":       llvm.PassManager.AddReassociatePass,
+	"sccp":              llvm.PassManager.AddSCCPPass,
+	"scalarrepl":        llvm.PassManager.AddScalarReplAggregatesPass,
+	"simplify-libcalls": llvm.PassManager.AddSimplifyLibCallsPass,
+	"tailcallelim":      llvm.PassManager.AddTailCallEliminationPass,
+	"constprop":         llvm.PassManager.AddConstantPropagationPass,
+	"verify":            llvm.PassManager.AddVerifierPass,
+
+	// interprocedural
+	"argpromotion":          llvm.PassManager.AddArgumentPromotionPass,
+	"constmerge":            llvm.PassManager.AddConstantMergePass,
+	"deadargelim":           llvm.PassManager.AddDeadArgEliminationPass,
+	"functionattrs":         llvm.PassManager.AddFunctionAttrsPass,
+	"inline":                llvm.PassManager.AddFunctionInliningPass,
+	"globaldce":             llvm.PassManager.AddGlobalDCEPass,
+	"globalopt":             llvm.PassManager.AddGlobalOptimizerPass,
+	"ipconstprop":           llvm.PassManager.AddIPConstantPropagationPass,
+	"prune-eh":              llvm.PassManager.AddPruneEHPass,
+	"ipsccp":                llvm.PassManager.AddIPSCCPPass,
+	"strip-dead-prototypes": llvm.PassManager.AddStripDeadPrototypesPass,
+
+	// vectorization
+	"loop-vectorize": llvm.PassManager.AddLoopVectorizePass,
+	"slp-vectorizer": llvm.PassManager.AddSLPVectorizePass,
+}
+
+func passNames() []string {
+	var names []string
+	for name := range namedPasses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Checks that the passes exist before any module is generated
+func (v *Codegen) checkPasses() {
+	for _, name := range v.Passes {
+		if _, ok := namedPasses[name]; !ok {
+			v.err("Unknown LLVM pass `%s`, the available passes are: %s", name, strings.Join(passNames(), ", "))
+		}
+	}
+}
+
+func (v *Codegen) addPasses(passManager llvm.PassManager) {
+	for _, name := range v.Passes {
+		namedPasses[name](passManager)
+	}
+}
+
+// The code generation level of the target machine for the optimization level
+func (v *Codegen) codeGenLevel() llvm.CodeGenOptLevel {
+	switch {
+	case v.OptLevel == 0:
+		return llvm.CodeGenLevelNone
+	case v.OptLevel == 1:
+		return llvm.CodeGenLevelLess
+	case v.OptLevel == 2 || v.SizeLevel > 0:
+		return llvm.CodeGenLevelDefault
+	default:
+		return llvm.CodeGenLevelAggressive
+	}
+}
@@ -0,0 +1,53 @@
+/* Generated by the Ark compiler, do not edit. */
+
+#ifndef ARK_HEADER_H
+#define ARK_HEADER_H
+
+#include <stdbool.h>
+#include <stddef.h>
+#include <stdint.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+typedef struct Header Header;
+typedef struct ark_array_intptr_t ark_array_intptr_t;
+typedef struct ark_tuple_T2_3int_3f64 ark_tuple_T2_3int_3f64;
+typedef struct ark_array_float ark_array_float;
+
+struct Header {
+	uint8_t tag;
+	uint32_t length;
+} __attribute__((packed));
+
+typedef int32_t Colour;
+enum {
+	Colour_Red = 0,
+	Colour_Green = 1,
+	Colour_Blue = 7,
+};
+
+struct ark_array_intptr_t {
+	size_t len;
+	intptr_t* ptr;
+};
+
+struct ark_tuple_T2_3int_3f64 {
+	intptr_t _0;
+	double _1;
+};
+
+struct ark_array_float {
+	size_t len;
+	float* ptr;
+};
+
+bool describe(Header* header, Colour colour, ark_array_intptr_t values, ark_tuple_T2_3int_3f64 pair);
+void scale(ark_array_float values, float factor);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif /* ARK_HEADER_H */
@@ -26,6 +26,9 @@ func (v *Codegen) typeToLLVMType(typ parser.Type) llvm.Type {
 	case parser.PrimitiveType:
 		return v.primitiveTypeToLLVMType(typ)
 	case parser.FunctionType:
+		if typ.IsClosure {
+			return v.closureTypeToLLVMType(typ)
+		}
 		return v.functionTypeToLLVMType(typ, true)
 	case parser.StructType:
 		return v.structTypeToLLVMType(typ)
@@ -33,10 +36,14 @@ func (v *Codegen) typeToLLVMType(typ parser.Type) llvm.Type {
 		return llvm.PointerType(v.typeToLLVMType(typ.Addressee), 0)
 	case parser.ArrayType:
 		return v.arrayTypeToLLVMType(typ)
+	case parser.VectorType:
+		return llvm.VectorType(v.typeToLLVMType(typ.MemberType), typ.Length)
 	case parser.TupleType:
 		return v.tupleTypeToLLVMType(typ)
 	case parser.EnumType:
 		return v.enumTypeToLLVMType(typ)
+	case parser.InterfaceType:
+		return v.interfaceTypeToLLVMType(typ)
 	case *parser.NamedType:
 		nt := typ
 		switch nt.Type.(type) {
@@ -64,18 +71,18 @@ func (v *Codegen) tupleTypeToLLVMType(typ parser.TupleType) llvm.Type {
 		fields[idx] = v.typeToLLVMType(mem)
 	}
 
-	return llvm.StructType(fields, false)
+	return v.context.StructType(fields, false)
 }
 
 func (v *Codegen) arrayTypeToLLVMType(typ parser.ArrayType) llvm.Type {
 	fields := []llvm.Type{v.typeToLLVMType(parser.PRIMITIVE_uint),
 		llvm.PointerType(v.typeToLLVMType(typ.MemberType), 0)}
 
-	return llvm.StructType(fields, false)
+	return v.context.StructType(fields, false)
 }
 
 func (v *Codegen) structTypeToLLVMType(typ parser.StructType) llvm.Type {
-	return llvm.StructType(v.structTypeToLLVMTypeFields(typ), typ.Attrs().Contains("packed"))
+	return v.context.StructType(v.structTypeToLLVMTypeFields(typ), typ.Attrs().Contains("packed"))
 }
 
 func (v *Codegen) structTypeToLLVMTypeFields(typ parser.StructType) []llvm.Type {
@@ -93,10 +100,10 @@ func (v *Codegen) structTypeToLLVMTypeFields(typ parser.StructType) []llvm.Type
 func (v *Codegen) enumTypeToLLVMType(typ parser.EnumType) llvm.Type {
 	if typ.Simple {
 		// TODO: Handle other integer size, maybe dynamic depending on max value? (1 / 2)
-		return llvm.IntType(32)
+		return v.context.IntType(32)
 	}
 
-	return llvm.StructType(v.enumTypeToLLVMTypeFields(typ), false)
+	return v.context.StructType(v.enumTypeToLLVMTypeFields(typ), false)
 }
 
 func (v *Codegen) enumTypeToLLVMTypeFields(typ parser.EnumType) []llvm.Type {
@@ -109,7 +116,7 @@ func (v *Codegen) enumTypeToLLVMTypeFields(typ parser.EnumType) []llvm.Type {
 	}
 
 	// TODO: verify no overflow
-	return []llvm.Type{llvm.IntType(32), llvm.ArrayType(llvm.IntType(8), int(longestLength))}
+	return []llvm.Type{v.context.IntType(32), llvm.ArrayType(v.context.IntType(8), int(longestLength))}
 }
 
 func (v *Codegen) functionTypeToLLVMType(typ parser.FunctionType, ptr bool) llvm.Type {
@@ -132,7 +139,7 @@ func (v *Codegen) functionTypeToLLVMType(typ parser.FunctionType, ptr bool) llvm
 	if typ.Return != nil {
 		returnType = v.typeToLLVMType(typ.Return)
 	} else {
-		returnType = llvm.VoidType()
+		returnType = v.context.VoidType()
 	}
 
 	// create the function type
@@ -145,35 +152,42 @@ func (v *Codegen) functionTypeToLLVMType(typ parser.FunctionType, ptr bool) llvm
 	return funcType
 }
 
+// The integer type with the size of a pointer. The IntPtrType of the target
+// data can't be used, as it belongs to the global context, while every worker
+// has a context of its own.
+func (v *Codegen) intPtrType() llvm.Type {
+	return v.context.IntType(v.targetData.PointerSize() * 8)
+}
+
 func (v *Codegen) primitiveTypeToLLVMType(typ parser.PrimitiveType) llvm.Type {
 	switch typ {
 	case parser.PRIMITIVE_int, parser.PRIMITIVE_uint:
-		return v.targetData.IntPtrType()
+		return v.intPtrType()
 
 	case parser.PRIMITIVE_s8, parser.PRIMITIVE_u8:
-		return llvm.IntType(8)
+		return v.context.IntType(8)
 	case parser.PRIMITIVE_s16, parser.PRIMITIVE_u16:
-		return llvm.IntType(16)
+		return v.context.IntType(16)
 	case parser.PRIMITIVE_s32, parser.PRIMITIVE_u32:
-		return llvm.IntType(32)
+		return v.context.IntType(32)
 	case parser.PRIMITIVE_s64, parser.PRIMITIVE_u64:
-		return llvm.IntType(64)
+		return v.context.IntType(64)
 	case parser.PRIMITIVE_s128, parser.PRIMITIVE_u128:
-		return llvm.IntType(128)
+		return v.context.IntType(128)
 
 	case parser.PRIMITIVE_f32:
-		return llvm.FloatType()
+		return v.context.FloatType()
 	case parser.PRIMITIVE_f64:
-		return llvm.DoubleType()
+		return v.context.DoubleType()
 	case parser.PRIMITIVE_f128:
-		return llvm.FP128Type()
+		return v.context.FP128Type()
 
 	case parser.PRIMITIVE_rune: // runes are signed 32-bit int
-		return llvm.IntType(32)
+		return v.context.IntType(32)
 	case parser.PRIMITIVE_bool:
-		return llvm.IntType(1)
+		return v.context.IntType(1)
 	case parser.PRIMITIVE_void:
-		return llvm.VoidType()
+		return v.context.VoidType()
 
 	default:
 		panic("Unimplemented primitive type in LLVM codegen")
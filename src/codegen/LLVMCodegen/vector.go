@@ -0,0 +1,111 @@
+package LLVMCodegen
+
+import (
+	"github.com/ark-lang/ark/src/parser"
+	"llvm.org/llvm/bindings/go/llvm"
+)
+
+// Vectors are operated on element-wise by the same instructions as their
+// members. Unlike operations on integers, the operations on vectors of
+// integers don't get runtime checks.
+func (v *Codegen) genVectorBinop(operator parser.BinOpType, typ parser.VectorType, lhand, rhand llvm.Value) llvm.Value {
+	member := typ.MemberType
+	isFloat := member.IsFloatingType()
+
+	switch operator {
+	case parser.BINOP_ADD:
+		if isFloat {
+			return v.builder().CreateFAdd(lhand, rhand, "")
+		}
+		return v.builder().CreateAdd(lhand, rhand, "")
+	case parser.BINOP_SUB:
+		if isFloat {
+			return v.builder().CreateFSub(lhand, rhand, "")
+		}
+		return v.builder().CreateSub(lhand, rhand, "")
+	case parser.BINOP_MUL:
+		if isFloat {
+			return v.builder().CreateFMul(lhand, rhand, "")
+		}
+		return v.builder().CreateMul(lhand, rhand, "")
+	case parser.BINOP_DIV:
+		if isFloat {
+			return v.builder().CreateFDiv(lhand, rhand, "")
+		} else if member.IsSigned() {
+			return v.builder().CreateSDiv(lhand, rhand, "")
+		}
+		return v.builder().CreateUDiv(lhand, rhand, "")
+	case parser.BINOP_MOD:
+		if isFloat {
+			return v.builder().CreateFRem(lhand, rhand, "")
+		} else if member.IsSigned() {
+			return v.builder().CreateSRem(lhand, rhand, "")
+		}
+		return v.builder().CreateURem(lhand, rhand, "")
+
+	// the comparison of each pair of members is sign extended from a bit
+	// into the mask
+	case parser.BINOP_GREATER, parser.BINOP_LESS, parser.BINOP_GREATER_EQ, parser.BINOP_LESS_EQ, parser.BINOP_EQ, parser.BINOP_NOT_EQ:
+		var cmp llvm.Value
+		if isFloat {
+			cmp = v.builder().CreateFCmp(comparisonOpToFloatPredicate(operator), lhand, rhand, "")
+		} else {
+			cmp = v.builder().CreateICmp(comparisonOpToIntPredicate(operator, member.IsSigned()), lhand, rhand, "")
+		}
+		return v.builder().CreateSExt(cmp, v.typeToLLVMType(typ.MaskType()), "")
+
+	case parser.BINOP_BIT_AND:
+		return v.builder().CreateAnd(lhand, rhand, "")
+	case parser.BINOP_BIT_OR:
+		return v.builder().CreateOr(lhand, rhand, "")
+	case parser.BINOP_BIT_XOR:
+		return v.builder().CreateXor(lhand, rhand, "")
+	case parser.BINOP_BIT_LEFT:
+		return v.builder().CreateShl(lhand, rhand, "")
+	case parser.BINOP_BIT_RIGHT:
+		if member.IsSigned() {
+			return v.builder().CreateAShr(lhand, rhand, "")
+		}
+		return v.builder().CreateLShr(lhand, rhand, "")
+
+	default:
+		panic("umimplented vector binop")
+	}
+}
+
+// Members missing from the literal are zero
+func (v *Codegen) genVectorLiteral(n *parser.CompositeLiteral) llvm.Value {
+	typ := n.Type.ActualType().(parser.VectorType)
+
+	if !v.inFunction() {
+		values := make([]llvm.Value, typ.Length)
+		for idx := range values {
+			if idx < len(n.Values) {
+				values[idx] = v.genExpr(n.Values[idx])
+			} else {
+				values[idx] = llvm.ConstNull(v.typeToLLVMType(typ.MemberType))
+			}
+		}
+		return llvm.ConstVector(values, false)
+	}
+
+	res := llvm.ConstNull(v.typeToLLVMType(typ))
+	for idx, value := range n.Values {
+		index := llvm.ConstInt(v.context.Int32Type(), uint64(idx), false)
+		res = v.builder().CreateInsertElement(res, v.genExpr(value), index, "")
+	}
+	return res
+}
+
+// Vectors are laid out in memory like arrays of their members, so an element
+// is accessed through a pointer to the first one
+func (v *Codegen) genVectorElementGEP(n *parser.ArrayAccessExpr, typ parser.VectorType) llvm.Value {
+	vector := v.genAccessGEP(n.Array)
+	index := v.genExpr(n.Subscript)
+
+	length := llvm.ConstInt(v.typeToLLVMType(parser.PRIMITIVE_uint), uint64(typ.Length), false)
+	v.genBoundsCheck(length, index, n.Subscript.GetType(), n.Pos())
+
+	members := v.builder().CreateBitCast(vector, llvm.PointerType(v.typeToLLVMType(typ.MemberType), 0), "")
+	return v.builder().CreateGEP(members, []llvm.Value{index}, "")
+}
@@ -77,6 +77,10 @@ type Variable struct {
 	ParentModule *Module
 	IsParameter  bool
 	IsArgument   bool
+	IsConstant   bool // declared with const, its value is known at compile time
+	Boxed        bool // mutable and captured by a lambda, so it is moved to the heap
+
+	constantDecl *VariableDecl // the declaration holding the value of a constant
 }
 
 func (v *Variable) String() string {
@@ -84,6 +88,9 @@ func (v *Variable) String() string {
 	if v.Mutable {
 		result += util.Green("[mutable] ")
 	}
+	if v.IsConstant {
+		result += util.Green("[constant] ")
+	}
 	for _, attr := range v.Attrs {
 		result += attr.String() + " "
 	}
@@ -102,6 +109,25 @@ type Function struct {
 	Receiver *VariableDecl // non-nil if non-static method
 
 	StaticReceiverType Type // non-nil if static
+
+	// Variables of enclosing functions used by a lambda. Mutable variables are
+	// boxed and captured by reference, all others by value.
+	Captures []*Variable
+
+	TypeParameters []ParameterType // non-nil if generic
+	TypeArguments  []Type          // non-nil if an instance of a generic function
+	generic        *genericFunction
+	template       *Function // the generic function, if an instance
+	inferred       bool
+}
+
+func (v *Function) addCapture(vari *Variable) {
+	for _, capture := range v.Captures {
+		if capture == vari {
+			return
+		}
+	}
+	v.Captures = append(v.Captures, vari)
 }
 
 func (v *Function) String() string {
@@ -117,6 +143,10 @@ func (v *Function) String() string {
 		result += ": " + util.Green(v.Type.Return.TypeName()) + " "
 	}
 
+	// the body of a generic function is only inferred in its instances
+	if v.IsGeneric() {
+		return result + ")"
+	}
 	if v.Body != nil {
 		result += v.Body.String()
 	}
@@ -387,6 +417,24 @@ func (v *CallStat) NodeName() string {
 	return "call statement"
 }
 
+// AsmStat
+
+type AsmStat struct {
+	nodePos
+	Asm *AsmExpr
+}
+
+func (v *AsmStat) statNode() {}
+
+func (v *AsmStat) String() string {
+	return "(" + util.Blue("AsmStat") + ": " +
+		v.Asm.String() + ")"
+}
+
+func (v *AsmStat) NodeName() string {
+	return "asm statement"
+}
+
 // DeferStat
 
 type DeferStat struct {
@@ -522,11 +570,10 @@ type MatchStat struct {
 
 	Target Expr
 
-	Branches map[Expr]Node
-}
-
-func newMatch() *MatchStat {
-	return &MatchStat{Branches: make(map[Expr]Node)}
+	// Patterns[i] is matched against Target before Patterns[i+1], and
+	// Bodies[i] is executed when it matches.
+	Patterns []Expr
+	Bodies   []*Block
 }
 
 func (v *MatchStat) statNode() {}
@@ -534,8 +581,8 @@ func (v *MatchStat) statNode() {}
 func (v *MatchStat) String() string {
 	result := "(" + util.Blue("MatchStat") + ": " + v.Target.String() + ":\n"
 
-	for pattern, stmt := range v.Branches {
-		result += "\t" + pattern.String() + " -> " + stmt.String() + "\n"
+	for i, pattern := range v.Patterns {
+		result += "\t" + pattern.String() + " -> " + v.Bodies[i].String() + "\n"
 	}
 
 	return result + ")"
@@ -717,6 +764,10 @@ type CompositeLiteral struct {
 	Fields []string // len(Fields) == len(Values). empty fields represented as ""
 	Values []Expr
 	InEnum bool
+
+	// the length of an array literal, if given. The elements after the
+	// values have their default value.
+	Length Expr
 }
 
 func (v *CompositeLiteral) exprNode() {}
@@ -854,6 +905,7 @@ type CallExpr struct {
 	ReceiverAccess Expr // nil if not method or if static
 
 	parameters []Type
+	typeHint   Type
 }
 
 func (v *CallExpr) exprNode() {}
@@ -871,7 +923,10 @@ func (v *CallExpr) String() string {
 
 func (v *CallExpr) GetType() Type {
 	if v.Function != nil {
-		return v.Function.GetType().(FunctionType).Return
+		// methods of interfaces are only resolved while inferring the call
+		if fnType, ok := v.Function.GetType().(FunctionType); ok {
+			return fnType.Return
+		}
 	}
 	return nil
 }
@@ -993,7 +1048,7 @@ func (v *ArrayAccessExpr) String() string {
 
 func (v *ArrayAccessExpr) GetType() Type {
 	if v.Array.GetType() != nil {
-		return v.Array.GetType().ActualType().(ArrayType).MemberType
+		return elementType(v.Array.GetType())
 	}
 	return nil
 }
@@ -1113,8 +1168,11 @@ func (v LambdaExpr) String() string {
 	return ret + ")"
 }
 
+// A lambda capturing variables is a closure
 func (v LambdaExpr) GetType() Type {
-	return v.Function.Type
+	typ := v.Function.Type
+	typ.IsClosure = len(v.Function.Captures) > 0
+	return typ
 }
 
 func (v LambdaExpr) NodeName() string {
@@ -1203,6 +1261,65 @@ func (v *DefaultExpr) NodeName() string {
 	return "default expression"
 }
 
+// AsmExpr
+
+// An inline assembly expression. The template and the constraints use the
+// syntax of LLVM inline assembly: operands are referred to as `$0`, `$1`, ...
+// in the order of the outputs followed by the inputs. The value of the
+// expression is the value of its only output, or a tuple of the values of its
+// outputs if it has several.
+type AsmExpr struct {
+	nodePos
+
+	Template string
+	Outputs  []*AsmOperand
+	Inputs   []*AsmOperand
+	Clobbers []string
+}
+
+type AsmOperand struct {
+	nodePos
+
+	Constraint string
+	Type       Type // set for outputs
+	Value      Expr // set for inputs
+}
+
+func (v *AsmExpr) exprNode() {}
+
+func (v *AsmExpr) String() string {
+	ret := "(" + util.Blue("AsmExpr") + ": " + colorizeEscapedString(EscapeString(v.Template))
+	for _, output := range v.Outputs {
+		ret += " " + output.Constraint + "(" + output.Type.TypeName() + ")"
+	}
+	for _, input := range v.Inputs {
+		ret += " " + input.Constraint + input.Value.String()
+	}
+	for _, clobber := range v.Clobbers {
+		ret += " ~" + clobber
+	}
+	return ret + ")"
+}
+
+func (v *AsmExpr) GetType() Type {
+	switch len(v.Outputs) {
+	case 0:
+		return PRIMITIVE_void
+	case 1:
+		return v.Outputs[0].Type
+	}
+
+	res := TupleType{Members: make([]Type, len(v.Outputs))}
+	for idx, output := range v.Outputs {
+		res.Members[idx] = output.Type
+	}
+	return res
+}
+
+func (v *AsmExpr) NodeName() string {
+	return "asm expression"
+}
+
 // DefaultMatchBranch
 
 type DefaultMatchBranch struct {
@@ -1222,3 +1339,132 @@ func (v *DefaultMatchBranch) GetType() Type {
 func (v *DefaultMatchBranch) NodeName() string {
 	return "default match branch"
 }
+
+// BindingPattern
+
+type BindingPattern struct {
+	nodePos
+	Variable *Variable
+}
+
+func (v *BindingPattern) exprNode() {}
+
+func (v *BindingPattern) String() string {
+	return "(" + util.Blue("BindingPattern") + ": " + v.Variable.String() + ")"
+}
+
+func (v *BindingPattern) GetType() Type {
+	return v.Variable.Type
+}
+
+func (v *BindingPattern) NodeName() string {
+	return "binding pattern"
+}
+
+// TuplePattern
+
+type TuplePattern struct {
+	nodePos
+	Type     Type
+	Elements []Expr
+}
+
+func (v *TuplePattern) exprNode() {}
+
+func (v *TuplePattern) String() string {
+	res := "(" + util.Blue("TuplePattern") + ":"
+	for _, elem := range v.Elements {
+		res += " " + elem.String()
+	}
+	return res + ")"
+}
+
+func (v *TuplePattern) GetType() Type {
+	return v.Type
+}
+
+func (v *TuplePattern) NodeName() string {
+	return "tuple pattern"
+}
+
+// StructPattern
+
+type StructPattern struct {
+	nodePos
+	Type   Type
+	Fields []string // len(Fields) == len(Values). after resolve, ordered the same as the struct's variables
+	Values []Expr
+	InEnum bool
+}
+
+func (v *StructPattern) exprNode() {}
+
+func (v *StructPattern) String() string {
+	res := "(" + util.Blue("StructPattern") + ":"
+	for i, val := range v.Values {
+		res += " " + v.Fields[i] + ": " + val.String()
+	}
+	return res + ")"
+}
+
+func (v *StructPattern) GetType() Type {
+	return v.Type
+}
+
+func (v *StructPattern) NodeName() string {
+	return "struct pattern"
+}
+
+// EnumPattern
+
+type EnumPattern struct {
+	nodePos
+	Type   Type
+	Member string
+
+	Payload Expr // nil, *TuplePattern or *StructPattern
+}
+
+func (v *EnumPattern) exprNode() {}
+
+func (v *EnumPattern) String() string {
+	res := "(" + util.Blue("EnumPattern") + ": " + v.Member
+	if v.Payload != nil {
+		res += " " + v.Payload.String()
+	}
+	return res + ")"
+}
+
+func (v *EnumPattern) GetType() Type {
+	return v.Type
+}
+
+func (v *EnumPattern) NodeName() string {
+	return "enum pattern"
+}
+
+// IsIrrefutablePattern returns true if the pattern matches every value of its type
+func IsIrrefutablePattern(pattern Expr) bool {
+	switch pattern := pattern.(type) {
+	case *DefaultMatchBranch, *BindingPattern:
+		return true
+
+	case *TuplePattern:
+		for _, elem := range pattern.Elements {
+			if !IsIrrefutablePattern(elem) {
+				return false
+			}
+		}
+		return true
+
+	case *StructPattern:
+		for _, val := range pattern.Values {
+			if !IsIrrefutablePattern(val) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
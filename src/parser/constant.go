@@ -0,0 +1,921 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/ark-lang/ark/src/util"
+)
+
+// The constant evaluator folds expressions whose value is known at compile
+// time into literals: numeric, bool, rune, string, composite, tuple and enum
+// literals. References to functions and addresses of global variables are
+// constant too, and are left as they are.
+
+const (
+	maxConstantCallDepth = 256
+	maxConstantSteps     = 1000000
+)
+
+type ConstantError struct {
+	Node Locatable
+	Msg  string
+
+	// the value depends on the size of a type, which is only known once the
+	// target is
+	TargetDependent bool
+}
+
+func (v *ConstantError) Error() string {
+	return v.Msg
+}
+
+type ConstantEvaluator struct {
+	// Returns the size of a type in bytes. If nil, sizeof can't be evaluated.
+	Sizeof func(Type) uint64
+
+	values     map[*Variable]Expr
+	evaluating map[*Variable]bool
+
+	frame *constantFrame
+	depth int
+	steps int
+}
+
+// The state of a call to a [const] function
+type constantFrame struct {
+	locals map[*Variable]Expr
+	result Expr
+}
+
+type constantFlow int
+
+const (
+	flowNormal constantFlow = iota
+	flowBreak
+	flowNext
+	flowReturn
+)
+
+func (v *ConstantEvaluator) err(n Locatable, err string, stuff ...interface{}) {
+	panic(&ConstantError{Node: n, Msg: fmt.Sprintf(err, stuff...)})
+}
+
+// Evaluates the expression, and returns the literal it folds to
+func (v *ConstantEvaluator) Evaluate(n Expr) (res Expr, err error) {
+	if v.values == nil {
+		v.values = make(map[*Variable]Expr)
+		v.evaluating = make(map[*Variable]bool)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			cerr, ok := r.(*ConstantError)
+			if !ok {
+				panic(r)
+			}
+
+			v.frame, v.depth, v.steps = nil, 0, 0
+			v.evaluating = make(map[*Variable]bool)
+			res, err = nil, cerr
+		}
+	}()
+
+	v.steps = 0
+	return v.eval(n), nil
+}
+
+// Evaluates an expression which has to be an integer, like the length of an
+// array
+func (v *ConstantEvaluator) EvaluateInt(n Expr) (*big.Int, error) {
+	res, err := v.Evaluate(n)
+	if err != nil {
+		return nil, err
+	}
+
+	value, _, isFloat, ok := numericValue(res)
+	if !ok || isFloat {
+		return nil, &ConstantError{Node: n, Msg: "Expected integer constant"}
+	}
+	return value, nil
+}
+
+func (v *ConstantEvaluator) eval(n Expr) Expr {
+	switch n := n.(type) {
+	case *NumericLiteral:
+		if n.IsFloat || n.Type.IsFloatingType() {
+			value := n.FloatValue
+			if !n.IsFloat {
+				value, _ = new(big.Float).SetInt(n.IntValue).Float64()
+			}
+			return v.newFloat(n, value, n.Type)
+		}
+		return v.newInt(n, n.IntValue, n.Type)
+
+	case *RuneLiteral, *BoolLiteral, *StringLiteral:
+		return n
+
+	case *FunctionAccessExpr, *LambdaExpr:
+		return n
+
+	case *AddressOfExpr:
+		if access, ok := n.Access.(*VariableAccessExpr); ok && v.isGlobal(access.Variable) {
+			return n
+		}
+		v.err(n, "Only the address of a global variable is constant")
+
+	case *TupleLiteral:
+		res := &TupleLiteral{Type: n.Type}
+		res.setPos(n.Pos())
+		for _, mem := range n.Members {
+			res.Members = append(res.Members, v.eval(mem))
+		}
+		return res
+
+	case *CompositeLiteral:
+		return v.evalCompositeLiteral(n)
+
+	case *EnumLiteral:
+		res := &EnumLiteral{Type: n.Type, Member: n.Member}
+		res.setPos(n.Pos())
+		if n.TupleLiteral != nil {
+			res.TupleLiteral = v.eval(n.TupleLiteral).(*TupleLiteral)
+		}
+		if n.CompositeLiteral != nil {
+			res.CompositeLiteral = v.eval(n.CompositeLiteral).(*CompositeLiteral)
+		}
+		return res
+
+	case *BinaryExpr:
+		if n.Op == BINOP_LOG_AND || n.Op == BINOP_LOG_OR {
+			lhand := v.evalBool(n.Lhand)
+			if lhand == (n.Op == BINOP_LOG_OR) {
+				return v.newBool(n, lhand)
+			}
+			return v.newBool(n, v.evalBool(n.Rhand))
+		}
+		return v.binary(n, n.Op, v.eval(n.Lhand), v.eval(n.Rhand), n.Type)
+
+	case *UnaryExpr:
+		return v.evalUnaryExpr(n)
+
+	case *CastExpr:
+		return v.cast(n, v.eval(n.Expr), n.Type)
+
+	case *VariableAccessExpr:
+		if v.frame != nil {
+			if value, ok := v.frame.locals[n.Variable]; ok {
+				return value
+			}
+		}
+		if n.Variable.IsConstant {
+			return v.constant(n, n.Variable)
+		}
+		v.err(n, "`%s` is not a constant", n.Variable.Name)
+
+	case *StructAccessExpr:
+		return v.field(n, v.eval(n.Struct), n.Member)
+
+	case *TupleAccessExpr:
+		tuple, ok := v.eval(n.Tuple).(*TupleLiteral)
+		if !ok {
+			v.err(n, "Expected constant tuple")
+		}
+		return tuple.Members[n.Index]
+
+	case *ArrayAccessExpr:
+		array := v.evalArray(n.Array)
+		return v.element(n, array, v.index(n.Subscript, array))
+
+	case *ArrayLenExpr:
+		if n.Expr == nil {
+			v.err(n, "Expected constant array")
+		}
+		return v.newInt(n, big.NewInt(v.arrayLength(v.evalArray(n.Expr))), PRIMITIVE_uint)
+
+	case *SizeofExpr:
+		if v.Sizeof == nil {
+			panic(&ConstantError{Node: n, Msg: "Size of type is not known yet", TargetDependent: true})
+		}
+
+		typ := n.Type
+		if n.Expr != nil {
+			typ = n.Expr.GetType()
+		}
+		return v.newInt(n, new(big.Int).SetUint64(v.Sizeof(typ)), PRIMITIVE_uint)
+
+	case *DefaultExpr:
+		return v.zero(n, n.Type)
+
+	case *CallExpr:
+		res := v.call(n)
+		if res == nil {
+			v.err(n, "Expected value, but `%s` returns nothing", v.calledFunction(n).Name)
+		}
+		return res
+	}
+
+	v.err(n, "%s can't be evaluated at compile time", util.CapitalizeFirst(n.NodeName()))
+	return nil
+}
+
+func (v *ConstantEvaluator) evalBool(n Expr) bool {
+	value, ok := v.eval(n).(*BoolLiteral)
+	if !ok {
+		v.err(n, "Expected constant boolean")
+	}
+	return value.Value
+}
+
+func (v *ConstantEvaluator) evalArray(n Expr) *CompositeLiteral {
+	array, ok := v.eval(n).(*CompositeLiteral)
+	if !ok {
+		v.err(n, "Expected constant array")
+	}
+	switch array.Type.ActualType().(type) {
+	case ArrayType, VectorType:
+	default:
+		v.err(n, "Expected constant array")
+	}
+	return array
+}
+
+func (v *ConstantEvaluator) evalCompositeLiteral(n *CompositeLiteral) Expr {
+	res := &CompositeLiteral{Type: n.Type, InEnum: n.InEnum}
+	res.setPos(n.Pos())
+
+	res.Fields = append(res.Fields, n.Fields...)
+	for _, val := range n.Values {
+		res.Values = append(res.Values, v.eval(val))
+	}
+
+	// fields missing from a struct literal are added if they have a default
+	// value in the struct
+	if structType, ok := n.Type.ActualType().(StructType); ok {
+		given := make(map[string]bool)
+		for _, field := range n.Fields {
+			given[field] = true
+		}
+
+		for _, decl := range structType.Variables {
+			if given[decl.Variable.Name] || decl.Assignment == nil {
+				continue
+			}
+			res.Fields = append(res.Fields, decl.Variable.Name)
+			res.Values = append(res.Values, v.eval(decl.Assignment))
+		}
+	}
+
+	if n.Length != nil {
+		length, _, isFloat, ok := numericValue(v.eval(n.Length))
+		if !ok || isFloat || !length.IsInt64() {
+			v.err(n.Length, "Expected integer constant as array length")
+		}
+		if length.Sign() < 0 {
+			v.err(n.Length, "Array length is negative")
+		}
+		if length.Int64() < int64(len(n.Values)) {
+			v.err(n, "Array literal has %d values, but a length of %d", len(n.Values), length.Int64())
+		}
+		res.Length = v.newInt(n.Length, length, PRIMITIVE_uint)
+	}
+
+	return res
+}
+
+// Returns the value of a constant declared with const
+func (v *ConstantEvaluator) constant(n Locatable, vari *Variable) Expr {
+	if value, ok := v.values[vari]; ok {
+		return value
+	}
+
+	if v.evaluating[vari] {
+		v.err(n, "Value of constant `%s` depends on itself", vari.Name)
+	}
+	if vari.constantDecl == nil || vari.constantDecl.Assignment == nil {
+		v.err(n, "Constant `%s` has no value", vari.Name)
+	}
+
+	// the constant is evaluated on its own, not with the locals of the
+	// function being evaluated
+	frame, depth := v.frame, v.depth
+	v.frame, v.depth = nil, 0
+	v.evaluating[vari] = true
+
+	value := v.eval(vari.constantDecl.Assignment)
+
+	delete(v.evaluating, vari)
+	v.frame, v.depth = frame, depth
+
+	v.values[vari] = value
+	return value
+}
+
+func (v *ConstantEvaluator) isGlobal(vari *Variable) bool {
+	if vari.IsParameter || vari.FromStruct {
+		return false
+	}
+	if v.frame != nil {
+		if _, ok := v.frame.locals[vari]; ok {
+			return false
+		}
+	}
+	if vari.ParentModule == nil {
+		return false
+	}
+
+	ident := vari.ParentModule.ModScope.GetIdent(UnresolvedName{Name: vari.Name})
+	return ident != nil && ident.Value == vari
+}
+
+//
+// Numbers
+//
+
+// Returns the integer or floating point value of a constant
+func numericValue(n Expr) (intValue *big.Int, floatValue float64, isFloat bool, ok bool) {
+	switch n := n.(type) {
+	case *NumericLiteral:
+		if n.IsFloat {
+			return nil, n.FloatValue, true, true
+		}
+		return n.IntValue, 0, false, true
+	case *RuneLiteral:
+		return big.NewInt(int64(n.Value)), 0, false, true
+	}
+	return nil, 0, false, false
+}
+
+// Returns the number of bits of an integer type
+func (v *ConstantEvaluator) intBits(typ Type) int {
+	switch typ.ActualType() {
+	case PRIMITIVE_s8, PRIMITIVE_u8:
+		return 8
+	case PRIMITIVE_s16, PRIMITIVE_u16:
+		return 16
+	case PRIMITIVE_s32, PRIMITIVE_u32, PRIMITIVE_rune:
+		return 32
+	case PRIMITIVE_s128, PRIMITIVE_u128:
+		return 128
+	case PRIMITIVE_int, PRIMITIVE_uint:
+		if v.Sizeof != nil {
+			return int(v.Sizeof(PRIMITIVE_int)) * 8
+		}
+	}
+	return 64
+}
+
+// Creates an integer constant of the type, reporting an error if the value
+// doesn't fit in it, as the generated code would panic
+func (v *ConstantEvaluator) newInt(n Locatable, value *big.Int, typ Type) Expr {
+	wrapped := v.wrap(value, typ)
+	if wrapped.Cmp(value) != 0 {
+		v.err(n, "Constant value %s overflows type `%s`", value, typ.TypeName())
+	}
+	return v.intLiteral(n, wrapped, typ)
+}
+
+// Creates an integer constant of the type, wrapping the value around like
+// casts and bitwise operators do
+func (v *ConstantEvaluator) wrapInt(n Locatable, value *big.Int, typ Type) Expr {
+	return v.intLiteral(n, v.wrap(value, typ), typ)
+}
+
+// Returns the value as it is represented by the integer type
+func (v *ConstantEvaluator) wrap(value *big.Int, typ Type) *big.Int {
+	bits := uint(v.intBits(typ))
+	modulus := new(big.Int).Lsh(big.NewInt(1), bits)
+
+	wrapped := new(big.Int).Mod(value, modulus)
+	if typ.IsSigned() || typ.ActualType() == PRIMITIVE_rune {
+		if wrapped.Bit(int(bits-1)) == 1 {
+			wrapped.Sub(wrapped, modulus)
+		}
+	}
+	return wrapped
+}
+
+func (v *ConstantEvaluator) intLiteral(n Locatable, value *big.Int, typ Type) Expr {
+	if typ.ActualType() == PRIMITIVE_rune {
+		res := &RuneLiteral{Value: rune(value.Int64())}
+		res.setPos(n.Pos())
+		return res
+	}
+
+	res := &NumericLiteral{IntValue: value, Type: typ}
+	res.setPos(n.Pos())
+	return res
+}
+
+func (v *ConstantEvaluator) newFloat(n Locatable, value float64, typ Type) Expr {
+	if typ.ActualType() == PRIMITIVE_f32 {
+		value = float64(float32(value))
+	}
+
+	res := &NumericLiteral{FloatValue: value, IsFloat: true, Type: typ}
+	res.setPos(n.Pos())
+	return res
+}
+
+func (v *ConstantEvaluator) newBool(n Locatable, value bool) Expr {
+	res := &BoolLiteral{Value: value}
+	res.setPos(n.Pos())
+	return res
+}
+
+func (v *ConstantEvaluator) binary(n Locatable, op BinOpType, lhand, rhand Expr, typ Type) Expr {
+	if lbool, ok := lhand.(*BoolLiteral); ok {
+		rbool, ok := rhand.(*BoolLiteral)
+		if !ok {
+			v.err(n, "Expected constant boolean")
+		}
+
+		switch op {
+		case BINOP_EQ:
+			return v.newBool(n, lbool.Value == rbool.Value)
+		case BINOP_NOT_EQ:
+			return v.newBool(n, lbool.Value != rbool.Value)
+		}
+		v.err(n, "Invalid operator `%s` for booleans", op.OpString())
+	}
+
+	lint, lfloat, lIsFloat, lok := numericValue(lhand)
+	rint, rfloat, rIsFloat, rok := numericValue(rhand)
+	if !lok || !rok {
+		v.err(n, "Operator `%s` can't be evaluated at compile time for these operands", op.OpString())
+	}
+
+	if lIsFloat || rIsFloat {
+		if !lIsFloat {
+			lfloat, _ = new(big.Float).SetInt(lint).Float64()
+		}
+		if !rIsFloat {
+			rfloat, _ = new(big.Float).SetInt(rint).Float64()
+		}
+		return v.binaryFloat(n, op, lfloat, rfloat, typ)
+	}
+	return v.binaryInt(n, op, lint, rint, typ)
+}
+
+func (v *ConstantEvaluator) binaryInt(n Locatable, op BinOpType, lhand, rhand *big.Int, typ Type) Expr {
+	res := new(big.Int)
+
+	switch op {
+	case BINOP_ADD:
+		res.Add(lhand, rhand)
+	case BINOP_SUB:
+		res.Sub(lhand, rhand)
+	case BINOP_MUL:
+		res.Mul(lhand, rhand)
+
+	case BINOP_DIV, BINOP_MOD:
+		if rhand.Sign() == 0 {
+			v.err(n, "Division by zero in constant expression")
+		}
+		if op == BINOP_DIV {
+			res.Quo(lhand, rhand)
+		} else {
+			res.Rem(lhand, rhand)
+		}
+
+	case BINOP_BIT_AND:
+		return v.wrapInt(n, res.And(lhand, rhand), typ)
+	case BINOP_BIT_OR:
+		return v.wrapInt(n, res.Or(lhand, rhand), typ)
+	case BINOP_BIT_XOR:
+		return v.wrapInt(n, res.Xor(lhand, rhand), typ)
+
+	case BINOP_BIT_LEFT, BINOP_BIT_RIGHT:
+		if rhand.Sign() < 0 || rhand.Cmp(big.NewInt(int64(v.intBits(typ)))) >= 0 {
+			v.err(n, "Shift by %s is out of range for type `%s`", rhand, typ.TypeName())
+		}
+		// bits shifted out are discarded
+		if op == BINOP_BIT_LEFT {
+			return v.wrapInt(n, res.Lsh(lhand, uint(rhand.Uint64())), typ)
+		}
+		return v.wrapInt(n, res.Rsh(lhand, uint(rhand.Uint64())), typ)
+
+	case BINOP_GREATER:
+		return v.newBool(n, lhand.Cmp(rhand) > 0)
+	case BINOP_LESS:
+		return v.newBool(n, lhand.Cmp(rhand) < 0)
+	case BINOP_GREATER_EQ:
+		return v.newBool(n, lhand.Cmp(rhand) >= 0)
+	case BINOP_LESS_EQ:
+		return v.newBool(n, lhand.Cmp(rhand) <= 0)
+	case BINOP_EQ:
+		return v.newBool(n, lhand.Cmp(rhand) == 0)
+	case BINOP_NOT_EQ:
+		return v.newBool(n, lhand.Cmp(rhand) != 0)
+
+	default:
+		v.err(n, "Invalid operator `%s` for integers", op.OpString())
+	}
+
+	return v.newInt(n, res, typ)
+}
+
+func (v *ConstantEvaluator) binaryFloat(n Locatable, op BinOpType, lhand, rhand float64, typ Type) Expr {
+	switch op {
+	case BINOP_ADD:
+		return v.newFloat(n, lhand+rhand, typ)
+	case BINOP_SUB:
+		return v.newFloat(n, lhand-rhand, typ)
+	case BINOP_MUL:
+		return v.newFloat(n, lhand*rhand, typ)
+	case BINOP_DIV:
+		return v.newFloat(n, lhand/rhand, typ)
+	case BINOP_MOD:
+		return v.newFloat(n, math.Mod(lhand, rhand), typ)
+
+	case BINOP_GREATER:
+		return v.newBool(n, lhand > rhand)
+	case BINOP_LESS:
+		return v.newBool(n, lhand < rhand)
+	case BINOP_GREATER_EQ:
+		return v.newBool(n, lhand >= rhand)
+	case BINOP_LESS_EQ:
+		return v.newBool(n, lhand <= rhand)
+	case BINOP_EQ:
+		return v.newBool(n, lhand == rhand)
+	case BINOP_NOT_EQ:
+		return v.newBool(n, lhand != rhand)
+	}
+
+	v.err(n, "Invalid operator `%s` for floating point numbers", op.OpString())
+	return nil
+}
+
+func (v *ConstantEvaluator) evalUnaryExpr(n *UnaryExpr) Expr {
+	if n.Op == UNOP_LOG_NOT {
+		return v.newBool(n, !v.evalBool(n.Expr))
+	}
+
+	intValue, floatValue, isFloat, ok := numericValue(v.eval(n.Expr))
+	if !ok {
+		v.err(n, "%s can't be evaluated at compile time", util.CapitalizeFirst(n.NodeName()))
+	}
+
+	switch n.Op {
+	case UNOP_NEGATIVE:
+		if isFloat {
+			return v.newFloat(n, -floatValue, n.Type)
+		}
+		return v.newInt(n, new(big.Int).Neg(intValue), n.Type)
+
+	case UNOP_BIT_NOT:
+		if !isFloat {
+			return v.wrapInt(n, new(big.Int).Not(intValue), n.Type)
+		}
+	}
+
+	v.err(n, "%s can't be evaluated at compile time", util.CapitalizeFirst(n.NodeName()))
+	return nil
+}
+
+func (v *ConstantEvaluator) cast(n Locatable, value Expr, typ Type) Expr {
+	actual := typ.ActualType()
+
+	// casts between named types only change the type of the value
+	if value.GetType() != nil && value.GetType().ActualType().Equals(actual) {
+		switch value := value.(type) {
+		case *NumericLiteral:
+			res := *value
+			res.Type = typ
+			return &res
+		case *CompositeLiteral:
+			res := *value
+			res.Type = typ
+			return &res
+		case *TupleLiteral:
+			res := *value
+			res.Type = typ
+			return &res
+		case *EnumLiteral:
+			res := *value
+			res.Type = typ
+			return &res
+		}
+		return value
+	}
+
+	intValue, floatValue, isFloat, ok := numericValue(value)
+	if ok && (actual.IsIntegerType() || actual == PRIMITIVE_rune) {
+		if isFloat {
+			if math.IsNaN(floatValue) || math.IsInf(floatValue, 0) {
+				v.err(n, "Cast of %v to `%s` is out of range", floatValue, typ.TypeName())
+			}
+			intValue, _ = big.NewFloat(floatValue).Int(nil)
+		}
+		return v.wrapInt(n, intValue, typ)
+	}
+
+	if ok && actual.IsFloatingType() {
+		if !isFloat {
+			floatValue, _ = new(big.Float).SetInt(intValue).Float64()
+		}
+		return v.newFloat(n, floatValue, typ)
+	}
+
+	v.err(n, "Cast to `%s` can't be evaluated at compile time", typ.TypeName())
+	return nil
+}
+
+//
+// Composite values
+//
+
+func (v *ConstantEvaluator) field(n Locatable, value Expr, member string) Expr {
+	lit, ok := value.(*CompositeLiteral)
+	if !ok {
+		v.err(n, "Expected constant struct")
+	}
+
+	for idx, field := range lit.Fields {
+		if field == member {
+			return lit.Values[idx]
+		}
+	}
+
+	// fields missing from the literal have their default value
+	structType, ok := lit.Type.ActualType().(StructType)
+	if !ok {
+		v.err(n, "Expected constant struct")
+	}
+	decl := structType.GetVariableDecl(member)
+	if decl.Assignment != nil {
+		return v.eval(decl.Assignment)
+	}
+	return v.zero(n, decl.Variable.Type)
+}
+
+func (v *ConstantEvaluator) arrayLength(array *CompositeLiteral) int64 {
+	if vec, ok := array.Type.ActualType().(VectorType); ok {
+		return int64(vec.Length)
+	}
+	if array.Length != nil {
+		length, _, _, _ := numericValue(array.Length)
+		return length.Int64()
+	}
+	return int64(len(array.Values))
+}
+
+func (v *ConstantEvaluator) index(n Expr, array *CompositeLiteral) int {
+	index, _, isFloat, ok := numericValue(v.eval(n))
+	if !ok || isFloat {
+		v.err(n, "Expected integer constant as index")
+	}
+
+	if index.Sign() < 0 || index.Cmp(big.NewInt(v.arrayLength(array))) >= 0 {
+		v.err(n, "Index %s is out of bounds of array of length %d", index, v.arrayLength(array))
+	}
+	return int(index.Int64())
+}
+
+func (v *ConstantEvaluator) element(n Locatable, array *CompositeLiteral, index int) Expr {
+	if index < len(array.Values) {
+		return array.Values[index]
+	}
+
+	// an array literal with a length and a vector literal are filled up with
+	// default values
+	return v.zero(n, elementType(array.Type))
+}
+
+// Returns the default value of a type
+func (v *ConstantEvaluator) zero(n Locatable, typ Type) Expr {
+	switch actual := typ.ActualType().(type) {
+	case PrimitiveType:
+		switch {
+		case actual == PRIMITIVE_bool:
+			return v.newBool(n, false)
+		case actual.IsFloatingType():
+			return v.newFloat(n, 0, typ)
+		case actual.IsIntegerType() || actual == PRIMITIVE_rune:
+			return v.newInt(n, big.NewInt(0), typ)
+		}
+
+	case StructType:
+		res := &CompositeLiteral{Type: typ}
+		res.setPos(n.Pos())
+		for _, decl := range actual.Variables {
+			res.Fields = append(res.Fields, decl.Variable.Name)
+			if decl.Assignment != nil {
+				res.Values = append(res.Values, v.eval(decl.Assignment))
+			} else {
+				res.Values = append(res.Values, v.zero(n, decl.Variable.Type))
+			}
+		}
+		return res
+
+	case TupleType:
+		res := &TupleLiteral{Type: typ}
+		res.setPos(n.Pos())
+		for _, mem := range actual.Members {
+			res.Members = append(res.Members, v.zero(n, mem))
+		}
+		return res
+
+	case ArrayType, VectorType:
+		res := &CompositeLiteral{Type: typ}
+		res.setPos(n.Pos())
+		return res
+	}
+
+	v.err(n, "Type `%s` has no constant default value", typ.TypeName())
+	return nil
+}
+
+//
+// Calls to [const] functions
+//
+
+func (v *ConstantEvaluator) calledFunction(n *CallExpr) *Function {
+	if access, ok := n.Function.(*FunctionAccessExpr); ok {
+		return access.Function
+	}
+	return nil
+}
+
+// Evaluates a call to a [const] function, returns nil if it returns nothing
+func (v *ConstantEvaluator) call(n *CallExpr) Expr {
+	fn := v.calledFunction(n)
+	if fn == nil {
+		v.err(n, "Only calls to [const] functions can be evaluated at compile time")
+	}
+	if !fn.Type.Attrs().Contains("const") {
+		v.err(n, "Call to `%s`, which isn't a [const] function", fn.Name)
+	}
+	if fn.Body == nil {
+		v.err(n, "[const] function `%s` has no body", fn.Name)
+	}
+	if v.depth >= maxConstantCallDepth {
+		v.err(n, "Too many nested calls to [const] functions")
+	}
+
+	frame := &constantFrame{locals: make(map[*Variable]Expr)}
+	if fn.Receiver != nil {
+		frame.locals[fn.Receiver.Variable] = v.eval(n.ReceiverAccess)
+	}
+	for idx, arg := range n.Arguments {
+		frame.locals[fn.Parameters[idx].Variable] = v.eval(arg)
+	}
+
+	outer := v.frame
+	v.frame = frame
+	v.depth++
+
+	v.execBlock(fn.Body)
+
+	v.depth--
+	v.frame = outer
+
+	if frame.result == nil && !fn.Type.Return.IsVoidType() {
+		v.err(n, "[const] function `%s` didn't return a value", fn.Name)
+	}
+	return frame.result
+}
+
+func (v *ConstantEvaluator) execBlock(n *Block) constantFlow {
+	for _, node := range n.Nodes {
+		if flow := v.exec(node); flow != flowNormal {
+			return flow
+		}
+	}
+	return flowNormal
+}
+
+func (v *ConstantEvaluator) exec(n Node) constantFlow {
+	v.steps++
+	if v.steps > maxConstantSteps {
+		v.err(n, "Constant evaluation took too many steps")
+	}
+
+	switch n := n.(type) {
+	case *VariableDecl:
+		if n.Assignment != nil {
+			v.frame.locals[n.Variable] = v.eval(n.Assignment)
+		} else {
+			v.frame.locals[n.Variable] = v.zero(n, n.Variable.Type)
+		}
+
+	case *AssignStat:
+		v.assign(n.Access, v.eval(n.Assignment))
+
+	case *BinopAssignStat:
+		value := v.binary(n, n.Operator, v.eval(n.Access), v.eval(n.Assignment), n.Access.GetType())
+		v.assign(n.Access, value)
+
+	case *CallStat:
+		v.call(n.Call)
+
+	case *BlockStat:
+		return v.execBlock(n.Block)
+
+	case *IfStat:
+		for idx, expr := range n.Exprs {
+			if v.evalBool(expr) {
+				return v.execBlock(n.Bodies[idx])
+			}
+		}
+		if n.Else != nil {
+			return v.execBlock(n.Else)
+		}
+
+	case *LoopStat:
+		for n.LoopType != LOOP_TYPE_CONDITIONAL || v.evalBool(n.Condition) {
+			switch v.execBlock(n.Body) {
+			case flowBreak:
+				return flowNormal
+			case flowReturn:
+				return flowReturn
+			}
+
+			v.steps++
+			if v.steps > maxConstantSteps {
+				v.err(n, "Constant evaluation took too many steps")
+			}
+		}
+
+	case *BreakStat:
+		return flowBreak
+
+	case *NextStat:
+		return flowNext
+
+	case *ReturnStat:
+		if n.Value != nil {
+			v.frame.result = v.eval(n.Value)
+		}
+		return flowReturn
+
+	default:
+		v.err(n, "%s can't be evaluated at compile time", util.CapitalizeFirst(n.NodeName()))
+	}
+
+	return flowNormal
+}
+
+// Stores a value in a local variable, or a part of one. Values are never
+// modified, the parts containing the value are copied instead.
+func (v *ConstantEvaluator) assign(n AccessExpr, value Expr) {
+	switch n := n.(type) {
+	case *VariableAccessExpr:
+		if _, ok := v.frame.locals[n.Variable]; !ok {
+			v.err(n, "Only local variables can be assigned at compile time")
+		}
+		v.frame.locals[n.Variable] = value
+
+	case *StructAccessExpr:
+		lit, ok := v.eval(n.Struct).(*CompositeLiteral)
+		if !ok {
+			v.err(n, "Expected constant struct")
+		}
+
+		res := *lit
+		res.Fields = append([]string{}, lit.Fields...)
+		res.Values = append([]Expr{}, lit.Values...)
+
+		found := false
+		for idx, field := range res.Fields {
+			if field == n.Member {
+				res.Values[idx] = value
+				found = true
+			}
+		}
+		if !found {
+			res.Fields = append(res.Fields, n.Member)
+			res.Values = append(res.Values, value)
+		}
+		v.assign(n.Struct, &res)
+
+	case *TupleAccessExpr:
+		lit, ok := v.eval(n.Tuple).(*TupleLiteral)
+		if !ok {
+			v.err(n, "Expected constant tuple")
+		}
+
+		res := *lit
+		res.Members = append([]Expr{}, lit.Members...)
+		res.Members[n.Index] = value
+		v.assign(n.Tuple, &res)
+
+	case *ArrayAccessExpr:
+		lit := v.evalArray(n.Array)
+		index := v.index(n.Subscript, lit)
+
+		res := *lit
+		res.Fields = append([]string{}, lit.Fields...)
+		res.Values = append([]Expr{}, lit.Values...)
+		for len(res.Values) <= index {
+			res.Values = append(res.Values, v.element(n, lit, len(res.Values)))
+			res.Fields = append(res.Fields, "")
+		}
+		res.Values[index] = value
+		v.assign(n.Array, &res)
+
+	default:
+		v.err(n, "%s can't be assigned at compile time", util.CapitalizeFirst(n.NodeName()))
+	}
+}
@@ -170,6 +170,7 @@ func (v *TupleTypeNode) construct(c *Constructor) Type {
 func (v *FunctionTypeNode) construct(c *Constructor) Type {
 	res := FunctionType{
 		IsVariadic: v.IsVariadic,
+		IsClosure:  v.IsClosure,
 		Parameters: c.constructTypes(v.ParameterTypes),
 		attrs:      v.Attrs(),
 	}
@@ -187,6 +188,10 @@ func (v *ArrayTypeNode) construct(c *Constructor) Type {
 	return ArrayOf(memberType)
 }
 
+func (v *VectorTypeNode) construct(c *Constructor) Type {
+	return VectorType{MemberType: c.constructType(v.MemberType), Length: v.Length}
+}
+
 func (v *TypeReferenceNode) construct(c *Constructor) Type {
 	parameters := c.constructTypes(v.TypeParameters)
 	res := UnresolvedType{Name: toUnresolvedName(v.Reference), Parameters: parameters}
@@ -365,18 +370,42 @@ func (v *FunctionDeclNode) construct(c *Constructor) Node {
 	function := v.Function.construct(c)
 	function.Type.attrs = v.Attrs()
 
+	if sigil := v.Function.Header.GenericSigil; sigil != nil {
+		if function.Receiver != nil || function.StaticReceiverType != nil {
+			c.err(sigil.Where(), "Methods cannot be generic")
+		}
+
+		for _, param := range sigil.Parameters {
+			function.TypeParameters = append(function.TypeParameters, ParameterType{Name: param.Name.Value})
+		}
+
+		function.generic = &genericFunction{
+			node:        v,
+			constructor: *c,
+			instances:   make(map[string]*Function),
+		}
+	}
+
 	res := &FunctionDecl{
 		docs:      v.DocComments(),
 		Function:  function,
 		Prototype: v.Function.Body == nil,
 	}
 
+	if function.generic != nil {
+		function.generic.decl = res
+	}
+
 	res.SetPublic(v.IsPublic())
 	res.setPos(v.Where().Start())
 	return res
 }
 
 func (v *LambdaExprNode) construct(c *Constructor) Expr {
+	if v.Function.Header.GenericSigil != nil {
+		c.err(v.Function.Header.GenericSigil.Where(), "Lambdas cannot be generic")
+	}
+
 	function := v.Function.construct(c)
 	function.Type.attrs = v.Attrs()
 
@@ -445,6 +474,7 @@ func (v *VarDeclNode) construct(c *Constructor) Node {
 		Name:         v.Name.Value,
 		Attrs:        v.Attrs(),
 		Mutable:      v.Mutable.Value != "",
+		IsConstant:   v.Constant.Value != "",
 		ParentModule: c.module,
 	}
 
@@ -460,6 +490,9 @@ func (v *VarDeclNode) construct(c *Constructor) Node {
 	if v.Value != nil {
 		res.Assignment = c.constructExpr(v.Value)
 	}
+	if variable.IsConstant {
+		variable.constantDecl = res
+	}
 
 	res.SetPublic(v.IsPublic())
 	res.setPos(v.Where().Start())
@@ -496,29 +529,86 @@ func (v *IfStatNode) construct(c *Constructor) Node {
 func (v *MatchStatNode) construct(c *Constructor) Node {
 	res := &MatchStat{}
 	res.Target = c.constructExpr(v.Value)
-	res.Branches = make(map[Expr]Node)
 	for _, branch := range v.Cases {
-		var pattern Expr
-		if dpn, ok := branch.Pattern.(*DefaultPatternNode); ok {
-			pattern = &DefaultMatchBranch{}
-			pattern.setPos(dpn.Where().Start())
-		} else {
-			pattern = c.constructExpr(branch.Pattern)
-		}
+		pattern := c.constructExpr(branch.Pattern)
 
+		// arms that aren't blocks get wrapped in one, so that each arm has
+		// its own scope for defers
 		body := c.constructNode(branch.Body)
-		res.Branches[pattern] = body
+		block, ok := body.(*Block)
+		if !ok {
+			block = &Block{Nodes: []Node{body}}
+			block.setPos(body.Pos())
+		}
+
+		res.Patterns = append(res.Patterns, pattern)
+		res.Bodies = append(res.Bodies, block)
 	}
 	res.setPos(v.Where().Start())
 	return res
 }
 
-func (v *DefaultPatternNode) construct(c *Constructor) Node {
+func (v *DefaultPatternNode) construct(c *Constructor) Expr {
 	res := &DefaultMatchBranch{}
 	res.setPos(v.Where().Start())
 	return res
 }
 
+func (v *BindingPatternNode) construct(c *Constructor) Expr {
+	res := &BindingPattern{
+		Variable: &Variable{
+			Name:         v.Name.Value,
+			Mutable:      v.Mutable.Value != "",
+			ParentModule: c.module,
+		},
+	}
+	res.setPos(v.Where().Start())
+	return res
+}
+
+func (v *TuplePatternNode) construct(c *Constructor) Expr {
+	res := &TuplePattern{
+		Elements: c.constructExprs(v.Elements),
+	}
+	res.setPos(v.Where().Start())
+	return res
+}
+
+func (v *StructPatternNode) construct(c *Constructor) Expr {
+	res := &StructPattern{
+		Type: UnresolvedType{Name: toUnresolvedName(v.Name)},
+	}
+
+	for i, val := range v.Values {
+		res.Fields = append(res.Fields, v.Fields[i].Value)
+		res.Values = append(res.Values, c.constructExpr(val))
+	}
+
+	res.setPos(v.Where().Start())
+	return res
+}
+
+func (v *EnumPatternNode) construct(c *Constructor) Expr {
+	if len(v.Name.Modules) == 0 {
+		c.err(v.Name.Where(), "Expected enum member in pattern, found `%s`", v.Name.Name.Value)
+	}
+
+	enumName, memberName := toUnresolvedName(v.Name).Split()
+
+	payload := &TuplePattern{
+		Elements: c.constructExprs(v.Tuple.Elements),
+	}
+	payload.setPos(v.Tuple.Where().Start())
+
+	res := &EnumPattern{
+		Type:    UnresolvedType{Name: enumName},
+		Member:  memberName,
+		Payload: payload,
+	}
+	res.setPos(v.Where().Start())
+	return res
+}
+
 func (v *LoopStatNode) construct(c *Constructor) Node {
 	res := &LoopStat{}
 	if v.Condition != nil {
@@ -575,6 +665,13 @@ func (v *CallStatNode) construct(c *Constructor) Node {
 	return res
 }
 
+func (v *AsmStatNode) construct(c *Constructor) Node {
+	res := &AsmStat{}
+	res.Asm = c.constructExpr(v.Asm).(*AsmExpr)
+	res.setPos(v.Where().Start())
+	return res
+}
+
 func (v *AssignStatNode) construct(c *Constructor) Node {
 	res := &AssignStat{}
 	res.Access = c.constructExpr(v.Target).(AccessExpr) // TODO: Error message
@@ -631,6 +728,25 @@ func (v *DefaultExprNode) construct(c *Constructor) Expr {
 	return res
 }
 
+func (v *AsmExprNode) construct(c *Constructor) Expr {
+	res := &AsmExpr{Template: v.Template.Value}
+	for _, output := range v.Outputs {
+		operand := &AsmOperand{Constraint: output.Constraint.Value, Type: c.constructType(output.Value)}
+		operand.setPos(output.Where().Start())
+		res.Outputs = append(res.Outputs, operand)
+	}
+	for _, input := range v.Inputs {
+		operand := &AsmOperand{Constraint: input.Constraint.Value, Value: c.constructExpr(input.Value)}
+		operand.setPos(input.Where().Start())
+		res.Inputs = append(res.Inputs, operand)
+	}
+	for _, clobber := range v.Clobbers {
+		res.Clobbers = append(res.Clobbers, clobber.Value)
+	}
+	res.setPos(v.Where().Start())
+	return res
+}
+
 func (v *AddrofExprNode) construct(c *Constructor) Expr {
 	res := &AddressOfExpr{
 		Mutable: v.Mutable,
@@ -756,11 +872,16 @@ func (v *CompositeLiteralNode) construct(c *Constructor) Expr {
 	res := &CompositeLiteral{}
 	res.Type = c.constructType(v.Type)
 
+	if array, ok := v.Type.(*ArrayTypeNode); ok && array.Length != nil {
+		res.Length = c.constructExpr(array.Length)
+	}
+
 	for i, val := range v.Values {
 		res.Fields = append(res.Fields, v.Fields[i].Value)
 		res.Values = append(res.Values, c.constructExpr(val))
 	}
 
+	res.setPos(v.Where().Start())
 	return res
 }
 
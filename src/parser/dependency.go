@@ -2,6 +2,7 @@ package parser
 
 import (
 	"bytes"
+	"sort"
 )
 
 type DependencyNode struct {
@@ -46,6 +47,59 @@ func (v *DependencyGraph) AddDependency(source, dependency *ModuleName) {
 	v.EdgesFrom[source.String()] = append(v.EdgesFrom[source.String()], dep)
 }
 
+// Returns the names of the modules the module depends on, directly or through
+// other modules, sorted by name
+func (v *DependencyGraph) TransitiveDependencies(module *ModuleName) []string {
+	seen := make(map[string]bool)
+
+	var visit func(name string)
+	visit = func(name string) {
+		for _, edge := range v.EdgesFrom[name] {
+			dep := edge.Dst.Module.String()
+			if !seen[dep] {
+				seen[dep] = true
+				visit(dep)
+			}
+		}
+	}
+	visit(module.String())
+
+	var res []string
+	for dep := range seen {
+		if dep != module.String() {
+			res = append(res, dep)
+		}
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+// Returns the length of the longest chain of dependencies below the module, so
+// a module is always deeper than the modules it depends on. The graph must not
+// have cycles.
+func (v *DependencyGraph) Depth(module *ModuleName) int {
+	depths := make(map[string]int)
+
+	var visit func(name string) int
+	visit = func(name string) int {
+		if depth, ok := depths[name]; ok {
+			return depth
+		}
+
+		depth := 0
+		for _, edge := range v.EdgesFrom[name] {
+			if dep := visit(edge.Dst.Module.String()) + 1; dep > depth {
+				depth = dep
+			}
+		}
+		depths[name] = depth
+		return depth
+	}
+
+	return visit(module.String())
+}
+
 func (d *DependencyGraph) DetectCycles() []string {
 	scgs := d.tarjan()
 
@@ -0,0 +1,244 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Generic functions are never resolved or generated themselves. Instead, every
+// use of a generic function with a distinct list of type arguments creates an
+// instance of it, which is constructed again from the parse tree and then
+// resolved with the type parameters substituted by the type arguments, the
+// same way the uses of generic named types are resolved. Instances are added
+// to the submodule of the generic function, so the following passes handle
+// them like any other function.
+
+type genericFunction struct {
+	node        *FunctionDeclNode
+	decl        *FunctionDecl
+	constructor Constructor // the state of the constructor at the declaration
+	instances   map[string]*Function
+	signature   *FunctionType
+}
+
+func (v *Function) IsGeneric() bool {
+	return v.generic != nil
+}
+
+// Creates a resolver for the scope the generic function was declared in, with
+// a new scope for the type parameters.
+func (v *genericFunction) resolver() *Resolver {
+	mod := v.decl.Function.ParentModule
+
+	res := &Resolver{
+		modules:   v.constructor.modules,
+		module:    mod,
+		cModule:   mod.ModScope.UsedModules["C"],
+		curSubmod: v.constructor.curSubmod,
+		curScope:  mod.ModScope,
+	}
+	res.EnterScope()
+
+	return res
+}
+
+// Returns the instance of the generic function fn with the type arguments
+// args, creating and resolving it if it doesn't exist yet.
+func (v *Resolver) instantiateFunction(loc Locatable, fn *Function, args []Type) *Function {
+	if len(args) != len(fn.TypeParameters) {
+		v.err(loc, "Function `%s` takes %d type arguments, have %d",
+			fn.Name, len(fn.TypeParameters), len(args))
+	}
+
+	return fn.instantiate(args)
+}
+
+func (v *Function) instantiate(args []Type) *Function {
+	key := ""
+	for _, arg := range args {
+		key += TypeMangledName(MANGLE_ARK_UNSTABLE, arg)
+	}
+
+	if instance, ok := v.generic.instances[key]; ok {
+		return instance
+	}
+
+	c := v.generic.constructor
+	decl := v.generic.node.construct(&c).(*FunctionDecl)
+	decl.Function.generic = nil
+	decl.Function.template = v
+	decl.Function.TypeArguments = args
+
+	// add the instance before resolving it, so recursive uses find it
+	v.generic.instances[key] = decl.Function
+	c.curSubmod.Nodes = append(c.curSubmod.Nodes, decl)
+
+	res := v.generic.resolver()
+	for idx, param := range v.TypeParameters {
+		res.curScope.InsertType(SubstitutionType{Name: param.Name, Type: args[idx]}, true)
+	}
+
+	node := Node(decl)
+	res.ResolveNode(&node)
+	NewASTVisitor(res).VisitChildren(node)
+	res.PostVisit(&node)
+
+	return decl.Function
+}
+
+// Returns the type of the generic function with the type parameters left in,
+// which the types of the arguments of a call are matched against to infer the
+// type arguments.
+func (v *Function) genericSignature() FunctionType {
+	if v.generic.signature == nil {
+		res := v.generic.resolver()
+		for _, param := range v.TypeParameters {
+			res.curScope.InsertType(SubstitutionType{Name: param.Name, Type: param}, true)
+		}
+
+		sig := res.ResolveType(v.generic.decl, v.Type).(FunctionType)
+		v.generic.signature = &sig
+	}
+
+	return *v.generic.signature
+}
+
+// ExtractTypeVariable matches the type value against the type pattern, which
+// may contain type parameters, and returns the types the parameters have in
+// value. Returns an error if the types don't match.
+func ExtractTypeVariable(pattern Type, value Type) (map[string]Type, error) {
+	res := make(map[string]Type)
+	err := extractTypeVariables(pattern, value, res)
+	return res, err
+}
+
+// Like ExtractTypeVariable, but adds to the types of the parameters in res.
+// Returns an error if a parameter already has another type.
+func extractTypeVariables(pattern Type, value Type, res map[string]Type) error {
+	if param, ok := pattern.(ParameterType); ok {
+		if typ, ok := res[param.Name]; ok && !typ.Equals(value) {
+			return fmt.Errorf("conflicting types `%s` and `%s` for type variable `%s`",
+				typ.TypeName(), value.TypeName(), param.Name)
+		}
+		res[param.Name] = value
+		return nil
+	}
+
+	mismatch := fmt.Errorf("`%s` does not match `%s`", value.TypeName(), pattern.TypeName())
+
+	switch pattern := pattern.(type) {
+	case PrimitiveType, InterfaceType:
+		if !pattern.Equals(value) {
+			return mismatch
+		}
+		return nil
+
+	case *NamedType:
+		named, ok := value.(*NamedType)
+		if !ok {
+			return mismatch
+		}
+
+		// instances of the same generic type match if their type arguments do
+		if pattern.GenericType == nil || pattern.GenericType != named.GenericType {
+			if !pattern.Equals(named) {
+				return mismatch
+			}
+			return nil
+		}
+
+		for idx, arg := range pattern.TypeArguments {
+			if err := extractTypeVariables(arg, named.TypeArguments[idx], res); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if reflect.TypeOf(pattern) != reflect.TypeOf(value) {
+		return mismatch
+	}
+
+	patternParts := AddChildren(pattern, nil)
+	valueParts := AddChildren(value, nil)
+	if len(patternParts) != len(valueParts) {
+		return mismatch
+	}
+
+	for idx, part := range patternParts {
+		if err := extractTypeVariables(part, valueParts[idx], res); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns true if the type contains type parameters
+func hasTypeVariables(typ Type) bool {
+	switch typ := typ.(type) {
+	case ParameterType:
+		return true
+
+	case *NamedType:
+		for _, arg := range typ.TypeArguments {
+			if hasTypeVariables(arg) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, part := range AddChildren(typ, nil) {
+		if hasTypeVariables(part) {
+			return true
+		}
+	}
+	return false
+}
+
+func AddChildren(typ Type, dest []Type) []Type {
+	switch typ := typ.(type) {
+	case StructType:
+		for _, decl := range typ.Variables {
+			dest = append(dest, decl.Variable.Type)
+		}
+
+	case *NamedType:
+		dest = append(dest, typ.Type)
+
+	case ArrayType:
+		dest = append(dest, typ.MemberType)
+
+	case VectorType:
+		dest = append(dest, typ.MemberType)
+
+	case PointerType:
+		dest = append(dest, typ.Addressee)
+
+	case MutableReferenceType:
+		dest = append(dest, typ.Referrer)
+
+	case ConstantReferenceType:
+		dest = append(dest, typ.Referrer)
+
+	case TupleType:
+		dest = append(dest, typ.Members...)
+
+	case EnumType:
+		for _, mem := range typ.Members {
+			dest = append(dest, mem.Type)
+		}
+
+	case FunctionType:
+		if typ.Receiver != nil {
+			dest = append(dest, typ.Receiver)
+		}
+		dest = append(dest, typ.Parameters...)
+		if typ.Return != nil { // TODO: can it ever be nil?
+			dest = append(dest, typ.Return)
+		}
+
+	}
+	return dest
+}
@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/ark-lang/ark/src/util/log"
 
@@ -78,6 +79,19 @@ func (v *Block) infer(s *TypeInferer) {
 	}
 }
 
+// Infers an instance of a generic function, unless it already is. Instances can
+// be created after the submodule of the generic function was inferred.
+func (v *TypeInferer) inferInstance(fn *Function) {
+	if fn.inferred {
+		return
+	}
+	fn.inferred = true
+
+	inf := &TypeInferer{Submodule: fn.template.generic.constructor.curSubmod}
+	fn.infer(inf)
+	v.shouldExit = v.shouldExit || inf.shouldExit
+}
+
 func (v *Function) infer(s *TypeInferer) {
 	s.pushFunction(v)
 	if v.Body != nil {
@@ -118,6 +132,7 @@ func (v *VariableDecl) infer(s *TypeInferer) {
 	if v.Assignment != nil {
 		v.Assignment.setTypeHint(v.Variable.Type)
 		v.Assignment.infer(s)
+		v.Assignment = convertImplicitly(v.Assignment, v.Variable.Type)
 
 		if v.Variable.Type == nil {
 			v.Variable.Type = v.Assignment.GetType()
@@ -137,6 +152,12 @@ func (v *UseDirective) infer(s *TypeInferer) {
 }
 
 func (v *FunctionDecl) infer(s *TypeInferer) {
+	// Generic functions are only inferred through their instances, which may
+	// already be inferred if they were created while inferring a call.
+	if v.Function.IsGeneric() || v.Function.inferred {
+		return
+	}
+	v.Function.inferred = true
 	v.Function.infer(s)
 }
 
@@ -152,6 +173,7 @@ func (v *ReturnStat) infer(s *TypeInferer) {
 	if !s.function.Type.Return.Equals(PRIMITIVE_void) {
 		v.Value.setTypeHint(s.function.Type.Return)
 		v.Value.infer(s)
+		v.Value = convertImplicitly(v.Value, s.function.Type.Return)
 	}
 }
 
@@ -185,6 +207,12 @@ func (v *CallStat) infer(s *TypeInferer) {
 	v.Call.infer(s)
 }
 
+// AsmStat
+
+func (v *AsmStat) infer(s *TypeInferer) {
+	v.Asm.infer(s)
+}
+
 // DeferStat
 
 func (v *DeferStat) infer(s *TypeInferer) {
@@ -197,6 +225,7 @@ func (v *AssignStat) infer(s *TypeInferer) {
 	v.Assignment.setTypeHint(v.Access.GetType())
 	v.Assignment.infer(s)
 	v.Access.infer(s)
+	v.Assignment = convertImplicitly(v.Assignment, v.Access.GetType())
 }
 
 // BinopAssignStat
@@ -225,11 +254,13 @@ func (v *LoopStat) infer(s *TypeInferer) {
 // MatchStat
 
 func (v *MatchStat) infer(s *TypeInferer) {
+	v.Target.setTypeHint(nil)
 	v.Target.infer(s)
 
-	for pattern, stmt := range v.Branches {
+	for i, pattern := range v.Patterns {
+		pattern.setTypeHint(v.Target.GetType())
 		pattern.infer(s)
-		stmt.infer(s)
+		v.Bodies[i].infer(s)
 	}
 }
 
@@ -290,7 +321,7 @@ func (v *BinaryExpr) infer(s *TypeInferer) {
 		v.Lhand.infer(s)
 		v.Rhand.setTypeHint(v.Lhand.GetType())
 		v.Rhand.infer(s)
-		v.Type = PRIMITIVE_bool
+		v.Type = comparisonType(v.Lhand.GetType())
 
 	case BINOP_ADD, BINOP_SUB, BINOP_MUL, BINOP_DIV, BINOP_MOD,
 		BINOP_GREATER, BINOP_LESS, BINOP_GREATER_EQ, BINOP_LESS_EQ,
@@ -300,10 +331,10 @@ func (v *BinaryExpr) infer(s *TypeInferer) {
 		v.Rhand.infer(s)
 
 		switch v.Op.Category() {
-		case OP_ARITHMETIC:
+		case OP_ARITHMETIC, OP_BITWISE:
 			v.Type = v.Lhand.GetType()
 		case OP_COMPARISON:
-			v.Type = PRIMITIVE_bool
+			v.Type = comparisonType(v.Lhand.GetType())
 		default:
 			s.err(v, "invalid operands specified `%s`", v.Op.String())
 		}
@@ -323,6 +354,17 @@ func (v *BinaryExpr) infer(s *TypeInferer) {
 	}
 }
 
+// Returns the type of comparing values of the type, which is a vector for
+// vectors that are compared element-wise
+func comparisonType(t Type) Type {
+	if t != nil {
+		if vec, ok := t.ActualType().(VectorType); ok {
+			return vec.MaskType()
+		}
+	}
+	return PRIMITIVE_bool
+}
+
 func (v *BinaryExpr) setTypeHint(t Type) {
 	switch v.Op.Category() {
 	case OP_ARITHMETIC, OP_BITWISE:
@@ -421,10 +463,20 @@ func (v *BoolLiteral) setTypeHint(t Type)   {}
 
 func (v *CompositeLiteral) infer(s *TypeInferer) {
 	if array, ok := v.Type.ActualType().(ArrayType); ok {
+		if v.Length != nil {
+			v.Length.setTypeHint(PRIMITIVE_uint)
+			v.Length.infer(s)
+		}
+
 		for _, val := range v.Values {
 			val.setTypeHint(array.MemberType)
 			val.infer(s)
 		}
+	} else if vec, ok := v.Type.ActualType().(VectorType); ok {
+		for _, val := range v.Values {
+			val.setTypeHint(vec.MemberType)
+			val.infer(s)
+		}
 	} else if struc, ok := v.Type.ActualType().(StructType); ok {
 		for i, val := range v.Values {
 			field := v.Fields[i]
@@ -437,7 +489,7 @@ func (v *CompositeLiteral) infer(s *TypeInferer) {
 			val.infer(s)
 		}
 	} else {
-		s.err(v, "Invalid composite literal, expected array or structure")
+		s.err(v, "Invalid composite literal, expected array, vector or structure")
 	}
 
 	/*var memType Type // type of each member of the array
@@ -481,11 +533,26 @@ func (v *CompositeLiteral) setTypeHint(t Type) {
 	}
 
 	switch t.ActualType().(type) {
-	case StructType, ArrayType:
+	case StructType, ArrayType, VectorType:
 		v.Type = t
 	}
 }
 
+// Wraps expr in a cast to typ if the type of expr converts to typ implicitly,
+// which are conversions to closures and to the interfaces it implements.
+func convertImplicitly(expr Expr, typ Type) Expr {
+	if expr == nil || typ == nil || expr.GetType() == nil || expr.GetType().Equals(typ) {
+		return expr
+	}
+
+	if ConvertsToClosure(expr.GetType(), typ) || canCastToInterface(expr.GetType(), typ) {
+		cast := &CastExpr{Expr: expr, Type: typ}
+		cast.setPos(expr.Pos())
+		return cast
+	}
+	return expr
+}
+
 // CastExpr
 
 func (v *CastExpr) infer(s *TypeInferer) {
@@ -495,17 +562,64 @@ func (v *CastExpr) infer(s *TypeInferer) {
 
 func (v *CastExpr) setTypeHint(t Type) {}
 
+// AsmExpr
+
+func (v *AsmExpr) infer(s *TypeInferer) {
+	for _, input := range v.Inputs {
+		// an input tied to an output gets its type
+		var hint Type
+		if idx, err := strconv.Atoi(input.Constraint); err == nil && idx < len(v.Outputs) {
+			hint = v.Outputs[idx].Type
+		}
+
+		input.Value.setTypeHint(hint)
+		input.Value.infer(s)
+	}
+}
+
+func (v *AsmExpr) setTypeHint(t Type) {}
+
 // CallExpr
 
 func (v *CallExpr) infer(s *TypeInferer) {
 
 	if sae, ok := v.Function.(*StructAccessExpr); ok {
 		sae.Struct.infer(s)
-		fn := TypeWithoutPointers(sae.Struct.GetType()).(*NamedType).GetMethod(sae.Member)
-		v.Function = &FunctionAccessExpr{Function: fn}
+		named := TypeWithoutPointers(sae.Struct.GetType()).(*NamedType)
+
+		if iface, ok := named.ActualType().(InterfaceType); ok {
+			// the method is looked up in the vtable of the interface value
+			fn, _ := iface.GetFunction(sae.Member)
+			if fn == nil {
+				s.err(v, "Interface `%s` has no function `%s`", named.TypeName(), sae.Member)
+				return
+			}
+			v.Function = &FunctionAccessExpr{Function: fn}
+
+			if ptr, ok := v.ReceiverAccess.GetType().(PointerType); ok {
+				v.ReceiverAccess = &DerefAccessExpr{
+					Type: ptr.Addressee,
+					Expr: v.ReceiverAccess,
+				}
+			}
+		} else {
+			fn := named.GetMethod(sae.Member)
+			v.Function = &FunctionAccessExpr{Function: fn}
+		}
+
 		if v.Function == nil {
 			//s.err(v, "Cannot resolve method `%s` of type `%s`", sae.Member, TypeWithoutPointers(sae.Struct.GetType()).TypeName())
 		}
+	} else if fae, ok := v.Function.(*FunctionAccessExpr); ok && fae.Function.IsGeneric() {
+		instance, err := v.inferTypeArguments(s, fae.Function)
+		if err != nil {
+			// the type of the call is unknown without the type arguments
+			s.err(v, "%s", err.Error())
+			v.Function = nil
+			return
+		}
+		fae.Function = instance
+		return
 	} else {
 		v.Function.infer(s)
 	}
@@ -552,12 +666,84 @@ func (v *CallExpr) infer(s *TypeInferer) {
 		}
 	}
 
-	for _, arg := range v.Arguments {
+	for i, arg := range v.Arguments {
 		arg.infer(s)
+
+		if v.Function != nil && i < len(v.Function.GetType().(FunctionType).Parameters) {
+			v.Arguments[i] = convertImplicitly(arg, v.Function.GetType().(FunctionType).Parameters[i])
+		}
 	}
 }
 
-func (v *CallExpr) setTypeHint(t Type) {}
+// Infers the type arguments of a call to the generic function fn from the
+// types of the arguments, or the type hint of the call, and returns the
+// instance of fn for them. Returns an error if they couldn't be inferred.
+func (v *CallExpr) inferTypeArguments(s *TypeInferer, fn *Function) (*Function, error) {
+	sig := fn.genericSignature()
+	vars := make(map[string]Type)
+
+	for i, arg := range v.Arguments {
+		if i >= len(sig.Parameters) || hasTypeVariables(sig.Parameters[i]) {
+			arg.setTypeHint(nil)
+		} else {
+			arg.setTypeHint(sig.Parameters[i])
+		}
+		arg.infer(s)
+
+		// numeric literals are only used for the type variables no other
+		// argument decides, as they can have any numeric type
+		if _, ok := arg.(*NumericLiteral); ok || i >= len(sig.Parameters) || arg.GetType() == nil {
+			continue
+		}
+
+		if err := extractTypeVariables(sig.Parameters[i], arg.GetType(), vars); err != nil {
+			return nil, fmt.Errorf("Cannot infer type arguments of `%s`: %s", fn.Name, err.Error())
+		}
+	}
+
+	// these only decide the type variables that are still unknown, any
+	// mismatch is reported when checking the types of the instance
+	weak := make(map[string]Type)
+	for i, arg := range v.Arguments {
+		if _, ok := arg.(*NumericLiteral); ok && i < len(sig.Parameters) {
+			extractTypeVariables(sig.Parameters[i], arg.GetType(), weak)
+		}
+	}
+	if v.typeHint != nil {
+		extractTypeVariables(sig.Return, v.typeHint, weak)
+	}
+
+	args := make([]Type, len(fn.TypeParameters))
+	for idx, param := range fn.TypeParameters {
+		if typ, ok := vars[param.Name]; ok {
+			args[idx] = typ
+		} else if typ, ok := weak[param.Name]; ok {
+			args[idx] = typ
+		} else {
+			return nil, fmt.Errorf("Cannot infer type variable `%s` of `%s`, specify the type arguments with `%s<...>`",
+				param.Name, fn.Name, fn.Name)
+		}
+	}
+
+	instance := fn.instantiate(args)
+	s.inferInstance(instance)
+
+	for i, arg := range v.Arguments {
+		if i >= len(instance.Type.Parameters) {
+			continue
+		}
+		if lit, ok := arg.(*NumericLiteral); ok {
+			lit.setTypeHint(instance.Type.Parameters[i])
+		}
+		v.Arguments[i] = convertImplicitly(arg, instance.Type.Parameters[i])
+	}
+
+	return instance, nil
+}
+
+func (v *CallExpr) setTypeHint(t Type) {
+	v.typeHint = t
+}
 
 // VariableAccessExpr
 func (v *VariableAccessExpr) infer(s *TypeInferer) {
@@ -567,8 +753,19 @@ func (v *VariableAccessExpr) infer(s *TypeInferer) {
 func (v *VariableAccessExpr) setTypeHint(t Type) {}
 
 // FunctionAccessExpr
-func (_ FunctionAccessExpr) infer(s *TypeInferer) {}
-func (_ FunctionAccessExpr) setTypeHint(t Type)   {}
+func (v *FunctionAccessExpr) infer(s *TypeInferer) {
+	if v.Function == nil {
+		return
+	}
+
+	if v.Function.IsGeneric() {
+		s.err(v, "Generic function `%s` requires type arguments", v.Function.Name)
+	} else if v.Function.template != nil {
+		s.inferInstance(v.Function)
+	}
+}
+
+func (_ FunctionAccessExpr) setTypeHint(t Type) {}
 
 // StructAccessExpr
 func (v *StructAccessExpr) infer(s *TypeInferer) {
@@ -752,6 +949,10 @@ func (v *EnumLiteral) infer(s *TypeInferer) {
 }
 
 func (v *EnumLiteral) setTypeHint(t Type) {
+	// a member of a generic enum takes its type parameters from the hint
+	if t != nil && IsInstanceOf(t, v.Type) {
+		v.Type = t
+	}
 }
 
 // DefaultMatchBranch
@@ -762,6 +963,99 @@ func (v *DefaultMatchBranch) infer(s *TypeInferer) {
 func (v *DefaultMatchBranch) setTypeHint(t Type) {
 }
 
+// BindingPattern
+
+func (v *BindingPattern) infer(s *TypeInferer) {
+}
+
+func (v *BindingPattern) setTypeHint(t Type) {
+	v.Variable.Type = t
+}
+
+// TuplePattern
+
+func (v *TuplePattern) infer(s *TypeInferer) {
+	for _, elem := range v.Elements {
+		elem.infer(s)
+	}
+}
+
+func (v *TuplePattern) setTypeHint(t Type) {
+	var tupleType TupleType
+	if t != nil {
+		tupleType, _ = t.ActualType().(TupleType)
+	}
+
+	if len(tupleType.Members) != len(v.Elements) {
+		for _, elem := range v.Elements {
+			elem.setTypeHint(nil)
+		}
+		return
+	}
+
+	v.Type = t
+	for idx, elem := range v.Elements {
+		elem.setTypeHint(tupleType.Members[idx])
+	}
+}
+
+// StructPattern
+
+func (v *StructPattern) infer(s *TypeInferer) {
+	for _, val := range v.Values {
+		val.infer(s)
+	}
+}
+
+func (v *StructPattern) setTypeHint(t Type) {
+	// the type of a struct pattern in an enum comes from the enum member
+	if v.InEnum && t != nil {
+		if _, ok := t.ActualType().(StructType); ok {
+			v.Type = t
+		}
+	}
+
+	var structType StructType
+	if v.Type != nil {
+		structType, _ = v.Type.ActualType().(StructType)
+	}
+
+	for idx, val := range v.Values {
+		if idx < len(structType.Variables) {
+			val.setTypeHint(structType.Variables[idx].Variable.Type)
+		} else {
+			val.setTypeHint(nil)
+		}
+	}
+}
+
+// EnumPattern
+
+func (v *EnumPattern) infer(s *TypeInferer) {
+	if v.Payload != nil {
+		v.Payload.infer(s)
+	}
+}
+
+func (v *EnumPattern) setTypeHint(t Type) {
+	// a pattern for a generic enum takes its type parameters from the hint
+	if t != nil && IsInstanceOf(t, v.Type) {
+		v.Type = t
+	}
+
+	if v.Payload == nil {
+		return
+	}
+
+	if enumType, ok := v.Type.ActualType().(EnumType); ok {
+		if member, ok := enumType.GetMember(v.Member); ok {
+			v.Payload.setTypeHint(member.Type)
+			return
+		}
+	}
+	v.Payload.setTypeHint(nil)
+}
+
 // DefaultExpr
 
 func (v *DefaultExpr) infer(s *TypeInferer) {
@@ -3,9 +3,12 @@ package parser
 const (
 	KEYWORD_ALLOC     string = "alloc"
 	KEYWORD_AS        string = "as"
+	KEYWORD_ASM       string = "asm"
 	KEYWORD_BREAK     string = "break"
 	KEYWORD_C         string = "C"
 	KEYWORD_CAST      string = "cast"
+	KEYWORD_CLOSURE   string = "closure"
+	KEYWORD_CONST     string = "const"
 	KEYWORD_DEFAULT   string = "default"
 	KEYWORD_DEFER     string = "defer"
 	KEYWORD_DO        string = "do"
@@ -32,15 +35,19 @@ const (
 	KEYWORD_TRAIT     string = "trait"
 	KEYWORD_TRUE      string = "true"
 	KEYWORD_USE       string = "use"
+	KEYWORD_VEC       string = "vec"
 	KEYWORD_VOID      string = "void"
 )
 
 var keywordList = []string{
 	KEYWORD_ALLOC,
 	KEYWORD_AS,
+	KEYWORD_ASM,
 	KEYWORD_BREAK,
 	KEYWORD_C,
 	KEYWORD_CAST,
+	KEYWORD_CLOSURE,
+	KEYWORD_CONST,
 	KEYWORD_DEFAULT,
 	KEYWORD_DEFER,
 	KEYWORD_DO,
@@ -82,7 +89,7 @@ func init() {
 	}
 }
 
-func isReservedKeyword(s string) bool {
+func IsReservedKeyword(s string) bool {
 	if m := keywordMap[s]; m {
 		return true
 	}
@@ -31,6 +31,9 @@ func TypeMangledName(mangleType MangleType, typ Type) string {
 		case ArrayType:
 			res += fmt.Sprintf("A%s", TypeMangledName(mangleType, typ.MemberType))
 
+		case VectorType:
+			res += fmt.Sprintf("V%d%s", typ.Length, TypeMangledName(mangleType, typ.MemberType))
+
 		case ConstantReferenceType:
 			res += fmt.Sprintf("RC%s", TypeMangledName(mangleType, typ.Referrer))
 
@@ -67,9 +70,21 @@ func TypeMangledName(mangleType MangleType, typ Type) string {
 				str = TypeMangledName(mangleType, typ.Receiver) + str
 			}
 
-			res += fmt.Sprintf("%dFT%s", len(str), str)
+			if typ.IsClosure {
+				res += fmt.Sprintf("%dCT%s", len(str), str)
+			} else {
+				res += fmt.Sprintf("%dFT%s", len(str), str)
+			}
+
+		case *NamedType:
+			if typ.GenericType != nil {
+				name := typ.GenericType.Name
+				return res + fmt.Sprintf("%d%s", len(name), name) + typeArgumentsMangledName(mangleType, typ.TypeArguments)
+			}
+
+			return res + fmt.Sprintf("%d%s", len(typ.Name), typ.Name)
 
-		case *NamedType, PrimitiveType:
+		case PrimitiveType:
 			name := typ.TypeName()
 			return res + fmt.Sprintf("%d%s", len(name), name)
 
@@ -92,6 +107,15 @@ func TypeMangledName(mangleType MangleType, typ Type) string {
 	}
 }
 
+// The type arguments of an instance of a generic function or type
+func typeArgumentsMangledName(mangleType MangleType, args []Type) string {
+	res := fmt.Sprintf("G%d", len(args))
+	for _, arg := range args {
+		res += TypeMangledName(mangleType, arg)
+	}
+	return res
+}
+
 func (v *Module) MangledName(typ MangleType) string {
 	switch typ {
 	case MANGLE_ARK_UNSTABLE:
@@ -123,6 +147,9 @@ func (v *Function) MangledName(typ MangleType) string {
 		}
 
 		result := fmt.Sprintf("_%sF%d%s", prefix, len(v.Name), v.Name)
+		if v.TypeArguments != nil {
+			result += typeArgumentsMangledName(typ, v.TypeArguments)
+		}
 		for _, arg := range v.Parameters {
 			result += TypeMangledName(typ, arg.Variable.Type)
 		}
@@ -159,7 +186,13 @@ func (v *Variable) MangledName(typ MangleType) string {
 func (v *NamedType) MangledName(typ MangleType) string {
 	switch typ {
 	case MANGLE_ARK_UNSTABLE:
-		result := fmt.Sprintf("_N%d%s", len(v.Name), v.Name)
+		var result string
+		if v.GenericType != nil {
+			result = fmt.Sprintf("_N%d%s", len(v.GenericType.Name), v.GenericType.Name) +
+				typeArgumentsMangledName(typ, v.TypeArguments)
+		} else {
+			result = fmt.Sprintf("_N%d%s", len(v.Name), v.Name)
+		}
 
 		result = v.ParentModule.MangledName(typ) + result
 
@@ -96,9 +96,16 @@ type FunctionTypeNode struct {
 	ParameterTypes []ParseNode
 	ReturnType     ParseNode
 	IsVariadic     bool
+	IsClosure      bool
 }
 
 type ArrayTypeNode struct {
+	baseNode
+	MemberType ParseNode
+	Length     ParseNode // nil if not given, only used by composite literals
+}
+
+type VectorTypeNode struct {
 	baseNode
 	MemberType ParseNode
 	Length     int
@@ -187,10 +194,11 @@ type EnumEntryNode struct {
 
 type VarDeclNode struct {
 	baseDecl
-	Name    LocatedString
-	Type    ParseNode
-	Value   ParseNode
-	Mutable LocatedString
+	Name     LocatedString
+	Type     ParseNode
+	Value    ParseNode
+	Mutable  LocatedString
+	Constant LocatedString
 }
 
 type TypeDeclNode struct {
@@ -251,6 +259,30 @@ type DefaultPatternNode struct {
 	baseNode
 }
 
+type BindingPatternNode struct {
+	baseNode
+	Name    LocatedString
+	Mutable LocatedString
+}
+
+type TuplePatternNode struct {
+	baseNode
+	Elements []ParseNode
+}
+
+type StructPatternNode struct {
+	baseNode
+	Name   *NameNode
+	Fields []LocatedString // has same length as Values. unnamed fields are matched by position.
+	Values []ParseNode
+}
+
+type EnumPatternNode struct {
+	baseNode
+	Name  *NameNode
+	Tuple *TuplePatternNode
+}
+
 type LoopStatNode struct {
 	baseNode
 	Condition ParseNode
@@ -278,6 +310,11 @@ type CallStatNode struct {
 	Call *CallExprNode
 }
 
+type AsmStatNode struct {
+	baseNode
+	Asm *AsmExprNode
+}
+
 type AssignStatNode struct {
 	baseNode
 	Target ParseNode
@@ -323,6 +360,20 @@ type DefaultExprNode struct {
 	Target ParseNode
 }
 
+type AsmExprNode struct {
+	baseNode
+	Template LocatedString
+	Outputs  []*AsmOperandNode
+	Inputs   []*AsmOperandNode
+	Clobbers []LocatedString
+}
+
+type AsmOperandNode struct {
+	baseNode
+	Constraint LocatedString
+	Value      ParseNode // the type of an output, the expression of an input
+}
+
 type AddrofExprNode struct {
 	baseNode
 	Mutable bool
@@ -208,13 +208,15 @@ func (v *parser) parseNode() (ParseNode, bool) {
 
 	is_cond := false
 
+	// conditional statements go before the other statements, so something
+	// like `match (a, b) {` isn't mistaken for a call
 	if decl := v.parseDecl(false); decl != nil {
 		ret = decl
-	} else if stat := v.parseStat(); stat != nil {
-		ret = stat
 	} else if cond := v.parseConditionalStat(); cond != nil {
 		ret = cond
 		is_cond = true
+	} else if stat := v.parseStat(); stat != nil {
+		ret = stat
 	} else if blockStat := v.parseBlockStat(); blockStat != nil {
 		ret = blockStat
 		is_cond = true
@@ -394,11 +396,11 @@ func (v *parser) parseFunc(lambda bool, topLevelNode bool) *FunctionNode {
 		v.consumeToken()
 
 		isCond := false
-		if stat = v.parseStat(); stat != nil {
-			end = stat.Where().End()
-		} else if stat = v.parseConditionalStat(); stat != nil {
+		if stat = v.parseConditionalStat(); stat != nil {
 			end = stat.Where().End()
 			isCond = true
+		} else if stat = v.parseStat(); stat != nil {
+			end = stat.Where().End()
 		} else if expr = v.parseExpr(); expr != nil {
 			end = expr.Where().End()
 		} else {
@@ -528,7 +530,7 @@ func (v *parser) parseTypeDecl() *TypeDeclNode {
 	startToken := v.consumeToken()
 
 	name := v.expect(lexer.TOKEN_IDENTIFIER, "")
-	if isReservedKeyword(name.Contents) {
+	if IsReservedKeyword(name.Contents) {
 		v.err("Cannot use reserved keyword `%s` as type name", name.Contents)
 	}
 
@@ -613,7 +615,7 @@ func (v *parser) parseEnumEntry() *EnumEntryNode {
 	}
 	name := v.consumeToken()
 
-	if isReservedKeyword(name.Contents) {
+	if IsReservedKeyword(name.Contents) {
 		v.err("Cannot use reserved keyword `%s` as name for enum entry", name.Contents)
 	}
 
@@ -651,6 +653,9 @@ func (v *parser) parseVarDecl(isTopLevel bool) *VarDeclNode {
 	if body == nil {
 		return nil
 	}
+	if body.Constant.Value != "" && !isTopLevel {
+		v.errPosSpecific(body.Where().Start(), "Constants must be declared at the top level")
+	}
 	if isTopLevel {
 		v.expect(lexer.TOKEN_SEPARATOR, ";")
 	}
@@ -665,9 +670,11 @@ func (v *parser) parseVarDeclBody() *VarDeclNode {
 
 	startPos := v.currentToken
 
-	var mutable *lexer.Token
+	var mutable, constant *lexer.Token
 	if v.tokenMatches(0, lexer.TOKEN_IDENTIFIER, KEYWORD_MUT) {
 		mutable = v.consumeToken()
+	} else if v.tokenMatches(0, lexer.TOKEN_IDENTIFIER, KEYWORD_CONST) {
+		constant = v.consumeToken()
 	}
 
 	if !v.tokensMatch(lexer.TOKEN_IDENTIFIER, "", lexer.TOKEN_OPERATOR, ":") {
@@ -697,6 +704,8 @@ func (v *parser) parseVarDeclBody() *VarDeclNode {
 		if value == nil {
 			v.err("Expected valid expression after `=` in variable declaration")
 		}
+	} else if constant != nil {
+		v.err("Expected `=` after constant declaration")
 	}
 
 	res := &VarDeclNode{Name: NewLocatedString(name), Type: varType}
@@ -704,6 +713,9 @@ func (v *parser) parseVarDeclBody() *VarDeclNode {
 	if mutable != nil {
 		res.Mutable = NewLocatedString(mutable)
 		start = mutable.Where.Start()
+	} else if constant != nil {
+		res.Constant = NewLocatedString(constant)
+		start = constant.Where.Start()
 	}
 
 	var end lexer.Position
@@ -752,6 +764,8 @@ func (v *parser) parseStat() ParseNode {
 		res = returnStat
 	} else if callStat := v.parseCallStat(); callStat != nil {
 		res = callStat
+	} else if asmStat := v.parseAsmStat(); asmStat != nil {
+		res = asmStat
 	} else if assignStat := v.parseAssignStat(); assignStat != nil {
 		res = assignStat
 	} else if binopAssignStat := v.parseBinopAssignStat(); binopAssignStat != nil {
@@ -872,18 +886,9 @@ func (v *parser) parseMatchStat() *MatchStatNode {
 			break
 		}
 
-		var pattern ParseNode
-		if v.tokenMatches(0, lexer.TOKEN_IDENTIFIER, "_") {
-			patTok := v.consumeToken()
-
-			pattern = &DefaultPatternNode{}
-			pattern.SetWhere(patTok.Where)
-		} else {
-			pattern = v.parseExpr()
-		}
-
+		pattern := v.parsePattern()
 		if pattern == nil {
-			v.err("Expected valid expression as pattern in match statement")
+			v.err("Expected valid pattern in match statement")
 		}
 
 		v.expect(lexer.TOKEN_OPERATOR, "=>")
@@ -912,6 +917,156 @@ func (v *parser) parseMatchStat() *MatchStatNode {
 	return res
 }
 
+func (v *parser) parsePattern() ParseNode {
+	defer un(trace(v, "pattern"))
+
+	if v.tokenMatches(0, lexer.TOKEN_IDENTIFIER, "_") {
+		patTok := v.consumeToken()
+
+		res := &DefaultPatternNode{}
+		res.SetWhere(patTok.Where)
+		return res
+	}
+
+	if tuplePattern := v.parseTuplePattern(); tuplePattern != nil {
+		// a single pattern in parentheses is just that pattern
+		if len(tuplePattern.Elements) == 1 {
+			return tuplePattern.Elements[0]
+		}
+		return tuplePattern
+	}
+
+	if bindingPattern := v.parseBindingPattern(); bindingPattern != nil {
+		return bindingPattern
+	}
+
+	if v.nextIs(lexer.TOKEN_IDENTIFIER) && !IsReservedKeyword(v.peek(0).Contents) {
+		startPos := v.currentToken
+		name := v.parseName()
+
+		if tuplePattern := v.parseTuplePattern(); tuplePattern != nil {
+			res := &EnumPatternNode{Name: name, Tuple: tuplePattern}
+			res.SetWhere(lexer.NewSpan(name.Where().Start(), tuplePattern.Where().End()))
+			return res
+		} else if v.tokenMatches(0, lexer.TOKEN_SEPARATOR, "{") {
+			return v.parseStructPattern(name)
+		}
+
+		// anything else is an expression, eg. `Enum::Member`
+		v.currentToken = startPos
+	}
+
+	return v.parseExpr()
+}
+
+func (v *parser) parseBindingPattern() *BindingPatternNode {
+	defer un(trace(v, "bindingpattern"))
+
+	startPos := v.currentToken
+
+	var mutable *lexer.Token
+	if v.tokenMatches(0, lexer.TOKEN_IDENTIFIER, KEYWORD_MUT) {
+		mutable = v.consumeToken()
+	}
+
+	// a binding is a lone identifier, anything followed by `::`, `(` or `{`
+	// is an enum member, a struct or a call
+	if !v.nextIs(lexer.TOKEN_IDENTIFIER) || IsReservedKeyword(v.peek(0).Contents) ||
+		v.tokenMatches(1, lexer.TOKEN_OPERATOR, "::") ||
+		v.tokenMatches(1, lexer.TOKEN_SEPARATOR, "(") ||
+		v.tokenMatches(1, lexer.TOKEN_SEPARATOR, "{") {
+		v.currentToken = startPos
+		return nil
+	}
+
+	name := v.consumeToken()
+
+	res := &BindingPatternNode{Name: NewLocatedString(name)}
+	if mutable != nil {
+		res.Mutable = NewLocatedString(mutable)
+		res.SetWhere(lexer.NewSpanFromTokens(mutable, name))
+	} else {
+		res.SetWhere(name.Where)
+	}
+	return res
+}
+
+func (v *parser) parseTuplePattern() *TuplePatternNode {
+	defer un(trace(v, "tuplepattern"))
+
+	if !v.tokenMatches(0, lexer.TOKEN_SEPARATOR, "(") {
+		return nil
+	}
+	startToken := v.consumeToken()
+
+	var elements []ParseNode
+	for {
+		if v.tokenMatches(0, lexer.TOKEN_SEPARATOR, ")") {
+			break
+		}
+
+		element := v.parsePattern()
+		if element == nil {
+			v.err("Expected valid pattern in tuple pattern")
+		}
+		elements = append(elements, element)
+
+		if !v.tokenMatches(0, lexer.TOKEN_SEPARATOR, ",") {
+			break
+		}
+		v.consumeToken()
+	}
+
+	endToken := v.expect(lexer.TOKEN_SEPARATOR, ")")
+
+	res := &TuplePatternNode{Elements: elements}
+	res.SetWhere(lexer.NewSpanFromTokens(startToken, endToken))
+	return res
+}
+
+func (v *parser) parseStructPattern(name *NameNode) *StructPatternNode {
+	defer un(trace(v, "structpattern"))
+
+	v.expect(lexer.TOKEN_SEPARATOR, "{")
+
+	res := &StructPatternNode{Name: name}
+
+	var lastToken *lexer.Token
+	for {
+		if v.tokenMatches(0, lexer.TOKEN_SEPARATOR, "}") {
+			lastToken = v.consumeToken()
+			break
+		}
+
+		var field LocatedString
+		if v.tokensMatch(lexer.TOKEN_IDENTIFIER, "", lexer.TOKEN_OPERATOR, ":") {
+			field = NewLocatedString(v.consumeToken())
+			v.consumeToken()
+		}
+
+		val := v.parsePattern()
+		if val == nil {
+			v.err("Expected pattern in struct pattern, found `%s`", v.peek(0).Contents)
+		}
+
+		res.Fields = append(res.Fields, field)
+		res.Values = append(res.Values, val)
+
+		if v.tokenMatches(0, lexer.TOKEN_SEPARATOR, ",") {
+			v.consumeToken()
+			continue
+		} else if v.tokenMatches(0, lexer.TOKEN_SEPARATOR, "}") {
+			lastToken = v.consumeToken()
+			break
+		} else {
+			v.err("Unexpected `%s`", v.peek(0).Contents)
+		}
+	}
+
+	res.SetWhere(lexer.NewSpan(name.Where().Start(), lastToken.Where.End()))
+	return res
+}
+
 func (v *parser) parseLoopStat() *LoopStatNode {
 	defer un(trace(v, "loopstat"))
 
@@ -1048,6 +1203,25 @@ func (v *parser) parseCallStat() *CallStatNode {
 	return res
 }
 
+func (v *parser) parseAsmStat() *AsmStatNode {
+	defer un(trace(v, "asmstat"))
+
+	if !v.tokenMatches(0, lexer.TOKEN_IDENTIFIER, KEYWORD_ASM) {
+		return nil
+	}
+	startPos := v.currentToken
+
+	asmExpr, ok := v.parseExpr().(*AsmExprNode)
+	if !ok {
+		v.currentToken = startPos
+		return nil
+	}
+
+	res := &AsmStatNode{Asm: asmExpr}
+	res.SetWhere(lexer.NewSpan(asmExpr.Where().Start(), asmExpr.Where().End()))
+	return res
+}
+
 func (v *parser) parseAssignStat() ParseNode {
 	defer un(trace(v, "assignstat"))
 
@@ -1128,13 +1302,14 @@ func (v *parser) parseType(doRefs bool, onlyComposites bool) ParseNode {
 		}
 	}()
 
-	// If the next token is a [ and identifier it must be a group of attributes
-	if v.tokenMatches(0, lexer.TOKEN_SEPARATOR, "[") && v.tokenMatches(1, lexer.TOKEN_IDENTIFIER, "") {
+	// If the next token is a [ and identifier it must be a group of attributes,
+	// except in composite literals, where it is the length of an array
+	if !onlyComposites && v.tokenMatches(0, lexer.TOKEN_SEPARATOR, "[") && v.tokenMatches(1, lexer.TOKEN_IDENTIFIER, "") {
 		attrs = v.parseAttributes()
 	}
 
 	if !onlyComposites {
-		if v.tokenMatches(0, lexer.TOKEN_IDENTIFIER, KEYWORD_FUNC) {
+		if v.tokenMatches(0, lexer.TOKEN_IDENTIFIER, KEYWORD_FUNC) || v.tokenMatches(0, lexer.TOKEN_IDENTIFIER, KEYWORD_CLOSURE) {
 			res = v.parseFunctionType()
 		} else if v.tokenMatches(0, lexer.TOKEN_OPERATOR, "^") {
 			res = v.parsePointerType()
@@ -1157,6 +1332,8 @@ func (v *parser) parseType(doRefs bool, onlyComposites bool) ParseNode {
 		res = v.parseStructType(true)
 	} else if v.tokenMatches(0, lexer.TOKEN_IDENTIFIER, KEYWORD_ENUM) {
 		res = v.parseEnumType()
+	} else if vectorType := v.parseVectorType(); vectorType != nil {
+		res = vectorType
 	} else if doRefs && v.nextIs(lexer.TOKEN_IDENTIFIER) {
 		res = v.parseTypeReference()
 	}
@@ -1308,13 +1485,13 @@ func (v *parser) parseReferenceType() *ReferenceTypeNode {
 func (v *parser) parseFunctionType() *FunctionTypeNode {
 	defer un(trace(v, "functiontype"))
 
-	if !v.tokenMatches(0, lexer.TOKEN_IDENTIFIER, KEYWORD_FUNC) {
+	if !v.tokenMatches(0, lexer.TOKEN_IDENTIFIER, KEYWORD_FUNC) && !v.tokenMatches(0, lexer.TOKEN_IDENTIFIER, KEYWORD_CLOSURE) {
 		return nil
 	}
 	startToken := v.consumeToken()
 
 	if !v.tokenMatches(0, lexer.TOKEN_SEPARATOR, "(") {
-		v.err("Expected `(` after `func` keyword")
+		v.err("Expected `(` after `%s` keyword", startToken.Contents)
 	}
 	lastParens := v.consumeToken()
 
@@ -1377,6 +1554,7 @@ func (v *parser) parseFunctionType() *FunctionTypeNode {
 	res := &FunctionTypeNode{
 		ParameterTypes: pars,
 		ReturnType:     returnType,
+		IsClosure:      startToken.Contents == KEYWORD_CLOSURE,
 	}
 	res.SetWhere(lexer.NewSpan(startToken.Where.Start(), end))
 
@@ -1439,9 +1617,12 @@ func (v *parser) parseArrayType() *ArrayTypeNode {
 	}
 	startToken := v.consumeToken()
 
-	length := v.parseNumberLit()
-	if length != nil && length.IsFloat {
-		v.err("Expected integer length for array type")
+	var length ParseNode
+	if !v.tokenMatches(0, lexer.TOKEN_SEPARATOR, "]") {
+		length = v.parseExpr()
+		if length == nil {
+			v.err("Expected valid length for array type")
+		}
 	}
 
 	v.expect(lexer.TOKEN_SEPARATOR, "]")
@@ -1451,15 +1632,42 @@ func (v *parser) parseArrayType() *ArrayTypeNode {
 		v.err("Expected valid type in array type")
 	}
 
-	res := &ArrayTypeNode{MemberType: memberType}
-	if length != nil {
-		// TODO: Defend against overflow
-		res.Length = int(length.IntValue.Int64())
-	}
+	res := &ArrayTypeNode{MemberType: memberType, Length: length}
 	res.SetWhere(lexer.NewSpan(startToken.Where.Start(), memberType.Where().End()))
 	return res
 }
 
+func (v *parser) parseVectorType() *VectorTypeNode {
+	defer un(trace(v, "vectortype"))
+
+	// vec isn't reserved, anything other than `vec<type, length>` refers to
+	// a type named vec
+	if !v.tokensMatch(lexer.TOKEN_IDENTIFIER, KEYWORD_VEC, lexer.TOKEN_OPERATOR, "<") {
+		return nil
+	}
+	startPos := v.currentToken
+	startToken := v.consumeToken()
+	v.consumeToken()
+
+	memberType := v.parseType(true, false)
+	if memberType == nil || !v.tokensMatch(lexer.TOKEN_SEPARATOR, ",", lexer.TOKEN_NUMBER, "") {
+		v.currentToken = startPos
+		return nil
+	}
+	v.consumeToken()
+
+	length := v.parseNumberLit()
+	if length == nil || length.IsFloat || length.IntValue.Sign() <= 0 || length.IntValue.BitLen() > 31 {
+		v.err("Expected positive integer as length of vector type")
+	}
+
+	endToken := v.expect(lexer.TOKEN_OPERATOR, ">")
+
+	res := &VectorTypeNode{MemberType: memberType, Length: int(length.IntValue.Int64())}
+	res.SetWhere(lexer.NewSpanFromTokens(startToken, endToken))
+	return res
+}
+
 func (v *parser) parseTypeReference() *TypeReferenceNode {
 	defer un(trace(v, "typereference"))
 
@@ -1612,8 +1820,8 @@ func (v *parser) parsePostfixExpr() ParseNode {
 			res := &ArrayAccessNode{Array: expr, Index: index}
 			res.SetWhere(lexer.NewSpan(expr.Where().Start(), endToken.Where.End()))
 			expr = res
-		} else if v.tokenMatches(0, lexer.TOKEN_OPERATOR, "|") {
-			// tuple index
+		} else if v.tokensMatch(lexer.TOKEN_OPERATOR, "|", lexer.TOKEN_NUMBER, "", lexer.TOKEN_OPERATOR, "|") {
+			// tuple index, anything else is a bitwise or
 			v.consumeToken()
 			defer un(trace(v, "tupleindex"))
 
@@ -1638,7 +1846,12 @@ func (v *parser) parsePostfixExpr() ParseNode {
 					break
 				}
 
-				arg := v.parseExpr()
+				// there is no block after the arguments, so they can be
+				// composite literals
+				arg := v.parseCompositeLiteral()
+				if arg == nil {
+					arg = v.parseExpr()
+				}
 				if arg == nil {
 					v.err("Expected valid expression as call argument")
 				}
@@ -1674,6 +1887,8 @@ func (v *parser) parsePrimaryExpr() ParseNode {
 		res = arrayLenExpr
 	} else if defaultExpr := v.parseDefaultExpr(); defaultExpr != nil {
 		res = defaultExpr
+	} else if asmExpr := v.parseAsmExpr(); asmExpr != nil {
+		res = asmExpr
 	} else if addrofExpr := v.parseAddrofExpr(); addrofExpr != nil {
 		res = addrofExpr
 	} else if litExpr := v.parseLitExpr(); litExpr != nil {
@@ -1795,6 +2010,99 @@ func (v *parser) parseDefaultExpr() *DefaultExprNode {
 	return res
 }
 
+func (v *parser) parseAsmExpr() *AsmExprNode {
+	defer un(trace(v, "asmexpr"))
+
+	if !v.tokenMatches(0, lexer.TOKEN_IDENTIFIER, KEYWORD_ASM) {
+		return nil
+	}
+	startToken := v.consumeToken()
+
+	v.expect(lexer.TOKEN_SEPARATOR, "(")
+
+	templateToken := v.expect(lexer.TOKEN_STRING, "")
+	template, err := UnescapeString(templateToken.Contents)
+	if err != nil {
+		v.errTokenSpecific(templateToken, "Invalid string literal: %s", err)
+	}
+
+	res := &AsmExprNode{Template: LocatedString{Where: templateToken.Where, Value: template}}
+
+	// the outputs, inputs and clobbers follow the template in this order, each
+	// after a colon. Like in C, `::` skips an empty section.
+	section := 0
+	for {
+		if v.tokenMatches(0, lexer.TOKEN_OPERATOR, ":") {
+			section++
+		} else if v.tokenMatches(0, lexer.TOKEN_OPERATOR, "::") {
+			section += 2
+		} else {
+			break
+		}
+
+		separator := v.consumeToken()
+		switch section {
+		case 1:
+			res.Outputs = v.parseAsmOperands(true)
+		case 2:
+			res.Inputs = v.parseAsmOperands(false)
+		case 3:
+			for v.tokenMatches(0, lexer.TOKEN_STRING, "") {
+				res.Clobbers = append(res.Clobbers, NewLocatedString(v.consumeToken()))
+
+				if !v.tokenMatches(0, lexer.TOKEN_SEPARATOR, ",") {
+					break
+				}
+				v.consumeToken()
+			}
+		default:
+			v.errTokenSpecific(separator, "Expected at most outputs, inputs and clobbers in asm expression")
+		}
+	}
+
+	endToken := v.expect(lexer.TOKEN_SEPARATOR, ")")
+
+	res.SetWhere(lexer.NewSpanFromTokens(startToken, endToken))
+	return res
+}
+
+// Parses the operands of an asm expression, which are a constraint followed by
+// the type of the operand for outputs, or its value for inputs
+func (v *parser) parseAsmOperands(outputs bool) []*AsmOperandNode {
+	var res []*AsmOperandNode
+
+	for v.tokenMatches(0, lexer.TOKEN_STRING, "") {
+		constraint := v.consumeToken()
+		v.expect(lexer.TOKEN_SEPARATOR, "(")
+
+		var value ParseNode
+		if outputs {
+			value = v.parseType(true, false)
+			if value == nil {
+				v.err("Expected valid type for asm output")
+			}
+		} else {
+			value = v.parseExpr()
+			if value == nil {
+				v.err("Expected valid expression for asm input")
+			}
+		}
+
+		endToken := v.expect(lexer.TOKEN_SEPARATOR, ")")
+
+		operand := &AsmOperandNode{Constraint: NewLocatedString(constraint), Value: value}
+		operand.SetWhere(lexer.NewSpanFromTokens(constraint, endToken))
+		res = append(res, operand)
+
+		if !v.tokenMatches(0, lexer.TOKEN_SEPARATOR, ",") {
+			break
+		}
+		v.consumeToken()
+	}
+
+	return res
+}
+
 func (v *parser) parseAddrofExpr() *AddrofExprNode {
 	defer un(trace(v, "addrofexpr"))
 
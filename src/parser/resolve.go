@@ -189,15 +189,36 @@ func (v *Resolver) getIdent(loc Locatable, name UnresolvedName) *Ident {
 		v.err(loc, "Cannot access private identifier `%s`", name)
 	}
 
-	// make sure lambda can't access variables of enclosing function
+	// lambdas capture the variables of enclosing functions they access
 	if ident.Scope.Function != nil && v.currentFunction() != ident.Scope.Function {
-		v.err(loc, "Cannot access local identifier `%s` from lambda", name)
+		if ident.Type != IDENT_VARIABLE {
+			v.err(loc, "Cannot access local identifier `%s` from lambda", name)
+		}
+		v.captureVariable(ident.Value.(*Variable), ident.Scope.Function)
 	}
 
 	return ident
 }
 
+// Adds the variable to the captures of all lambdas between the function that
+// declares it and the current function, so nested lambdas can pass it on.
+// Mutable variables are boxed, as lambdas share them with their function.
+func (v *Resolver) captureVariable(vari *Variable, owner *Function) {
+	if vari.Mutable {
+		vari.Boxed = true
+	}
+	for idx := len(v.functionStack) - 1; idx >= 0 && v.functionStack[idx] != owner; idx-- {
+		v.functionStack[idx].addCapture(vari)
+	}
+}
+
 func (v *Resolver) Visit(n *Node) bool {
+	// Generic functions are only resolved through their instances, which are
+	// resolved as soon as they are created.
+	if decl, ok := (*n).(*FunctionDecl); ok && (decl.Function.IsGeneric() || decl.Function.TypeArguments != nil) {
+		return false
+	}
+
 	v.ResolveNode(n)
 	return true
 }
@@ -306,6 +327,11 @@ func (v *Resolver) ResolveNode(node *Node) {
 	case *DefaultExpr:
 		n.Type = v.ResolveType(n, n.Type)
 
+	case *AsmExpr:
+		for _, output := range n.Outputs {
+			output.Type = v.ResolveType(output, output.Type)
+		}
+
 	case *VariableAccessExpr:
 		// TODO: Check if we can clean this up
 		// NOTE: Here we check whether this is actually a variable access or an enum member.
@@ -339,8 +365,19 @@ func (v *Resolver) ResolveNode(node *Node) {
 		if ident == nil {
 			// do nothing
 		} else if ident.Type == IDENT_FUNCTION {
+			// Without type arguments, the instance of a generic function is
+			// chosen when inferring the type arguments of the call.
+			fn := ident.Value.(*Function)
+			if len(n.parameters) > 0 {
+				args := make([]Type, len(n.parameters))
+				for idx, param := range n.parameters {
+					args[idx] = v.ResolveType(n, param)
+				}
+				fn = v.instantiateFunction(n, fn, args)
+			}
+
 			*node = &FunctionAccessExpr{
-				Function:   ident.Value.(*Function),
+				Function:   fn,
 				parameters: n.parameters,
 			}
 			(*node).setPos(n.Pos())
@@ -466,13 +503,71 @@ func (v *Resolver) ResolveNode(node *Node) {
 			}
 		}
 
+	case *BindingPattern:
+		// a lone name of a constant matches its value instead of binding it
+		if !n.Variable.Mutable {
+			ident := v.curScope.GetIdent(UnresolvedName{Name: n.Variable.Name})
+			if ident != nil && ident.Type == IDENT_VARIABLE && ident.Value.(*Variable).IsConstant {
+				access := &VariableAccessExpr{Name: UnresolvedName{Name: n.Variable.Name}}
+				access.Variable = ident.Value.(*Variable)
+				access.setPos(n.Pos())
+
+				*node = access
+				break
+			}
+		}
+
+		if v.curScope.InsertVariable(n.Variable, false) != nil {
+			v.err(n, "Illegal redeclaration of variable `%s`", n.Variable.Name)
+		}
+
+	case *StructPattern:
+		if n.InEnum {
+			break
+		}
+
+		// NOTE: Here we check if we are matching an actual struct,
+		// or the struct part of an enum type
+		if name, ok := n.Type.(UnresolvedType); ok {
+			enumName, memberName := name.Name.Split()
+			if memberName != "" {
+				ident := v.getIdent(n, enumName)
+				if ident != nil && ident.Type == IDENT_TYPE {
+					itype := ident.Value.(Type)
+					if _, ok := itype.ActualType().(EnumType); ok {
+						enum := &EnumPattern{}
+						enum.Member = memberName
+						enum.Type = itype
+						enum.Payload = n
+						n.InEnum = true
+						enum.setPos(n.Pos())
+						v.resolveEnumPattern(enum)
+
+						*node = enum
+						break
+					}
+				}
+			}
+		}
+
+		n.Type = v.ResolveType(n, n.Type)
+		if structType, ok := n.Type.ActualType().(StructType); ok {
+			v.resolveStructPatternFields(n, structType)
+		} else {
+			v.err(n, "Expected struct type in struct pattern, found `%s`", n.Type.TypeName())
+		}
+
+	case *EnumPattern:
+		n.Type = v.ResolveType(n, n.Type)
+		v.resolveEnumPattern(n)
+
 	// No-Ops
 	case *Block, *DefaultMatchBranch, *UseDirective, *AssignStat, *BinopAssignStat,
-		*BlockStat, *BreakStat, *CallStat, *DefaultStat, *DeferStat, *IfStat,
+		*AsmStat, *BlockStat, *BreakStat, *CallStat, *DefaultStat, *DeferStat, *IfStat,
 		*MatchStat, *LoopStat, *NextStat, *ReturnStat, *AddressOfExpr,
 		*ArrayAccessExpr, *BinaryExpr, *DerefAccessExpr, *UnaryExpr,
 		*StructAccessExpr, *TupleAccessExpr, *BoolLiteral,
-		*NumericLiteral, *RuneLiteral, *StringLiteral, *TupleLiteral:
+		*NumericLiteral, *RuneLiteral, *StringLiteral, *TupleLiteral, *TuplePattern:
 		break
 
 	default:
@@ -480,14 +575,109 @@ func (v *Resolver) ResolveNode(node *Node) {
 	}
 }
 
+func (v *Resolver) resolveEnumPattern(n *EnumPattern) {
+	enumType, ok := n.Type.ActualType().(EnumType)
+	if !ok {
+		v.err(n, "Expected enum type in enum pattern, found `%s`", n.Type.TypeName())
+		return
+	}
+
+	member, ok := enumType.GetMember(n.Member)
+	if !ok {
+		v.err(n, "No such member in enum `%s`: `%s`", n.Type.TypeName(), n.Member)
+		return
+	}
+
+	switch payload := n.Payload.(type) {
+	case *TuplePattern:
+		tupleType, ok := member.Type.(TupleType)
+		if !ok {
+			v.err(n, "Enum member `%s` of `%s` does not hold a tuple", n.Member, n.Type.TypeName())
+		} else if len(payload.Elements) != len(tupleType.Members) {
+			v.err(payload, "Expected %d elements in pattern for `%s`, found %d",
+				len(tupleType.Members), n.Member, len(payload.Elements))
+		}
+
+	case *StructPattern:
+		structType, ok := member.Type.(StructType)
+		if !ok {
+			v.err(n, "Enum member `%s` of `%s` does not hold a struct", n.Member, n.Type.TypeName())
+		} else {
+			v.resolveStructPatternFields(payload, structType)
+		}
+	}
+}
+
+// Orders the fields of a struct pattern like the variables of the struct,
+// fields missing from the pattern match anything.
+func (v *Resolver) resolveStructPatternFields(n *StructPattern, typ StructType) {
+	values := make([]Expr, len(typ.Variables))
+	fields := make([]string, len(typ.Variables))
+
+	for i, decl := range typ.Variables {
+		fields[i] = decl.Variable.Name
+	}
+
+	for i, value := range n.Values {
+		idx := i
+		if n.Fields[i] != "" {
+			idx = -1
+			for j, field := range fields {
+				if field == n.Fields[i] {
+					idx = j
+					break
+				}
+			}
+
+			if idx < 0 {
+				v.err(value, "No such field in struct: `%s`", n.Fields[i])
+				return
+			}
+		} else if idx >= len(values) {
+			v.err(value, "Too many fields in struct pattern")
+			return
+		}
+
+		if values[idx] != nil {
+			v.err(value, "Duplicate field `%s` in struct pattern", fields[idx])
+			return
+		}
+		values[idx] = value
+	}
+
+	for i, value := range values {
+		if value == nil {
+			wildcard := &DefaultMatchBranch{}
+			wildcard.setPos(n.Pos())
+			values[i] = wildcard
+		}
+	}
+
+	n.Fields, n.Values = fields, values
+}
+
 func (v *Resolver) ResolveType(src Locatable, t Type) Type {
 	switch t := t.(type) {
-	case PrimitiveType, *NamedType, InterfaceType:
+	case PrimitiveType, *NamedType:
+		return t
+
+	case InterfaceType:
+		for _, fn := range t.Functions {
+			fn.Type = v.ResolveType(src, fn.Type).(FunctionType)
+			for idx, par := range fn.Parameters {
+				par.Variable.Type = fn.Type.Parameters[idx]
+			}
+		}
 		return t
 
 	case ArrayType:
 		return ArrayOf(v.ResolveType(src, t.MemberType))
 
+	case VectorType:
+		// the member type is checked in semantic analysis, as named types
+		// declared further down aren't resolved yet
+		return VectorType{MemberType: v.ResolveType(src, t.MemberType), Length: t.Length}
+
 	case MutableReferenceType:
 		return mutableReferenceTo(v.ResolveType(src, t.Referrer))
 
@@ -561,6 +751,7 @@ func (v *Resolver) ResolveType(src Locatable, t Type) Type {
 		nv := FunctionType{
 			attrs:      t.attrs,
 			IsVariadic: t.IsVariadic,
+			IsClosure:  t.IsClosure,
 		}
 
 		for _, par := range t.Parameters {
@@ -587,16 +778,26 @@ func (v *Resolver) ResolveType(src Locatable, t Type) Type {
 
 			// TODO what is this stuff?
 			if namedType, ok := typ.(*NamedType); ok && len(t.Parameters) > 0 {
+				if len(t.Parameters) != len(namedType.Parameters) {
+					v.err(src, "Type `%s` takes %d type arguments, have %d",
+						namedType.Name, len(namedType.Parameters), len(t.Parameters))
+				}
+
+				args := make([]Type, len(t.Parameters))
+				for idx, param := range t.Parameters {
+					args[idx] = v.ResolveType(src, param)
+				}
+
 				v.EnterScope()
 				name := namedType.Name + "<"
 				for idx, param := range namedType.Parameters {
 					paramType := SubstitutionType{
 						Name: param.Name,
-						Type: v.ResolveType(src, t.Parameters[idx]),
+						Type: args[idx],
 					}
 					v.curScope.InsertType(paramType, ident.Public)
 
-					name += t.Parameters[idx].TypeName()
+					name += args[idx].TypeName()
 					if idx < len(namedType.Parameters)-1 {
 						name += ", "
 					}
@@ -604,10 +805,12 @@ func (v *Resolver) ResolveType(src Locatable, t Type) Type {
 				name += ">"
 
 				typ = &NamedType{
-					Name:         name,
-					Type:         v.ResolveType(src, namedType.Type),
-					ParentModule: namedType.ParentModule,
-					Methods:      namedType.Methods,
+					Name:          name,
+					Type:          v.ResolveType(src, namedType.Type),
+					ParentModule:  namedType.ParentModule,
+					Methods:       namedType.Methods,
+					GenericType:   namedType,
+					TypeArguments: args,
 				}
 				v.ExitScope()
 			} else {
@@ -624,90 +827,3 @@ func (v *Resolver) ResolveType(src Locatable, t Type) Type {
 		panic("INTERNAL ERROR: Unhandled type in resolve pass: " + typeName)
 	}
 }
-
-//
-// The following is preliminary work used for generics and the future redo of
-// the type inference system.
-//
-func ExtractTypeVariable(pattern Type, value Type) map[string]Type {
-	/*
-		Pointer($T), Pointer(int) -> {$T: int}
-		Arbitrary depth type => Stack containing breadth first traversal
-	*/
-	res := make(map[string]Type)
-
-	var (
-		ps []Type
-		vs []Type
-	)
-	ps = append(ps, pattern)
-	vs = append(vs, value)
-
-	for i := 0; i < len(ps); i++ {
-		ppart := ps[i]
-		vpart := vs[i]
-		log.Debugln("resovle", "\nP = `%s`, V = `%s`", ppart.TypeName(), vpart.TypeName())
-
-		ps = AddChildren(ppart, ps)
-		vs = AddChildren(vpart, vs)
-
-		if vari, ok := ppart.(ParameterType); ok {
-			log.Debugln("resolve", "P was variable (Name: %s)", vari.Name)
-			res[vari.Name] = vpart
-			continue
-		}
-
-		switch ppart.(type) {
-		case PrimitiveType, *NamedType:
-			if !ppart.Equals(vpart) {
-				log.Errorln("resolve", "%s != %s", ppart.TypeName(), vpart.TypeName())
-				panic("Part of type did not match pattern")
-			}
-
-		default:
-			if reflect.TypeOf(ppart) != reflect.TypeOf(vpart) {
-				log.Errorln("resolve", "%T != %T", ppart, vpart)
-				panic("Part of type did not match pattern")
-			}
-		}
-	}
-
-	return res
-}
-
-func AddChildren(typ Type, dest []Type) []Type {
-	switch typ := typ.(type) {
-	case StructType:
-		for _, decl := range typ.Variables {
-			dest = append(dest, decl.Variable.Type)
-		}
-
-	case *NamedType:
-		dest = append(dest, typ.Type)
-
-	case ArrayType:
-		dest = append(dest, typ.MemberType)
-
-	case PointerType:
-		dest = append(dest, typ.Addressee)
-
-	case TupleType:
-		dest = append(dest, typ.Members...)
-
-	case EnumType:
-		for _, mem := range typ.Members {
-			dest = append(dest, mem.Type)
-		}
-
-	case FunctionType:
-		if typ.Receiver != nil {
-			dest = append(dest, typ.Receiver)
-		}
-		dest = append(dest, typ.Parameters...)
-		if typ.Return != nil { // TODO: can it ever be nil?
-			dest = append(dest, typ.Return)
-		}
-
-	}
-	return dest
-}
@@ -1,6 +1,10 @@
 package parser
 
-import "github.com/ark-lang/ark/src/util"
+import (
+	"strconv"
+
+	"github.com/ark-lang/ark/src/util"
+)
 
 type Type interface {
 	TypeName() string
@@ -232,6 +236,9 @@ type NamedType struct {
 	Parameters   []ParameterType
 	ParentModule *Module
 	Methods      []*Function
+
+	GenericType   *NamedType // non-nil if an instance of a generic type
+	TypeArguments []Type
 }
 
 func (v *NamedType) addMethod(fn *Function) {
@@ -248,6 +255,28 @@ func (v *NamedType) GetMethod(name string) *Function {
 	return nil
 }
 
+// ImplementingMethod returns the method of the type that implements the
+// interface function fn, or nil if there is none.
+func (v *NamedType) ImplementingMethod(fn *Function) *Function {
+	method := v.GetMethod(fn.Name)
+	if method == nil || method.Type.Receiver == nil {
+		return nil
+	}
+
+	mt, ft := method.Type, fn.Type
+	if mt.IsVariadic != ft.IsVariadic || len(mt.Parameters) != len(ft.Parameters) || !mt.Return.Equals(ft.Return) {
+		return nil
+	}
+
+	for idx, par := range mt.Parameters {
+		if !par.Equals(ft.Parameters[idx]) {
+			return nil
+		}
+	}
+
+	return method
+}
+
 func (v *NamedType) ActualType() Type {
 	return v.Type.ActualType()
 }
@@ -291,13 +320,29 @@ func (v *NamedType) IsFloatingType() bool {
 }
 
 func (v *NamedType) CanCastTo(t Type) bool {
-	return v.ActualType().CanCastTo(t)
+	return v.ActualType().CanCastTo(t) || canCastToInterface(v, t)
 }
 
 func (v *NamedType) Attrs() AttrGroup {
 	return v.Type.Attrs()
 }
 
+// IsInstanceOf returns true if t is the type generic, or generic with its
+// type parameters substituted.
+func IsInstanceOf(t Type, generic Type) bool {
+	if t.Equals(generic) {
+		return true
+	}
+
+	named, ok := t.(*NamedType)
+	genericNamed, isNamed := generic.(*NamedType)
+	if !ok || !isNamed || len(genericNamed.Parameters) == 0 {
+		return false
+	}
+
+	return named.GenericType == genericNamed
+}
+
 func (v *NamedType) Equals(t Type) bool {
 	other, ok := t.(*NamedType)
 	if !ok {
@@ -390,6 +435,94 @@ func (v ArrayType) ActualType() Type {
 	return v
 }
 
+// VectorType
+
+// A SIMD vector of a fixed number of integers or floating point numbers.
+// Arithmetic, bitwise and comparison operators work on vectors element-wise.
+type VectorType struct {
+	MemberType Type
+	Length     int
+}
+
+func (v VectorType) String() string {
+	return "(" + util.Blue("VectorType") + ": " + v.TypeName() + ")"
+}
+
+func (v VectorType) TypeName() string {
+	return "vec<" + v.MemberType.TypeName() + ", " + strconv.Itoa(v.Length) + ">"
+}
+
+func (v VectorType) IsSigned() bool {
+	return false
+}
+
+func (v VectorType) LevelsOfIndirection() int {
+	return 0
+}
+
+func (v VectorType) IsVoidType() bool {
+	return false
+}
+
+func (v VectorType) IsIntegerType() bool {
+	return false
+}
+
+func (v VectorType) IsFloatingType() bool {
+	return false
+}
+
+func (v VectorType) CanCastTo(t Type) bool {
+	return t.ActualType().Equals(v)
+}
+
+func (v VectorType) Attrs() AttrGroup {
+	return nil
+}
+
+func (v VectorType) Equals(t Type) bool {
+	other, ok := t.(VectorType)
+	if !ok {
+		return false
+	}
+
+	return v.Length == other.Length && v.MemberType.Equals(other.MemberType)
+}
+
+func (v VectorType) ActualType() Type {
+	return v
+}
+
+// The type of comparing two vectors of this type. Like in C, each element of
+// the result is a signed integer of the size of the members, which is -1 if
+// the comparison is true for the members and 0 otherwise.
+func (v VectorType) MaskType() VectorType {
+	var member Type
+	switch v.MemberType.ActualType() {
+	case PRIMITIVE_s8, PRIMITIVE_u8:
+		member = PRIMITIVE_s8
+	case PRIMITIVE_s16, PRIMITIVE_u16:
+		member = PRIMITIVE_s16
+	case PRIMITIVE_s32, PRIMITIVE_u32, PRIMITIVE_f32:
+		member = PRIMITIVE_s32
+	case PRIMITIVE_s64, PRIMITIVE_u64, PRIMITIVE_f64:
+		member = PRIMITIVE_s64
+	case PRIMITIVE_s128, PRIMITIVE_u128, PRIMITIVE_f128:
+		member = PRIMITIVE_s128
+	default:
+		member = PRIMITIVE_int
+	}
+	return VectorType{MemberType: member, Length: v.Length}
+}
+
+// Returns the type of the elements of an array or vector type
+func elementType(t Type) Type {
+	if vec, ok := t.ActualType().(VectorType); ok {
+		return vec.MemberType
+	}
+	return t.ActualType().(ArrayType).MemberType
+}
+
 // Constant Reference
 
 type ConstantReferenceType struct {
@@ -421,7 +554,7 @@ func (v ConstantReferenceType) IsVoidType() bool {
 }
 
 func (v ConstantReferenceType) CanCastTo(t Type) bool {
-	return t.IsIntegerType()
+	return t.IsIntegerType() || canCastToInterface(v, t)
 }
 
 func (v ConstantReferenceType) Attrs() AttrGroup {
@@ -480,7 +613,7 @@ func (v MutableReferenceType) IsVoidType() bool {
 }
 
 func (v MutableReferenceType) CanCastTo(t Type) bool {
-	return t.IsIntegerType()
+	return t.IsIntegerType() || canCastToInterface(v, t)
 }
 
 func (v MutableReferenceType) Attrs() AttrGroup {
@@ -546,7 +679,7 @@ func (v PointerType) CanCastTo(t Type) bool {
 	if t.IsIntegerType() {
 		return true
 	}
-	return false
+	return canCastToInterface(v, t)
 }
 
 func (v PointerType) Attrs() AttrGroup {
@@ -714,8 +847,67 @@ func (v InterfaceType) addFunction(fn *Function) InterfaceType {
 	return v
 }
 
-func (v InterfaceType) MatchesType(t Type) {
+// MatchesType returns true if t implements the interface. This is the case if
+// t is a named type, or a pointer or reference to one, and has a method with
+// the same type as each function of the interface.
+func (v InterfaceType) MatchesType(t Type) bool {
+	named, ok := InterfaceImplementor(t)
+	if !ok {
+		return false
+	}
 
+	return v.MissingFunction(named) == nil
+}
+
+// MissingFunction returns the first function of the interface that the named
+// type has no matching method for, or nil if it implements the interface.
+func (v InterfaceType) MissingFunction(t *NamedType) *Function {
+	for _, fn := range v.Functions {
+		if t.ImplementingMethod(fn) == nil {
+			return fn
+		}
+	}
+	return nil
+}
+
+// GetFunction returns the function of the interface with the name and its
+// index in the vtable, or nil and -1 if there is no such function.
+func (v InterfaceType) GetFunction(name string) (*Function, int) {
+	for idx, fn := range v.Functions {
+		if fn.Name == name {
+			return fn, idx
+		}
+	}
+	return nil, -1
+}
+
+// InterfaceImplementor returns the named type whose methods implement the
+// interface for a value of type t. This is t itself, or the type it points to
+// or references. Interfaces don't implement other interfaces.
+func InterfaceImplementor(t Type) (*NamedType, bool) {
+	switch typ := t.(type) {
+	case PointerType:
+		t = typ.Addressee
+	case MutableReferenceType:
+		t = typ.Referrer
+	case ConstantReferenceType:
+		t = typ.Referrer
+	}
+
+	named, ok := t.(*NamedType)
+	if !ok {
+		return nil, false
+	}
+
+	if _, isInterface := named.ActualType().(InterfaceType); isInterface {
+		return nil, false
+	}
+	return named, true
+}
+
+func canCastToInterface(from Type, to Type) bool {
+	iface, ok := to.ActualType().(InterfaceType)
+	return ok && iface.MatchesType(from)
 }
 
 func (v InterfaceType) Attrs() AttrGroup {
@@ -873,6 +1065,7 @@ type FunctionType struct {
 	Parameters []Type
 	Return     Type
 	IsVariadic bool
+	IsClosure  bool // a function pointer with an environment pointer
 
 	Receiver Type // non-nil if non-static method
 }
@@ -894,7 +1087,11 @@ func (v FunctionType) TypeName() string {
 		}
 	}
 
-	res += "func("
+	if v.IsClosure {
+		res += "closure("
+	} else {
+		res += "func("
+	}
 
 	for idx, para := range v.Parameters {
 		res += para.TypeName()
@@ -933,7 +1130,25 @@ func (v FunctionType) IsVoidType() bool {
 }
 
 func (v FunctionType) CanCastTo(t Type) bool {
-	return false
+	return ConvertsToClosure(v, t)
+}
+
+// ConvertsToClosure returns true if a value of type from can be used as a
+// closure of type to, which is the case for functions of the same type that
+// aren't closures.
+func ConvertsToClosure(from, to Type) bool {
+	fromFunc, ok := from.ActualType().(FunctionType)
+	if !ok || fromFunc.IsClosure {
+		return false
+	}
+
+	toFunc, ok := to.ActualType().(FunctionType)
+	if !ok || !toFunc.IsClosure {
+		return false
+	}
+
+	fromFunc.IsClosure = true
+	return fromFunc.Equals(toFunc)
 }
 
 func (v FunctionType) Attrs() AttrGroup {
@@ -950,7 +1165,7 @@ func (v FunctionType) Equals(t Type) bool {
 		return false
 	}
 
-	if v.IsVariadic != other.IsVariadic {
+	if v.IsVariadic != other.IsVariadic || v.IsClosure != other.IsClosure {
 		return false
 	}
 
@@ -22,7 +22,9 @@ func NewASTVisitor(visitor Visitor) *ASTVisitor {
 
 func (v *ASTVisitor) VisitSubmodule(submodule *Submodule) {
 	v.EnterScope()
-	submodule.Nodes = v.VisitNodes(submodule.Nodes)
+	nodes := v.VisitNodes(submodule.Nodes)
+	// keep the nodes added to the submodule during the visit
+	submodule.Nodes = append(nodes, submodule.Nodes[len(nodes):]...)
 	v.ExitScope()
 }
 
@@ -150,11 +152,22 @@ func (v *ASTVisitor) VisitChildren(n Node) {
 	case *MatchStat:
 		n.Target = v.VisitExpr(n.Target)
 
-		res := make(map[Expr]Node)
-		for pattern, stmt := range n.Branches {
-			res[v.VisitExpr(pattern)] = v.Visit(stmt)
+		// each arm gets its own scope for the variables bound by its pattern
+		for i, pattern := range n.Patterns {
+			v.EnterScope()
+			n.Patterns[i] = v.VisitExpr(pattern)
+			n.Bodies[i] = v.VisitBlock(n.Bodies[i])
+			v.ExitScope()
 		}
-		n.Branches = res
+
+	case *TuplePattern:
+		n.Elements = v.VisitExprs(n.Elements)
+
+	case *StructPattern:
+		n.Values = v.VisitExprs(n.Values)
+
+	case *EnumPattern:
+		n.Payload = v.VisitExpr(n.Payload)
 
 	case *BinaryExpr:
 		n.Lhand = v.VisitExpr(n.Lhand)
@@ -181,6 +194,7 @@ func (v *ASTVisitor) VisitChildren(n Node) {
 		n.Members = v.VisitExprs(n.Members)
 
 	case *CompositeLiteral:
+		n.Length = v.VisitExpr(n.Length)
 		n.Values = v.VisitExprs(n.Values)
 
 	case *EnumLiteral:
@@ -204,6 +218,9 @@ func (v *ASTVisitor) VisitChildren(n Node) {
 	case *CallStat:
 		n.Call = v.Visit(n.Call).(*CallExpr)
 
+	case *AsmStat:
+		n.Asm = v.Visit(n.Asm).(*AsmExpr)
+
 	case *DeferStat:
 		n.Call = v.Visit(n.Call).(*CallExpr)
 
@@ -216,6 +233,11 @@ func (v *ASTVisitor) VisitChildren(n Node) {
 	case *CastExpr:
 		n.Expr = v.VisitExpr(n.Expr)
 
+	case *AsmExpr:
+		for _, input := range n.Inputs {
+			input.Value = v.VisitExpr(input.Value)
+		}
+
 	case *LambdaExpr:
 		v.VisitFunction(n.Function)
 
@@ -239,7 +261,7 @@ func (v *ASTVisitor) VisitChildren(n Node) {
 
 	case *NumericLiteral, *StringLiteral, *BoolLiteral, *RuneLiteral,
 		*VariableAccessExpr, *TypeDecl, *DefaultExpr, *DefaultMatchBranch,
-		*UseDirective, *BreakStat, *NextStat, *FunctionAccessExpr:
+		*UseDirective, *BreakStat, *NextStat, *FunctionAccessExpr, *BindingPattern:
 		// do nothing
 
 	default:
@@ -5,15 +5,26 @@ import (
 )
 
 type AttributeCheck struct {
+	InFunction int
 }
 
 func (v *AttributeCheck) Init(s *SemanticAnalyzer)       {}
 func (v *AttributeCheck) EnterScope(s *SemanticAnalyzer) {}
 func (v *AttributeCheck) ExitScope(s *SemanticAnalyzer)  {}
 
-func (v *AttributeCheck) PostVisit(s *SemanticAnalyzer, n parser.Node) {}
+func (v *AttributeCheck) PostVisit(s *SemanticAnalyzer, n parser.Node) {
+	switch n.(type) {
+	case *parser.FunctionDecl, *parser.LambdaExpr:
+		v.InFunction--
+	}
+}
 
 func (v *AttributeCheck) Visit(s *SemanticAnalyzer, n parser.Node) {
+	switch n.(type) {
+	case *parser.FunctionDecl, *parser.LambdaExpr:
+		v.InFunction++
+	}
+
 	switch n := n.(type) {
 	case *parser.TypeDecl:
 		typ := n.NamedType.Type
@@ -37,14 +48,23 @@ func (v *AttributeCheck) Destroy(s *SemanticAnalyzer) {
 }
 
 func (v *AttributeCheck) CheckFunctionDecl(s *SemanticAnalyzer, n *parser.FunctionDecl) {
-	v.CheckAttrsDistanceFromLine(s, n.Function.Type.Attrs(), n.Pos().Line, "function", n.Function.Name)
+	attrs := n.Function.Type.Attrs()
+	v.CheckAttrsDistanceFromLine(s, attrs, n.Pos().Line, "function", n.Function.Name)
 
-	for _, attr := range n.Function.Type.Attrs() {
+	for _, attr := range attrs {
 		switch attr.Key {
 		case "deprecated":
 		case "unused":
 		case "c":
+		case "const":
+			if attr.Value != "" {
+				s.Err(attr, "Function attribute `%s` doesn't expect value", attr.Key)
+			}
 		case "call_conv":
+		case "init", "fini":
+			v.CheckInitFunction(s, n, attr)
+		case "section", "weak", "visibility":
+			v.CheckLinkageAttr(s, attr, n.IsPublic() || attrs.Contains("c") || attrs.Contains("weak"))
 		case "inline":
 			switch attr.Value {
 			case "always":
@@ -59,6 +79,28 @@ func (v *AttributeCheck) CheckFunctionDecl(s *SemanticAnalyzer, n *parser.Functi
 	}
 }
 
+// Functions run before or after main can't be passed arguments, and nothing
+// receives their result
+func (v *AttributeCheck) CheckInitFunction(s *SemanticAnalyzer, n *parser.FunctionDecl, attr *parser.Attr) {
+	if attr.Value != "" {
+		s.Err(attr, "Function attribute `%s` doesn't expect value", attr.Key)
+	}
+
+	fnType := n.Function.Type
+	if len(fnType.Parameters) > 0 || fnType.IsVariadic || fnType.Receiver != nil {
+		s.Err(n, "Function `%s` with [%s] attribute must not have parameters", n.Function.Name, attr.Key)
+	}
+	if !fnType.Return.Equals(parser.PRIMITIVE_void) {
+		s.Err(n, "Function `%s` with [%s] attribute must not return a value", n.Function.Name, attr.Key)
+	}
+	if n.Function.TypeArguments != nil {
+		s.Err(n, "Function `%s` with [%s] attribute must not be generic", n.Function.Name, attr.Key)
+	}
+	if n.Prototype {
+		s.Err(n, "Function `%s` with [%s] attribute must have a body", n.Function.Name, attr.Key)
+	}
+}
+
 func (v *AttributeCheck) CheckStructType(s *SemanticAnalyzer, n parser.StructType) {
 	for _, attr := range n.Attrs() {
 		switch attr.Key {
@@ -87,17 +129,64 @@ func (v *AttributeCheck) CheckStructType(s *SemanticAnalyzer, n parser.StructTyp
 func (v *AttributeCheck) CheckVariableDecl(s *SemanticAnalyzer, n *parser.VariableDecl) {
 	v.CheckAttrsDistanceFromLine(s, n.Variable.Attrs, n.Pos().Line, "variable", n.Variable.Name)
 
+	isGlobal := v.InFunction == 0 && !n.Variable.FromStruct
 	for _, attr := range n.Variable.Attrs {
 		switch attr.Key {
 		case "deprecated":
 			// value is optional, nothing to check
 		case "unused":
+		case "thread_local", "section", "weak", "visibility":
+			if !isGlobal {
+				s.Err(attr, "Variable attribute `%s` is only allowed on global variables", attr.Key)
+			} else if attr.Key == "thread_local" {
+				if attr.Value != "" {
+					s.Err(attr, "Variable attribute `%s` doesn't expect value", attr.Key)
+				}
+				// the initializers of globals only run on the main thread
+				if n.Assignment != nil && !isConstantExpr(n.Assignment) {
+					s.Err(n, "Thread-local variable `%s` must be initialized with a constant", n.Variable.Name)
+				}
+			} else {
+				v.CheckLinkageAttr(s, attr, n.IsPublic() || n.Variable.Attrs.Contains("weak"))
+			}
 		default:
 			s.Err(attr, "Invalid variable attribute key `%s`", attr.Key)
 		}
 	}
 }
 
+// Whether the expression can be evaluated at compile time, once the sizes of
+// types are known
+func isConstantExpr(n parser.Expr) bool {
+	_, err := (&parser.ConstantEvaluator{}).Evaluate(n)
+	return err == nil || err.(*parser.ConstantError).TargetDependent
+}
+
+// Checks the attributes changing how a global variable or function is linked.
+// exported is whether the declaration is visible outside of its module.
+func (v *AttributeCheck) CheckLinkageAttr(s *SemanticAnalyzer, attr *parser.Attr, exported bool) {
+	switch attr.Key {
+	case "section":
+		if attr.Value == "" {
+			s.Err(attr, "Attribute `%s` expects the name of a section", attr.Key)
+		}
+	case "weak":
+		if attr.Value != "" {
+			s.Err(attr, "Attribute `%s` doesn't expect value", attr.Key)
+		}
+	case "visibility":
+		switch attr.Value {
+		case "hidden":
+		case "default":
+		default:
+			s.Err(attr, "Invalid value `%s` for [visibility] attribute", attr.Value)
+		}
+		if !exported {
+			s.Warn(attr, "Attribute `%s` has no effect on declarations which aren't public", attr.Key)
+		}
+	}
+}
+
 func (v *AttributeCheck) CheckAttrsDistanceFromLine(s *SemanticAnalyzer, attrs parser.AttrGroup, line int, declType, declName string) {
 	// Turn map into a list sorted by line number
 	var sorted []*parser.Attr
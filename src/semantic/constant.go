@@ -0,0 +1,77 @@
+package semantic
+
+import (
+	"math/big"
+
+	"github.com/ark-lang/ark/src/parser"
+)
+
+// Checks that the values of constants and the lengths of array literals can be
+// evaluated at compile time. Global variables are initialized at runtime if
+// their initial value can't be.
+type ConstantCheck struct {
+	evaluator *parser.ConstantEvaluator
+}
+
+func (v *ConstantCheck) Init(s *SemanticAnalyzer) {
+	v.evaluator = &parser.ConstantEvaluator{}
+}
+
+func (v *ConstantCheck) EnterScope(s *SemanticAnalyzer) {}
+func (v *ConstantCheck) ExitScope(s *SemanticAnalyzer)  {}
+
+func (v *ConstantCheck) Visit(s *SemanticAnalyzer, n parser.Node) {
+	switch n := n.(type) {
+	case *parser.VariableDecl:
+		if n.Variable.IsConstant && n.Assignment != nil {
+			v.check(s, n.Assignment)
+		}
+
+	case *parser.CompositeLiteral:
+		if n.Length != nil {
+			v.checkArrayLength(s, n)
+		}
+	}
+}
+
+func (v *ConstantCheck) PostVisit(s *SemanticAnalyzer, n parser.Node) {}
+
+func (v *ConstantCheck) check(s *SemanticAnalyzer, n parser.Expr) {
+	if _, err := v.evaluator.Evaluate(n); err != nil {
+		v.report(s, n, err)
+	}
+}
+
+func (v *ConstantCheck) checkArrayLength(s *SemanticAnalyzer, n *parser.CompositeLiteral) {
+	length, err := v.evaluator.EvaluateInt(n.Length)
+	if err != nil {
+		v.report(s, n.Length, err)
+		return
+	}
+
+	if length.Sign() < 0 {
+		s.Err(n.Length, "Array length %s is negative", length)
+	} else if length.Cmp(big.NewInt(int64(len(n.Values)))) < 0 {
+		s.Err(n.Length, "Array literal has %d values, but a length of %s", len(n.Values), length)
+	}
+}
+
+// Reports the error of evaluating the expression n
+func (v *ConstantCheck) report(s *SemanticAnalyzer, n parser.Expr, err error) {
+	// values depending on the size of a type are checked by the codegen
+	cerr := err.(*parser.ConstantError)
+	if cerr.TargetDependent {
+		return
+	}
+
+	// errors in constants declared in other files are reported there
+	if cerr.Node.Pos().Filename != s.Submodule.File.Name {
+		s.Err(n, "Expression can't be evaluated at compile time")
+		return
+	}
+	s.Err(cerr.Node, "%s", cerr.Msg)
+}
+
+func (v *ConstantCheck) Destroy(s *SemanticAnalyzer) {
+
+}
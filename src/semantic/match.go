@@ -0,0 +1,174 @@
+package semantic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ark-lang/ark/src/parser"
+)
+
+type MatchCheck struct {
+}
+
+func (v *MatchCheck) Init(s *SemanticAnalyzer)       {}
+func (v *MatchCheck) EnterScope(s *SemanticAnalyzer) {}
+func (v *MatchCheck) ExitScope(s *SemanticAnalyzer)  {}
+func (v *MatchCheck) Destroy(s *SemanticAnalyzer)    {}
+
+func (v *MatchCheck) Visit(s *SemanticAnalyzer, n parser.Node) {
+	switch n := n.(type) {
+	case *parser.MatchStat:
+		v.CheckMatchStat(s, n)
+	}
+}
+
+func (v *MatchCheck) PostVisit(s *SemanticAnalyzer, n parser.Node) {}
+
+func (v *MatchCheck) CheckMatchStat(s *SemanticAnalyzer, n *parser.MatchStat) {
+	covered := make(map[matchKey]bool)
+	matchesAll := false
+
+	for _, pattern := range n.Patterns {
+		if matchesAll {
+			s.Warn(pattern, "Unreachable match arm, an earlier arm matches everything")
+			continue
+		}
+
+		if key, ok := patternTestKey(pattern); ok && covered[key] {
+			s.Warn(pattern, "Unreachable match arm, `%s` is already matched by an earlier arm",
+				key.describe(n.Target.GetType()))
+		}
+
+		if parser.IsIrrefutablePattern(pattern) {
+			matchesAll = true
+		} else if key, ok := patternCoverKey(pattern); ok {
+			covered[key] = true
+		}
+	}
+
+	if matchesAll {
+		return
+	}
+
+	if missing := missingMatchPatterns(n); len(missing) > 0 {
+		for i, key := range missing {
+			missing[i] = "`" + key + "`"
+		}
+		s.Err(n, "Match is not exhaustive, missing %s", strings.Join(missing, ", "))
+	}
+}
+
+// IsExhaustiveMatch returns true if any value of the target type is matched
+// by one of the arms of the match statement.
+func IsExhaustiveMatch(n *parser.MatchStat) bool {
+	for _, pattern := range n.Patterns {
+		if parser.IsIrrefutablePattern(pattern) {
+			return true
+		}
+	}
+
+	switch actual := n.Target.GetType().ActualType(); actual.(type) {
+	case parser.EnumType:
+		return len(missingMatchPatterns(n)) == 0
+	case parser.PrimitiveType:
+		return actual == parser.PRIMITIVE_bool && len(missingMatchPatterns(n)) == 0
+	}
+
+	return false
+}
+
+// Returns the enum members or bools not matched by any arm of the match,
+// ignoring arms that match everything.
+func missingMatchPatterns(n *parser.MatchStat) []string {
+	covered := make(map[matchKey]bool)
+	for _, pattern := range n.Patterns {
+		if key, ok := patternCoverKey(pattern); ok {
+			covered[key] = true
+		}
+	}
+
+	var keys []matchKey
+	targetType := n.Target.GetType()
+	switch actual := targetType.ActualType().(type) {
+	case parser.EnumType:
+		for _, mem := range actual.Members {
+			keys = append(keys, matchKey{enumMember: true, value: mem.Name})
+		}
+
+	case parser.PrimitiveType:
+		if actual == parser.PRIMITIVE_bool {
+			keys = []matchKey{{value: "true"}, {value: "false"}}
+		}
+	}
+
+	var missing []string
+	for _, key := range keys {
+		if !covered[key] {
+			missing = append(missing, key.describe(targetType))
+		}
+	}
+	return missing
+}
+
+// Identifies the value or enum member a pattern tests for. All patterns of a
+// match have the type of its target, so enum members are told apart by their
+// name alone.
+type matchKey struct {
+	enumMember bool
+	value      string
+}
+
+func (v matchKey) describe(typ parser.Type) string {
+	if v.enumMember {
+		return typ.TypeName() + "::" + v.value
+	}
+	return v.value
+}
+
+// Returns the key of the single value or enum member a pattern tests for, if
+// there is one.
+func patternTestKey(pattern parser.Expr) (matchKey, bool) {
+	switch pattern := pattern.(type) {
+	case *parser.NumericLiteral:
+		if pattern.IsFloat {
+			return matchKey{value: strconv.FormatFloat(pattern.FloatValue, 'g', -1, 64)}, true
+		}
+		return matchKey{value: pattern.IntValue.String()}, true
+
+	case *parser.RuneLiteral:
+		return matchKey{value: strconv.QuoteRune(pattern.Value)}, true
+
+	case *parser.StringLiteral:
+		return matchKey{value: strconv.Quote(pattern.Value)}, true
+
+	case *parser.BoolLiteral:
+		return matchKey{value: fmt.Sprintf("%t", pattern.Value)}, true
+
+	case *parser.EnumLiteral:
+		return matchKey{enumMember: true, value: pattern.Member}, true
+
+	case *parser.EnumPattern:
+		return matchKey{enumMember: true, value: pattern.Member}, true
+
+	case *parser.VariableAccessExpr:
+		// constants are keyed by their value
+		evaluator := &parser.ConstantEvaluator{}
+		if value, err := evaluator.Evaluate(pattern); err == nil {
+			return patternTestKey(value)
+		}
+	}
+
+	return matchKey{}, false
+}
+
+// Like patternTestKey, but returns false if the pattern doesn't match every
+// value with that key, like an enum pattern that only matches some payloads.
+func patternCoverKey(pattern parser.Expr) (matchKey, bool) {
+	if enumPattern, ok := pattern.(*parser.EnumPattern); ok {
+		if enumPattern.Payload != nil && !parser.IsIrrefutablePattern(enumPattern.Payload) {
+			return matchKey{}, false
+		}
+	}
+	return patternTestKey(pattern)
+}
@@ -88,6 +88,10 @@ func isTypeRecursive(typ parser.Type) (bool, []parser.Type) {
 				return true
 			}
 
+			// only the types on the current path make the definition
+			// recursive, not the ones used by earlier fields
+			delete(traversed, current)
+
 			// TODO: Add array if we ever add embedded fixed size/static arrays
 		}
 		return false
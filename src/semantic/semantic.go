@@ -55,12 +55,14 @@ func NewSemanticAnalyzer(module *parser.Submodule, useOwnership bool, ignoreUnus
 	res.Checks = []SemanticCheck{
 		&AttributeCheck{},
 		&UnreachableCheck{},
+		&MatchCheck{},
 		&BreakAndNextCheck{},
 		&DeprecatedCheck{},
 		&RecursiveDefinitionCheck{},
 		&TypeCheck{},
 		&ImmutableAssignCheck{},
 		&UseBeforeDeclareCheck{},
+		&ConstantCheck{},
 		&MiscCheck{},
 	}
 
@@ -103,6 +105,11 @@ func (v *SemanticAnalyzer) Finalize() {
 }
 
 func (v *SemanticAnalyzer) Visit(n *parser.Node) bool {
+	// generic functions are only analyzed through their instances
+	if decl, ok := (*n).(*parser.FunctionDecl); ok && decl.Function.IsGeneric() {
+		return false
+	}
+
 	for _, check := range v.Checks {
 		check.Visit(v, *n)
 	}
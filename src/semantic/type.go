@@ -1,6 +1,11 @@
 package semantic
 
-import "github.com/ark-lang/ark/src/parser"
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ark-lang/ark/src/parser"
+)
 
 type TypeCheck struct {
 	functions []*parser.Function
@@ -33,12 +38,20 @@ func (v *TypeCheck) Visit(s *SemanticAnalyzer, n parser.Node) {
 	switch n := n.(type) {
 	case *parser.FunctionDecl:
 		v.pushFunction(n.Function)
+		v.checkVectorTypes(s, n, n.Function.Type)
 
 	case *parser.LambdaExpr:
 		v.pushFunction(n.Function)
 
+	case *parser.TypeDecl:
+		// generic types are checked through their instances
+		if len(n.NamedType.Parameters) == 0 {
+			v.checkVectorTypes(s, n, n.NamedType.Type)
+		}
+
 	case *parser.VariableDecl:
 		v.CheckVariableDecl(s, n)
+		v.checkVectorTypes(s, n, n.Variable.Type)
 
 	case *parser.ReturnStat:
 		v.CheckReturnStat(s, n)
@@ -46,6 +59,9 @@ func (v *TypeCheck) Visit(s *SemanticAnalyzer, n parser.Node) {
 	case *parser.IfStat:
 		v.CheckIfStat(s, n)
 
+	case *parser.MatchStat:
+		v.CheckMatchStat(s, n)
+
 	case *parser.AssignStat:
 		v.CheckAssignStat(s, n)
 
@@ -63,10 +79,14 @@ func (v *TypeCheck) Visit(s *SemanticAnalyzer, n parser.Node) {
 
 	case *parser.CastExpr:
 		v.CheckCastExpr(s, n)
+		v.checkVectorTypes(s, n, n.Type)
 
 	case *parser.CallExpr:
 		v.CheckCallExpr(s, n)
 
+	case *parser.AsmExpr:
+		v.CheckAsmExpr(s, n)
+
 	case *parser.ArrayAccessExpr:
 		v.CheckArrayAccessExpr(s, n)
 
@@ -81,6 +101,7 @@ func (v *TypeCheck) Visit(s *SemanticAnalyzer, n parser.Node) {
 
 	case *parser.CompositeLiteral:
 		v.CheckCompositeLiteral(s, n)
+		v.checkVectorTypes(s, n, n.Type)
 
 	case *parser.TupleLiteral:
 		v.CheckTupleLiteral(s, n)
@@ -94,8 +115,64 @@ func (v *TypeCheck) Destroy(s *SemanticAnalyzer) {
 
 }
 
+// Reports an error if typ is an interface that the type of expr is expected to
+// implement, but doesn't. Values that implement it are converted while
+// inferring types. Returns false if an error was reported.
+func (v *TypeCheck) checkImplements(s *SemanticAnalyzer, expr parser.Expr, typ parser.Type) bool {
+	iface, ok := typ.ActualType().(parser.InterfaceType)
+	if !ok || expr.GetType().Equals(typ) {
+		return true
+	}
+
+	named, ok := parser.InterfaceImplementor(expr.GetType())
+	if !ok {
+		return true
+	}
+
+	if missing := iface.MissingFunction(named); missing != nil {
+		s.Err(expr, "Type `%s` does not implement `%s`, missing method `%s`",
+			expr.GetType().TypeName(), typ.TypeName(), missing.Name)
+		return false
+	}
+	return true
+}
+
+// Checks that the vector types used by typ have integer or floating point
+// members. Named types are checked at their declaration, except for the
+// instances of generic types, which have their type arguments substituted.
+func (v *TypeCheck) checkVectorTypes(s *SemanticAnalyzer, loc parser.Locatable, typ parser.Type) {
+	v.checkVectorTypesIn(s, loc, typ, make(map[*parser.NamedType]bool))
+}
+
+func (v *TypeCheck) checkVectorTypesIn(s *SemanticAnalyzer, loc parser.Locatable, typ parser.Type, visited map[*parser.NamedType]bool) {
+	switch typ := typ.(type) {
+	case *parser.NamedType:
+		if typ.GenericType == nil || visited[typ] {
+			return
+		}
+		visited[typ] = true
+
+	case parser.VectorType:
+		member := typ.MemberType.ActualType()
+		_, isParameter := member.(parser.ParameterType)
+		prim, isPrimitive := member.(parser.PrimitiveType)
+		if !isParameter && !(isPrimitive && (prim.IsIntegerType() || prim.IsFloatingType())) {
+			s.Err(loc, "Invalid member type `%s` in vector type, expected integer or floating point type", typ.MemberType.TypeName())
+		}
+		return
+	}
+
+	for _, child := range parser.AddChildren(typ, nil) {
+		v.checkVectorTypesIn(s, loc, child, visited)
+	}
+}
+
 func (v *TypeCheck) CheckVariableDecl(s *SemanticAnalyzer, decl *parser.VariableDecl) {
 	if decl.Assignment != nil {
+		if !v.checkImplements(s, decl.Assignment, decl.Variable.Type) {
+			return
+		}
+
 		if !decl.Variable.Type.Equals(decl.Assignment.GetType()) {
 			s.Err(decl, "Cannot assign expression of type `%s` to variable of type `%s`",
 				decl.Assignment.GetType().TypeName(), decl.Variable.Type.TypeName())
@@ -113,6 +190,10 @@ func (v *TypeCheck) CheckReturnStat(s *SemanticAnalyzer, stat *parser.ReturnStat
 		if v.Function().Type.Return.Equals(parser.PRIMITIVE_void) {
 			s.Err(stat.Value, "Cannot return expression from void function")
 		} else {
+			if !v.checkImplements(s, stat.Value, v.Function().Type.Return) {
+				return
+			}
+
 			if !stat.Value.GetType().Equals(v.Function().Type.Return) {
 				s.Err(stat.Value, "Cannot return expression of type `%s` from function `%s` of type `%s`",
 					stat.Value.GetType().TypeName(), v.Function().Name, v.Function().Type.Return.TypeName())
@@ -130,7 +211,76 @@ func (v *TypeCheck) CheckIfStat(s *SemanticAnalyzer, stat *parser.IfStat) {
 
 }
 
+func (v *TypeCheck) CheckMatchStat(s *SemanticAnalyzer, stat *parser.MatchStat) {
+	for _, pattern := range stat.Patterns {
+		v.checkPattern(s, pattern, stat.Target.GetType())
+	}
+}
+
+func (v *TypeCheck) checkPattern(s *SemanticAnalyzer, pattern parser.Expr, typ parser.Type) {
+	switch pattern := pattern.(type) {
+	case *parser.DefaultMatchBranch, *parser.BindingPattern:
+		// matches anything
+
+	case *parser.TuplePattern:
+		tupleType, ok := typ.ActualType().(parser.TupleType)
+		if !ok || len(tupleType.Members) != len(pattern.Elements) {
+			s.Err(pattern, "Cannot match value of type `%s` against a tuple pattern with %d elements",
+				typ.TypeName(), len(pattern.Elements))
+			return
+		}
+
+		for idx, elem := range pattern.Elements {
+			v.checkPattern(s, elem, tupleType.Members[idx])
+		}
+
+	case *parser.StructPattern:
+		if !pattern.InEnum && !pattern.Type.Equals(typ) {
+			s.Err(pattern, "Mismatched types: `%s` and `%s`", typ.TypeName(), pattern.Type.TypeName())
+			return
+		}
+
+		structType, ok := typ.ActualType().(parser.StructType)
+		if !ok {
+			s.Err(pattern, "Cannot match value of type `%s` against a struct pattern", typ.TypeName())
+			return
+		}
+
+		for idx, val := range pattern.Values {
+			v.checkPattern(s, val, structType.Variables[idx].Variable.Type)
+		}
+
+	case *parser.EnumPattern:
+		if !pattern.Type.Equals(typ) {
+			s.Err(pattern, "Mismatched types: `%s` and `%s`", typ.TypeName(), pattern.Type.TypeName())
+			return
+		}
+
+		if pattern.Payload != nil {
+			member, _ := typ.ActualType().(parser.EnumType).GetMember(pattern.Member)
+			v.checkPattern(s, pattern.Payload, member.Type)
+		}
+
+	default:
+		actual := typ.ActualType()
+		_, isEnum := actual.(parser.EnumType)
+		if !actual.IsIntegerType() && !actual.IsFloatingType() && actual != parser.PRIMITIVE_rune &&
+			actual != parser.PRIMITIVE_bool && !isEnum && !actual.Equals(parser.ArrayOf(parser.PRIMITIVE_u8)) {
+			s.Err(pattern, "Cannot compare value of type `%s` in a pattern", typ.TypeName())
+			return
+		}
+
+		if !pattern.GetType().Equals(typ) {
+			s.Err(pattern, "Mismatched types: `%s` and `%s`", typ.TypeName(), pattern.GetType().TypeName())
+		}
+	}
+}
+
 func (v *TypeCheck) CheckAssignStat(s *SemanticAnalyzer, stat *parser.AssignStat) {
+	if !v.checkImplements(s, stat.Assignment, stat.Access.GetType()) {
+		return
+	}
+
 	if !stat.Access.GetType().Equals(stat.Assignment.GetType()) {
 		s.Err(stat, "Mismatched types: `%s` and `%s`", stat.Access.GetType().TypeName(), stat.Assignment.GetType().TypeName())
 	}
@@ -166,6 +316,11 @@ func (v *TypeCheck) CheckUnaryExpr(s *SemanticAnalyzer, expr *parser.UnaryExpr)
 }
 
 func (v *TypeCheck) CheckBinaryExpr(s *SemanticAnalyzer, expr *parser.BinaryExpr) {
+	if vec, ok := expr.Lhand.GetType().ActualType().(parser.VectorType); ok {
+		v.checkVectorBinaryExpr(s, expr, vec)
+		return
+	}
+
 	switch expr.Op {
 	case parser.BINOP_EQ, parser.BINOP_NOT_EQ:
 		if !expr.Lhand.GetType().Equals(expr.Rhand.GetType()) {
@@ -207,6 +362,29 @@ func (v *TypeCheck) CheckBinaryExpr(s *SemanticAnalyzer, expr *parser.BinaryExpr
 	}
 }
 
+// Vectors are operated on element-wise, so both operands must be vectors of
+// the same type, with members the operator can be used on
+func (v *TypeCheck) checkVectorBinaryExpr(s *SemanticAnalyzer, expr *parser.BinaryExpr, typ parser.VectorType) {
+	if !expr.Lhand.GetType().Equals(expr.Rhand.GetType()) {
+		s.Err(expr, "Operands for binary operator `%s` must have the same type, have `%s` and `%s`",
+			expr.Op.OpString(), expr.Lhand.GetType().TypeName(), expr.Rhand.GetType().TypeName())
+		return
+	}
+
+	switch expr.Op {
+	case parser.BINOP_BIT_AND, parser.BINOP_BIT_OR, parser.BINOP_BIT_XOR,
+		parser.BINOP_BIT_LEFT, parser.BINOP_BIT_RIGHT:
+		if !typ.MemberType.IsIntegerType() {
+			s.Err(expr, "Operands for bitwise operator `%s` must be vectors of integers, have `%s`",
+				expr.Op.OpString(), typ.TypeName())
+		}
+
+	case parser.BINOP_LOG_AND, parser.BINOP_LOG_OR:
+		s.Err(expr, "Operands for logical operator `%s` must not be vectors, have `%s`",
+			expr.Op.OpString(), typ.TypeName())
+	}
+}
+
 func (v *TypeCheck) CheckCastExpr(s *SemanticAnalyzer, expr *parser.CastExpr) {
 	if expr.Type.Equals(expr.Expr.GetType()) {
 		s.Warn(expr, "Casting expression of type `%s` to the same type",
@@ -217,6 +395,61 @@ func (v *TypeCheck) CheckCastExpr(s *SemanticAnalyzer, expr *parser.CastExpr) {
 	}
 }
 
+func (v *TypeCheck) CheckAsmExpr(s *SemanticAnalyzer, expr *parser.AsmExpr) {
+	for _, output := range expr.Outputs {
+		if !strings.HasPrefix(output.Constraint, "=") {
+			s.Err(output, "Constraint `%s` of asm output must start with `=`", output.Constraint)
+			continue
+		}
+
+		// `&` marks outputs written before all inputs are read
+		code := strings.TrimPrefix(strings.TrimPrefix(output.Constraint, "="), "&")
+		v.checkAsmOperand(s, expr, output, code, output.Type, false)
+	}
+
+	for _, input := range expr.Inputs {
+		v.checkAsmOperand(s, expr, input, input.Constraint, input.Value.GetType(), true)
+	}
+}
+
+// Checks that the type of an operand of an asm expression can be passed the
+// way its constraint asks for
+func (v *TypeCheck) checkAsmOperand(s *SemanticAnalyzer, expr *parser.AsmExpr, operand *parser.AsmOperand, code string, typ parser.Type, input bool) {
+	isInteger, isPointer := typ.IsIntegerType(), typ.LevelsOfIndirection() > 0
+
+	switch {
+	case strings.HasPrefix(code, "{") && strings.HasSuffix(code, "}") && len(code) > 2:
+		if !(isInteger || isPointer || typ.IsFloatingType()) {
+			s.Err(operand, "Asm operand in register `%s` must be an integer, floating point number or pointer, have `%s`",
+				code[1:len(code)-1], typ.TypeName())
+		}
+
+	case code == "r":
+		if !(isInteger || isPointer) {
+			s.Err(operand, "Asm operand in a general purpose register must be an integer or pointer, have `%s`", typ.TypeName())
+		}
+
+	case (code == "i" || code == "n") && input:
+		if !isInteger {
+			s.Err(operand, "Immediate asm operand must be an integer, have `%s`", typ.TypeName())
+		} else if !isConstantExpr(operand.Value) {
+			s.Err(operand, "Immediate asm operand must be a constant")
+		}
+
+	case input && code != "" && strings.Trim(code, "0123456789") == "":
+		idx, err := strconv.Atoi(code)
+		if err != nil || idx >= len(expr.Outputs) {
+			s.Err(operand, "Asm input tied to nonexistent output %s", code)
+		} else if output := expr.Outputs[idx]; !typ.Equals(output.Type) {
+			s.Err(operand, "Asm input tied to output %d must have type `%s`, have `%s`",
+				idx, output.Type.TypeName(), typ.TypeName())
+		}
+
+	default:
+		s.Err(operand, "Unsupported asm constraint `%s`", operand.Constraint)
+	}
+}
+
 func (v *TypeCheck) CheckCallExpr(s *SemanticAnalyzer, expr *parser.CallExpr) {
 	fnType := expr.Function.GetType().(parser.FunctionType)
 
@@ -285,6 +518,11 @@ func (v *TypeCheck) CheckCallExpr(s *SemanticAnalyzer, expr *parser.CallExpr) {
 			}
 		} else {
 			par := fnType.Parameters[i]
+
+			if !v.checkImplements(s, arg, par) {
+				continue
+			}
+
 			if !arg.GetType().Equals(par) {
 				s.Err(arg, "Mismatched types in function call: `%s` and `%s`",
 					arg.GetType().TypeName(), par.TypeName())
@@ -294,7 +532,9 @@ func (v *TypeCheck) CheckCallExpr(s *SemanticAnalyzer, expr *parser.CallExpr) {
 }
 
 func (v *TypeCheck) CheckArrayAccessExpr(s *SemanticAnalyzer, expr *parser.ArrayAccessExpr) {
-	if _, ok := expr.Array.GetType().ActualType().(parser.ArrayType); !ok {
+	switch expr.Array.GetType().ActualType().(type) {
+	case parser.ArrayType, parser.VectorType:
+	default:
 		s.Err(expr, "Cannot index type `%s` as an array", expr.Array.GetType().TypeName())
 	}
 
@@ -397,6 +637,21 @@ func (v *TypeCheck) CheckCompositeLiteral(s *SemanticAnalyzer, lit *parser.Compo
 			}
 		}
 
+	case parser.VectorType:
+		if len(lit.Values) > typ.Length {
+			s.Err(lit, "Too many elements in literal of vector type `%s`, have %d", typ.TypeName(), len(lit.Values))
+		}
+
+		for i, mem := range lit.Values {
+			if !mem.GetType().Equals(typ.MemberType) {
+				s.Err(mem, "Cannot use element of type `%s` in vector of type `%s`", mem.GetType().TypeName(), typ.TypeName())
+			}
+
+			if lit.Fields[i] != "" {
+				s.Err(mem, "Unexpected field in vector literal: `%s`", lit.Fields[i])
+			}
+		}
+
 	case parser.StructType:
 		for i, mem := range lit.Values {
 			name := lit.Fields[i]
@@ -418,7 +673,7 @@ func (v *TypeCheck) CheckCompositeLiteral(s *SemanticAnalyzer, lit *parser.Compo
 		}
 
 	default:
-		panic("composite literal has neither struct nor array type")
+		panic("composite literal has neither struct, array nor vector type")
 	}
 }
 
@@ -68,6 +68,20 @@ func IsNodeTerminating(n parser.Node) bool {
 		}
 
 		return true
+
+	case *parser.MatchStat:
+		for i, pattern := range n.Patterns {
+			if !n.Bodies[i].IsTerminating {
+				return false
+			}
+
+			// later arms are never run
+			if parser.IsIrrefutablePattern(pattern) {
+				break
+			}
+		}
+
+		return IsExhaustiveMatch(n)
 	}
 
 	return false
@@ -69,7 +69,10 @@ func (v *UnusedCheck) AnalyzeUsage(s *SemanticAnalyzer) {
 		switch node.(type) {
 		case *parser.VariableDecl:
 			decl := node.(*parser.VariableDecl)
-			if !decl.Variable.Attrs.Contains("unused") && !decl.Variable.IsParameter && !decl.Variable.FromStruct && v.uses[decl.Variable] == 0 {
+			// public constants are meant to be used by other modules
+			isPublicConstant := decl.Variable.IsConstant && decl.IsPublic()
+
+			if !decl.Variable.Attrs.Contains("unused") && !decl.Variable.IsParameter && !decl.Variable.FromStruct && !isPublicConstant && v.uses[decl.Variable] == 0 {
 				s.Err(decl, "Unused variable `%s`", decl.Variable.Name)
 			}
 
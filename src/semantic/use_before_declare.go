@@ -38,9 +38,15 @@ func (v *UseBeforeDeclareCheck) Visit(s *SemanticAnalyzer, n parser.Node) {
 		decl := n.(*parser.VariableDecl)
 		v.scope[decl.Variable.Name] = true
 
+	case *parser.BindingPattern:
+		pattern := n.(*parser.BindingPattern)
+		v.scope[pattern.Variable.Name] = true
+
 	case *parser.VariableAccessExpr:
 		expr := n.(*parser.VariableAccessExpr)
-		if !v.scope[expr.Variable.Name] && expr.Variable.ParentModule == s.Submodule.Parent {
+		// constants are evaluated at compile time, so they can be used
+		// anywhere in the module
+		if !v.scope[expr.Variable.Name] && expr.Variable.ParentModule == s.Submodule.Parent && !expr.Variable.IsConstant {
 			s.Err(expr, "Use of variable before declaration: %s", expr.Variable.Name)
 		}
 	}
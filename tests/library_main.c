@@ -0,0 +1,10 @@
+#include <stdint.h>
+#include <stdio.h>
+
+intptr_t ark_add(intptr_t a, intptr_t b);
+intptr_t ark_sum_to(intptr_t n);
+
+int main(void) {
+    printf("%ld %ld\n", (long)ark_add(2, 3), (long)ark_sum_to(10));
+    return 0;
+}